@@ -0,0 +1,76 @@
+package main
+
+import (
+	"TaskManager/pkg/backup"
+	"TaskManager/pkg/storage"
+	"TaskManager/pkg/utilities"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runBackup - подкоманда backup: выгружает все данные в файл (или stdout при -o -)
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	db := fs.String("db", defaultConnStr, "database connection string")
+	out := fs.String("o", "-", "archive file, - for stdout")
+	fs.Parse(args)
+
+	s, err := storage.New(*db)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	err = backup.Export(context.Background(), s, w)
+	if err != nil {
+		return err
+	}
+	if f, ok := w.(*os.File); ok && f != os.Stdout {
+		return f.Close()
+	}
+	return nil
+}
+
+// runRestore - подкоманда restore: восстанавливает данные из файла (или stdin при -i -) и печатает отчет
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	db := fs.String("db", defaultConnStr, "database connection string")
+	in := fs.String("i", "-", "archive file, - for stdin")
+	strategy := fs.String("strategy", backup.StrategySkip, "conflict strategy: skip, overwrite, remap or fail")
+	dryRun := fs.Bool("dryrun", false, "validate and report without saving")
+	fs.Parse(args)
+
+	s, err := storage.New(*db)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	report, err := backup.Import(context.Background(), s, r, *strategy, *dryRun)
+	if err != nil {
+		return err
+	}
+	fmt.Println(utilities.ToJSON(report))
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"TaskManager/pkg/client"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Адрес сервера, если он не задан ни в профиле, ни флагом.
+const defaultServer = "http://localhost:8010"
+
+// Профиль, используемый если в файле настроек не выбран другой.
+const defaultProfile = "default"
+
+// Config - файл настроек клиента со списком профилей.
+type Config struct {
+	// Профиль по умолчанию
+	Current  string              `yaml:"current,omitempty"`
+	Profiles map[string]*Profile `yaml:"profiles,omitempty"`
+}
+
+// Profile - сервер и учетные данные, с которыми работает клиент.
+type Profile struct {
+	Server string `yaml:"server,omitempty"`
+	Token  string `yaml:"token,omitempty"`
+	// ID или имя пользователя, которого обозначает "me"
+	User string `yaml:"user,omitempty"`
+}
+
+// defaultConfigPath - $XDG_CONFIG_HOME/tm/config.yaml или его аналог для ОС
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tm", "config.yaml"), nil
+}
+
+// loadConfig - читает файл настроек, отсутствующий файл дает пустые настройки
+func loadConfig(path string) (*Config, error) {
+	c := &Config{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(b, c)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// save - записывает файл настроек. Файл содержит токены, поэтому доступен только владельцу
+func (c *Config) save(path string) error {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// profileName - имя выбранного профиля: явно заданное, текущее из файла или default
+func (c *Config) profileName(name string) string {
+	switch {
+	case name != "":
+		return name
+	case c.Current != "":
+		return c.Current
+	}
+	return defaultProfile
+}
+
+// app - состояние запуска: флаги, настройки и клиент API
+type app struct {
+	// Значения общих флагов как они заданы пользователем
+	flags struct {
+		config  string
+		profile string
+		server  string
+		token   string
+		output  string
+	}
+
+	configPath string
+	config     *Config
+	profile    Profile
+	api        *client.Client
+	out        io.Writer
+}
+
+// setup - загружает настройки и собирает итоговый профиль: флаги и переменные окружения
+// важнее значений из файла. Вызывается повторно без последствий
+func (a *app) setup() error {
+	if a.api != nil {
+		return nil
+	}
+
+	if !validFormat(a.flags.output) {
+		return fmt.Errorf("unknown output format %q, expected one of: %s", a.flags.output, strings.Join(outputFormats, ", "))
+	}
+
+	a.configPath = a.flags.config
+	if a.configPath == "" {
+		path, err := defaultConfigPath()
+		if err != nil {
+			return err
+		}
+		a.configPath = path
+	}
+	config, err := loadConfig(a.configPath)
+	if err != nil {
+		return err
+	}
+	a.config = config
+
+	name := config.profileName(a.flags.profile)
+	if p, ok := config.Profiles[name]; ok {
+		a.profile = *p
+	} else if a.flags.profile != "" {
+		return fmt.Errorf("profile %q not found in %s", name, a.configPath)
+	}
+	if a.flags.server != "" {
+		a.profile.Server = a.flags.server
+	}
+	if a.flags.token != "" {
+		a.profile.Token = a.flags.token
+	}
+	if a.profile.Server == "" {
+		a.profile.Server = defaultServer
+	}
+
+	a.api, err = client.New(a.profile.Server, client.Config{Token: a.profile.Token})
+	return err
+}
+
+// configCmd - команды работы с профилями
+func (a *app) configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage profiles with server URL, token and user",
+	}
+
+	var user string
+	set := &cobra.Command{
+		Use:   "set [profile]",
+		Short: "Create or update a profile from --server, --token and --user",
+		Example: "  tm config set work --server https://tasks.example.com --token s3cret --user alice\n" +
+			"  tm config set --user 7",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: a.completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := a.config.profileName(a.flags.profile)
+			if len(args) > 0 {
+				name = args[0]
+			}
+			if a.config.Profiles == nil {
+				a.config.Profiles = map[string]*Profile{}
+			}
+			p, ok := a.config.Profiles[name]
+			if !ok {
+				p = &Profile{}
+				a.config.Profiles[name] = p
+			}
+
+			flags := cmd.Flags()
+			if flags.Changed("server") {
+				p.Server = a.flags.server
+			}
+			if flags.Changed("token") {
+				p.Token = a.flags.token
+			}
+			if flags.Changed("user") {
+				p.User = user
+			}
+			if a.config.Current == "" {
+				a.config.Current = name
+			}
+			return a.config.save(a.configPath)
+		},
+	}
+	set.Flags().StringVar(&user, "user", "", `user ID or name that "me" refers to`)
+
+	use := &cobra.Command{
+		Use:               "use <profile>",
+		Short:             "Make a profile the default one",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: a.completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := a.config.Profiles[args[0]]; !ok {
+				return fmt.Errorf("profile %q not found in %s", args[0], a.configPath)
+			}
+			a.config.Current = args[0]
+			return a.config.save(a.configPath)
+		},
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List profiles",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := make([]string, 0, len(a.config.Profiles))
+			for name := range a.config.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			current := a.config.profileName("")
+
+			// Токены не выводятся, в том числе в json и yaml
+			type row struct {
+				Name    string `json:"Name" yaml:"name"`
+				Server  string `json:"Server" yaml:"server"`
+				User    string `json:"User" yaml:"user"`
+				Current bool   `json:"Current" yaml:"current"`
+			}
+			rows := make([]row, 0, len(names))
+			for _, name := range names {
+				p := a.config.Profiles[name]
+				rows = append(rows, row{name, p.Server, p.User, name == current})
+			}
+
+			return a.print(rows, func(w *tabwriter.Writer) {
+				fmt.Fprintln(w, "CURRENT\tNAME\tSERVER\tUSER")
+				for _, r := range rows {
+					mark := ""
+					if r.Current {
+						mark = "*"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", mark, r.Name, r.Server, r.User)
+				}
+			})
+		},
+	}
+
+	cmd.AddCommand(set, use, list)
+	return cmd
+}
+
+// completeProfiles - дополнение имен профилей
+func (a *app) completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if a.setup() != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for name := range a.config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
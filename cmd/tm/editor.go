@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Строка-разделитель: все, что ниже нее, при разборе отбрасывается.
+const scissors = "# ------------------------ >8 ------------------------"
+
+// Подсказка под разделителем в редактируемом тексте.
+const editorHelp = scissors + `
+# The first line is the task title, everything after it is the description.
+# Everything below the line above is ignored. An empty title aborts.
+`
+
+// formatMessage - текст для редактора: заголовок, пустая строка, описание и подсказка
+func formatMessage(title, content string) string {
+	var b strings.Builder
+	b.WriteString(title)
+	b.WriteString("\n\n")
+	if content != "" {
+		b.WriteString(content)
+		if !strings.HasSuffix(content, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(editorHelp)
+	return b.String()
+}
+
+// parseMessage - разбирает текст из редактора на заголовок и описание
+func parseMessage(text string) (title, content string, err error) {
+	if i := strings.Index(text, scissors); i >= 0 {
+		text = text[:i]
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	title, content, _ = strings.Cut(strings.TrimLeft(text, " \t\n"), "\n")
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", "", errors.New("aborting: empty title")
+	}
+	return title, strings.Trim(content, "\n"), nil
+}
+
+// editText - открывает text в $VISUAL или $EDITOR (по умолчанию vi) и возвращает результат
+func editText(text string) (string, error) {
+	f, err := os.CreateTemp("", "tm-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(text)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// Редактор может быть задан с аргументами, например "code --wait"
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("editor %s: %w", args[0], err)
+	}
+
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// editTask - редактирует заголовок и описание в редакторе
+func editTask(title, content string) (string, string, error) {
+	text, err := editText(formatMessage(title, content))
+	if err != nil {
+		return "", "", err
+	}
+	return parseMessage(text)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"TaskManager/pkg/client"
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// labelCmd - команды работы с метками
+func (a *app) labelCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "label",
+		Aliases: []string{"labels", "l"},
+		Short:   "Manage labels and label tasks",
+	}
+
+	list := &cobra.Command{
+		Use:     "list [task id]",
+		Aliases: []string{"ls"},
+		Short:   "List all labels or the labels of a task",
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var labels []client.Label
+			var err error
+			if len(args) == 0 {
+				labels, err = a.api.AllLabels(cmd.Context())
+			} else {
+				var ids []int
+				ids, err = parseIDs(args)
+				if err == nil {
+					labels, err = a.api.TaskLabels(cmd.Context(), ids[0])
+				}
+			}
+			if err != nil {
+				return err
+			}
+			return a.printLabels(labels)
+		},
+	}
+
+	var color, description string
+	create := &cobra.Command{
+		Use:     "create <name>",
+		Short:   "Create a label, scope::name creates a scoped label",
+		Example: "  tm label create bug --color '#d73a4a'\n  tm label create priority::high",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := a.api.CreateLabel(cmd.Context(), &client.Label{Name: args[0], Color: color, Description: description})
+			if err != nil {
+				return err
+			}
+			return a.printLabels([]client.Label{*l})
+		},
+	}
+	create.Flags().StringVar(&color, "color", "", "label color as #rrggbb")
+	create.Flags().StringVar(&description, "description", "", "label description")
+
+	var scope string
+	usage := &cobra.Command{
+		Use:   "usage",
+		Short: "List labels with the number of tasks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var filter *string
+			if cmd.Flags().Changed("scope") {
+				filter = &scope
+			}
+			usage, err := a.api.LabelsUsage(cmd.Context(), filter)
+			if err != nil {
+				return err
+			}
+			if usage == nil {
+				usage = []client.LabelUsage{}
+			}
+			return a.print(usage, func(w *tabwriter.Writer) {
+				fmt.Fprintln(w, "ID\tNAME\tTASKS\tOPEN")
+				for _, u := range usage {
+					fmt.Fprintf(w, "%d\t%s\t%d\t%d\n", u.ID, u.FullName(), u.Tasks, u.OpenTasks)
+				}
+			})
+		},
+	}
+	usage.Flags().StringVar(&scope, "scope", "", "only labels of the scope, empty for labels without a scope")
+
+	var force bool
+	del := &cobra.Command{
+		Use:               "delete <label>",
+		Aliases:           []string{"rm"},
+		Short:             "Delete a label given by ID or name",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: a.completeLabels,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			labelID, err := a.resolveLabel(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			_, err = a.api.DeleteLabel(cmd.Context(), labelID, force)
+			return err
+		},
+	}
+	del.Flags().BoolVar(&force, "force", false, "remove the label from its tasks before deleting it")
+
+	var dryRun bool
+	merge := &cobra.Command{
+		Use:               "merge <target> <source>...",
+		Short:             "Move tasks from source labels to the target label and delete the sources",
+		Example:           "  tm label merge bug Bug BUG\n  tm label merge 3 7 9 --dry-run",
+		Args:              cobra.MinimumNArgs(2),
+		ValidArgsFunction: a.completeLabels,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids := make([]int, len(args))
+			for i, ref := range args {
+				id, err := a.resolveLabel(cmd.Context(), ref)
+				if err != nil {
+					return err
+				}
+				ids[i] = id
+			}
+			report, err := a.api.MergeLabels(cmd.Context(), ids[0], ids[1:], dryRun)
+			if err != nil {
+				return err
+			}
+			return a.print(report, func(w *tabwriter.Writer) {
+				for _, l := range report.Sources {
+					fmt.Fprintf(w, "%d\t%s\t-> %s\n", l.ID, l.FullName(), report.Target.FullName())
+				}
+				fmt.Fprintf(w, "tasks:\t%d\t(%d got the target label)\n", report.Tasks, report.Moved)
+				if report.DryRun {
+					fmt.Fprintln(w, "dry run, nothing saved")
+				}
+			})
+		},
+	}
+	merge.Flags().BoolVar(&dryRun, "dry-run", false, "show what would change without saving")
+
+	duplicates := &cobra.Command{
+		Use:   "duplicates",
+		Short: "List labels whose names differ only in case",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			groups, err := a.api.DuplicateLabels(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if groups == nil {
+				groups = []client.DuplicateLabels{}
+			}
+			return a.print(groups, func(w *tabwriter.Writer) {
+				fmt.Fprintln(w, "ID\tNAME\tTASKS\tOPEN")
+				for _, g := range groups {
+					for _, l := range g.Labels {
+						fmt.Fprintf(w, "%d\t%s\t%d\t%d\n", l.ID, l.FullName(), l.Tasks, l.OpenTasks)
+					}
+					fmt.Fprintln(w)
+				}
+			})
+		},
+	}
+
+	var createMissing bool
+	add := &cobra.Command{
+		Use:               "add <task id> <label>...",
+		Short:             "Add labels given by ID or name to a task",
+		Example:           "  tm label add 42 backend\n  tm label add 42 bug urgent --create",
+		Args:              cobra.MinimumNArgs(2),
+		ValidArgsFunction: a.completeTaskLabels,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.changeTaskLabels(cmd.Context(), args, createMissing, a.api.AddTaskLabel)
+		},
+	}
+	add.Flags().BoolVar(&createMissing, "create", false, "create labels that do not exist")
+
+	remove := &cobra.Command{
+		Use:               "remove <task id> <label>...",
+		Short:             "Remove labels given by ID or name from a task",
+		Args:              cobra.MinimumNArgs(2),
+		ValidArgsFunction: a.completeTaskLabels,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.changeTaskLabels(cmd.Context(), args, false, a.api.RemoveTaskLabel)
+		},
+	}
+
+	cmd.AddCommand(list, create, usage, del, merge, duplicates, add, remove)
+	return cmd
+}
+
+// changeTaskLabels - добавляет или удаляет метки задачи, args - ID задачи и метки.
+// При createMissing отсутствующие метки создаются
+func (a *app) changeTaskLabels(ctx context.Context, args []string, createMissing bool,
+	change func(ctx context.Context, taskID, labelID int) (*client.Task, error)) error {
+	ids, err := parseIDs(args[:1])
+	if err != nil {
+		return err
+	}
+	taskID := ids[0]
+
+	for _, ref := range args[1:] {
+		labelID, err := a.resolveLabel(ctx, ref)
+		if err != nil && createMissing {
+			var l *client.Label
+			l, err = a.api.CreateLabel(ctx, &client.Label{Name: ref})
+			if err == nil {
+				labelID = l.ID
+			}
+		}
+		if err != nil {
+			return err
+		}
+		_, err = change(ctx, taskID, labelID)
+		if err != nil {
+			return err
+		}
+	}
+
+	labels, err := a.api.TaskLabels(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	return a.printLabels(labels)
+}
+
+// printLabels - выводит список меток
+func (a *app) printLabels(labels []client.Label) error {
+	if labels == nil {
+		labels = []client.Label{}
+	}
+	return a.print(labels, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tCOLOR\tDESCRIPTION")
+		for _, l := range labels {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", l.ID, l.FullName(), l.Color, l.Description)
+		}
+	})
+}
+
+// completeLabels - дополнение названий меток
+func (a *app) completeLabels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if a.setup() != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	labels, err := a.api.AllLabels(cmd.Context())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	names := make([]string, 0, len(labels))
+	for _, l := range labels {
+		names = append(names, l.FullName())
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTaskLabels - дополнение аргументов add и remove: сначала ID задачи, затем метки
+func (a *app) completeTaskLabels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return a.completeLabels(cmd, args, toComplete)
+}
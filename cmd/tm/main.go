@@ -0,0 +1,54 @@
+// tm - консольный клиент TaskManager, работает с сервером через HTTP API.
+//
+//	tm task list --assignee me --label bug
+//	tm task create --title "Fix login" --label bug
+//	tm task close 42
+//	tm label add 42 backend
+//	tm user list
+//
+// Адрес сервера, токен и пользователь для "me" берутся из профиля в файле настроек
+// (tm config set), переменных окружения TM_SERVER, TM_TOKEN, TM_PROFILE или флагов.
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func main() {
+	a := &app{out: os.Stdout}
+	err := a.rootCmd().Execute()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// rootCmd - корневая команда с общими флагами и всеми подкомандами
+func (a *app) rootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "tm",
+		Short:        "Command-line client for the task manager",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return a.setup()
+		},
+	}
+
+	f := root.PersistentFlags()
+	f.StringVar(&a.flags.config, "config", os.Getenv("TM_CONFIG"), "config file (default $XDG_CONFIG_HOME/tm/config.yaml)")
+	f.StringVar(&a.flags.profile, "profile", os.Getenv("TM_PROFILE"), "profile from the config file")
+	f.StringVar(&a.flags.server, "server", os.Getenv("TM_SERVER"), "server URL, overrides the profile")
+	f.StringVar(&a.flags.token, "token", os.Getenv("TM_TOKEN"), "bearer token, overrides the profile")
+	f.StringVarP(&a.flags.output, "output", "o", formatTable, "output format: table, json or yaml")
+	root.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(outputFormats, cobra.ShellCompDirectiveNoFileComp))
+	root.RegisterFlagCompletionFunc("profile", a.completeProfiles)
+
+	root.AddCommand(
+		a.taskCmd(),
+		a.labelCmd(),
+		a.userCmd(),
+		a.configCmd(),
+	)
+	return root
+}
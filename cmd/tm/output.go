@@ -0,0 +1,74 @@
+package main
+
+import (
+	"TaskManager/pkg/client"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Форматы вывода.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+var outputFormats = []string{formatTable, formatJSON, formatYAML}
+
+func validFormat(f string) bool {
+	return slices.Contains(outputFormats, f)
+}
+
+// print - выводит v в выбранном формате, для таблицы вызывает table
+func (a *app) print(v any, table func(w *tabwriter.Writer)) error {
+	switch a.flags.output {
+	case formatJSON:
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case formatYAML:
+		enc := yaml.NewEncoder(a.out)
+		enc.SetIndent(2)
+		err := enc.Encode(v)
+		if err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// formatTime - дата и время в локальной зоне, 0 - пустая строка
+func formatTime(unix int64) string {
+	if unix == 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).Local().Format("2006-01-02 15:04")
+}
+
+// Названия приоритетов, индекс - значение Task.Priority.
+var priorities = []string{"", "high", "medium", "low"}
+
+// formatPriority - название приоритета
+func formatPriority(p int) string {
+	if p >= 0 && p < len(priorities) {
+		return priorities[p]
+	}
+	return fmt.Sprint(p)
+}
+
+// taskState - open или closed
+func taskState(t *client.Task) string {
+	if t.Closed != 0 {
+		return "closed"
+	}
+	return "open"
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// resolveUser - ID пользователя по ссылке: "me" (пользователь профиля), число или имя
+func (a *app) resolveUser(ctx context.Context, ref string) (int, error) {
+	if ref == "me" {
+		if a.profile.User == "" || a.profile.User == "me" {
+			return 0, errors.New(`"me" is not set for this profile, run: tm config set --user <id or name>`)
+		}
+		ref = a.profile.User
+	}
+	if n, err := strconv.Atoi(ref); err == nil {
+		return n, nil
+	}
+
+	users, err := a.api.AllUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var found []int
+	for _, u := range users {
+		if strings.EqualFold(u.Name, ref) {
+			found = append(found, u.ID)
+		}
+	}
+	return pick("user", ref, found)
+}
+
+// resolveLabel - ID метки по ссылке: число или имя. Точное совпадение имени важнее
+// совпадения без учета регистра, чтобы метки bug и Bug можно было различить
+func (a *app) resolveLabel(ctx context.Context, ref string) (int, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		return n, nil
+	}
+
+	labels, err := a.api.AllLabels(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var found, exact []int
+	for _, l := range labels {
+		if strings.EqualFold(l.FullName(), ref) {
+			found = append(found, l.ID)
+		}
+		if l.FullName() == ref {
+			exact = append(exact, l.ID)
+		}
+	}
+	if len(exact) == 1 {
+		return exact[0], nil
+	}
+	return pick("label", ref, found)
+}
+
+// pick - единственный найденный ID, иначе ошибка
+func pick(kind, ref string, found []int) (int, error) {
+	switch len(found) {
+	case 0:
+		return 0, fmt.Errorf("%s %q not found", kind, ref)
+	case 1:
+		return found[0], nil
+	}
+	return 0, fmt.Errorf("%s %q is ambiguous: %d matches, use the ID", kind, ref, len(found))
+}
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"TaskManager/pkg/client"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Форматы сроков выполнения в локальной зоне.
+var dueLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	time.RFC3339,
+}
+
+// parseDue - срок выполнения в unix time, "none" снимает срок
+func parseDue(v string) (int64, error) {
+	if v == "none" {
+		return 0, nil
+	}
+	for _, layout := range dueLayouts {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid due date %q, expected YYYY-MM-DD, \"YYYY-MM-DD HH:MM\", RFC 3339 or none", v)
+}
+
+// parsePriority - приоритет по названию или числу от 0 до 3
+func parsePriority(v string) (int, error) {
+	if v == "none" {
+		return 0, nil
+	}
+	for i, name := range priorities {
+		if name != "" && strings.EqualFold(v, name) {
+			return i, nil
+		}
+	}
+	p, err := strconv.Atoi(v)
+	if err != nil || p < 0 || p >= len(priorities) {
+		return 0, fmt.Errorf("invalid priority %q, expected high, medium, low, none or 0-3", v)
+	}
+	return p, nil
+}
+
+// parseIDs - ID из аргументов команды
+func parseIDs(args []string) ([]int, error) {
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid task ID %q", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// taskFilter - фильтр списка задач, пользователи и метки заданы как в командной строке
+type taskFilter struct {
+	assignee string
+	author   string
+	labels   []string
+	// open, closed или all
+	state string
+}
+
+// listTasks - задачи, подходящие под фильтр, по возрастанию ID.
+// Для нескольких меток берется пересечение задач каждой из них
+func (a *app) listTasks(ctx context.Context, f taskFilter) ([]client.Task, error) {
+	var assigneeID, authorID int
+	var err error
+	if f.assignee != "" {
+		assigneeID, err = a.resolveUser(ctx, f.assignee)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.author != "" {
+		authorID, err = a.resolveUser(ctx, f.author)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tasks []client.Task
+	if len(f.labels) == 0 {
+		tasks, err = a.api.AllTasks(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, ref := range f.labels {
+		labelID, err := a.resolveLabel(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		labelTasks, err := a.api.TasksByLabel(ctx, labelID)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			tasks = labelTasks
+			continue
+		}
+		found := make(map[int]bool, len(labelTasks))
+		for _, t := range labelTasks {
+			found[t.ID] = true
+		}
+		tasks = keep(tasks, func(t *client.Task) bool { return found[t.ID] })
+	}
+
+	tasks = keep(tasks, func(t *client.Task) bool {
+		switch {
+		case assigneeID != 0 && t.AssignedID != assigneeID:
+			return false
+		case authorID != 0 && t.AuthorID != authorID:
+			return false
+		case f.state == "open" && t.Closed != 0:
+			return false
+		case f.state == "closed" && t.Closed == 0:
+			return false
+		}
+		return true
+	})
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks, nil
+}
+
+// keep - оставляет задачи, для которых ok возвращает true
+func keep(tasks []client.Task, ok func(t *client.Task) bool) []client.Task {
+	result := tasks[:0]
+	for i := range tasks {
+		if ok(&tasks[i]) {
+			result = append(result, tasks[i])
+		}
+	}
+	return result
+}
+
+// userNames - имена пользователей по ID для вывода в таблицах
+func (a *app) userNames(ctx context.Context) (map[int]string, error) {
+	users, err := a.api.AllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Name
+	}
+	return names, nil
+}
+
+// printTasks - выводит список задач, в таблице вместо ID исполнителей - имена
+func (a *app) printTasks(ctx context.Context, tasks []client.Task) error {
+	if tasks == nil {
+		tasks = []client.Task{}
+	}
+	var names map[int]string
+	if a.flags.output == formatTable {
+		var err error
+		names, err = a.userNames(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return a.print(tasks, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tSTATE\tPRIORITY\tDUE\tASSIGNEE\tTITLE")
+		for _, t := range tasks {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+				t.ID, taskState(&t), formatPriority(t.Priority), formatTime(t.Due), names[t.AssignedID], t.Title)
+		}
+	})
+}
+
+// printTask - выводит задачу, в таблице - подробно, с метками и описанием
+func (a *app) printTask(ctx context.Context, t *client.Task) error {
+	if a.flags.output != formatTable {
+		return a.print(t, nil)
+	}
+	names, err := a.userNames(ctx)
+	if err != nil {
+		return err
+	}
+	labels, err := a.api.TaskLabels(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	labelNames := make([]string, 0, len(labels))
+	for _, l := range labels {
+		labelNames = append(labelNames, l.FullName())
+	}
+
+	return a.print(t, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "ID:\t%d\n", t.ID)
+		fmt.Fprintf(w, "Title:\t%s\n", t.Title)
+		fmt.Fprintf(w, "State:\t%s\n", taskState(t))
+		fmt.Fprintf(w, "Priority:\t%s\n", formatPriority(t.Priority))
+		fmt.Fprintf(w, "Due:\t%s\n", formatTime(t.Due))
+		fmt.Fprintf(w, "Author:\t%s\n", names[t.AuthorID])
+		fmt.Fprintf(w, "Assignee:\t%s\n", names[t.AssignedID])
+		fmt.Fprintf(w, "Labels:\t%s\n", strings.Join(labelNames, ", "))
+		fmt.Fprintf(w, "Opened:\t%s\n", formatTime(t.Opened))
+		if t.Closed != 0 {
+			fmt.Fprintf(w, "Closed:\t%s\n", formatTime(t.Closed))
+		}
+		if t.Content != "" {
+			fmt.Fprintf(w, "\n%s\n", t.Content)
+		}
+	})
+}
+
+// taskCmd - команды работы с задачами
+func (a *app) taskCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "task",
+		Aliases: []string{"tasks", "t"},
+		Short:   "Manage tasks",
+	}
+	cmd.AddCommand(
+		a.taskListCmd(),
+		a.taskGetCmd(),
+		a.taskCreateCmd(),
+		a.taskEditCmd(),
+		a.taskStateCmd("close", "Close tasks", true),
+		a.taskStateCmd("reopen", "Reopen closed tasks", false),
+		a.taskAssignCmd(),
+		a.taskUnassignCmd(),
+		a.taskDeleteCmd(),
+	)
+	return cmd
+}
+
+func (a *app) taskListCmd() *cobra.Command {
+	var f taskFilter
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List tasks",
+		Example: "  tm task list --assignee me --label bug\n  tm task list --state all -o json",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if f.state != "open" && f.state != "closed" && f.state != "all" {
+				return fmt.Errorf("invalid state %q, expected open, closed or all", f.state)
+			}
+			tasks, err := a.listTasks(cmd.Context(), f)
+			if err != nil {
+				return err
+			}
+			return a.printTasks(cmd.Context(), tasks)
+		},
+	}
+	cmd.Flags().StringVar(&f.assignee, "assignee", "", `assignee ID, name or "me"`)
+	cmd.Flags().StringVar(&f.author, "author", "", `author ID, name or "me"`)
+	cmd.Flags().StringSliceVar(&f.labels, "label", nil, "label ID or name, repeat to require several")
+	cmd.Flags().StringVar(&f.state, "state", "open", "open, closed or all")
+	cmd.RegisterFlagCompletionFunc("assignee", a.completeUsers)
+	cmd.RegisterFlagCompletionFunc("author", a.completeUsers)
+	cmd.RegisterFlagCompletionFunc("label", a.completeLabels)
+	cmd.RegisterFlagCompletionFunc("state", cobra.FixedCompletions([]string{"open", "closed", "all"}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func (a *app) taskGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "get <id>",
+		Aliases: []string{"show"},
+		Short:   "Show a task",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			t, err := a.api.Task(cmd.Context(), ids[0])
+			if err != nil {
+				return err
+			}
+			return a.printTask(cmd.Context(), t)
+		},
+	}
+}
+
+// taskFields - поля задачи, задаваемые флагами create и edit
+type taskFields struct {
+	title    string
+	content  string
+	due      string
+	priority string
+	edit     bool
+}
+
+func (f *taskFields) register(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&f.title, "title", "t", "", "task title")
+	cmd.Flags().StringVarP(&f.content, "content", "c", "", "task description")
+	cmd.Flags().StringVar(&f.due, "due", "", `due date: YYYY-MM-DD, "YYYY-MM-DD HH:MM", RFC 3339 or none`)
+	cmd.Flags().StringVarP(&f.priority, "priority", "p", "", "priority: high, medium, low or none")
+	cmd.Flags().BoolVarP(&f.edit, "edit", "e", false, "edit the title and description in $EDITOR")
+	cmd.RegisterFlagCompletionFunc("priority", cobra.FixedCompletions([]string{"high", "medium", "low", "none"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// changed - задан ли хотя бы один флаг полей задачи
+func (f *taskFields) changed(cmd *cobra.Command) bool {
+	for _, name := range []string{"title", "content", "due", "priority"} {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply - переносит измененные флаги в задачу
+func (f *taskFields) apply(cmd *cobra.Command, t *client.Task) error {
+	flags := cmd.Flags()
+	if flags.Changed("title") {
+		t.Title = f.title
+	}
+	if flags.Changed("content") {
+		t.Content = f.content
+	}
+	if flags.Changed("due") {
+		due, err := parseDue(f.due)
+		if err != nil {
+			return err
+		}
+		t.Due = due
+	}
+	if flags.Changed("priority") {
+		p, err := parsePriority(f.priority)
+		if err != nil {
+			return err
+		}
+		t.Priority = p
+	}
+	return nil
+}
+
+func (a *app) taskCreateCmd() *cobra.Command {
+	var fields taskFields
+	var assignee string
+	var labels []string
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a task",
+		Long:  "Create a task. Without --title the title and description are written in $EDITOR.",
+		Example: "  tm task create --title \"Fix login\" --label bug --assignee me --due 2024-06-01\n" +
+			"  tm task create --priority high",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			t := &client.Task{}
+			err := fields.apply(cmd, t)
+			if err != nil {
+				return err
+			}
+			if fields.edit || t.Title == "" {
+				t.Title, t.Content, err = editTask(t.Title, t.Content)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Ссылки проверяются до создания, чтобы не оставить задачу без исполнителя или меток
+			var userID int
+			if assignee != "" {
+				userID, err = a.resolveUser(ctx, assignee)
+				if err != nil {
+					return err
+				}
+			}
+			labelIDs := make([]int, 0, len(labels))
+			for _, ref := range labels {
+				labelID, err := a.resolveLabel(ctx, ref)
+				if err != nil {
+					return err
+				}
+				labelIDs = append(labelIDs, labelID)
+			}
+
+			t, err = a.api.CreateTask(ctx, t)
+			if err != nil {
+				return err
+			}
+			if userID != 0 {
+				_, err = a.api.AssignTask(ctx, t.ID, userID)
+				if err != nil {
+					return fmt.Errorf("task %d created: %w", t.ID, err)
+				}
+			}
+			for _, labelID := range labelIDs {
+				_, err = a.api.AddTaskLabel(ctx, t.ID, labelID)
+				if err != nil {
+					return fmt.Errorf("task %d created: %w", t.ID, err)
+				}
+			}
+
+			t, err = a.api.Task(ctx, t.ID)
+			if err != nil {
+				return err
+			}
+			return a.printTask(ctx, t)
+		},
+	}
+	fields.register(cmd)
+	cmd.Flags().StringVarP(&assignee, "assignee", "a", "", `assignee ID, name or "me"`)
+	cmd.Flags().StringSliceVarP(&labels, "label", "l", nil, "label ID or name, repeat to add several")
+	cmd.RegisterFlagCompletionFunc("assignee", a.completeUsers)
+	cmd.RegisterFlagCompletionFunc("label", a.completeLabels)
+	return cmd
+}
+
+func (a *app) taskEditCmd() *cobra.Command {
+	var fields taskFields
+	cmd := &cobra.Command{
+		Use:   "edit <id>",
+		Short: "Edit a task",
+		Long:  "Edit a task. Without flags the title and description are edited in $EDITOR.",
+		Example: "  tm task edit 42\n" +
+			"  tm task edit 42 --priority low --due none",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			t, err := a.api.Task(ctx, ids[0])
+			if err != nil {
+				return err
+			}
+			err = fields.apply(cmd, t)
+			if err != nil {
+				return err
+			}
+			if fields.edit || !fields.changed(cmd) {
+				t.Title, t.Content, err = editTask(t.Title, t.Content)
+				if err != nil {
+					return err
+				}
+			}
+
+			t, err = a.api.UpdateTask(ctx, t)
+			if err != nil {
+				return err
+			}
+			return a.printTask(ctx, t)
+		},
+	}
+	fields.register(cmd)
+	return cmd
+}
+
+// taskStateCmd - закрытие или повторное открытие задач
+func (a *app) taskStateCmd(use, short string, closing bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <id>...",
+		Short: short,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			tasks := make([]client.Task, 0, len(ids))
+			for _, id := range ids {
+				t, err := a.api.Task(ctx, id)
+				if err != nil {
+					return err
+				}
+				switch {
+				case closing && t.Closed == 0:
+					t.Closed = time.Now().Unix()
+				case !closing && t.Closed != 0:
+					t.Closed = 0
+				default:
+					tasks = append(tasks, *t)
+					continue
+				}
+				t, err = a.api.UpdateTask(ctx, t)
+				if err != nil {
+					return err
+				}
+				tasks = append(tasks, *t)
+			}
+			return a.printTasks(ctx, tasks)
+		},
+	}
+}
+
+func (a *app) taskAssignCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "assign <id> <user>",
+		Short: `Assign a task to a user given by ID, name or "me"`,
+		Args:  cobra.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 1 {
+				return a.completeUsers(cmd, args, toComplete)
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args[:1])
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			userID, err := a.resolveUser(ctx, args[1])
+			if err != nil {
+				return err
+			}
+			t, err := a.api.AssignTask(ctx, ids[0], userID)
+			if err != nil {
+				return err
+			}
+			return a.printTasks(ctx, []client.Task{*t})
+		},
+	}
+}
+
+func (a *app) taskUnassignCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unassign <id>...",
+		Short: "Remove the assignee from tasks",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			tasks := make([]client.Task, 0, len(ids))
+			for _, id := range ids {
+				t, err := a.api.AssignTask(ctx, id, 0)
+				if err != nil {
+					return err
+				}
+				tasks = append(tasks, *t)
+			}
+			return a.printTasks(ctx, tasks)
+		},
+	}
+}
+
+func (a *app) taskDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "delete <id>...",
+		Aliases: []string{"rm"},
+		Short:   "Delete tasks",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				_, err = a.api.DeleteTask(cmd.Context(), id)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
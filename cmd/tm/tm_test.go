@@ -0,0 +1,233 @@
+package main
+
+import (
+	"TaskManager/pkg/client"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testServer - сервер с фиксированными пользователями, метками и задачами
+func testServer(t *testing.T) *httptest.Server {
+	users := []client.User{{ID: 1, Name: "alice"}, {ID: 2, Name: "bob"}}
+	labels := []client.Label{{ID: 10, Name: "bug"}, {ID: 11, Name: "backend"}}
+	tasks := []client.Task{
+		{ID: 3, Title: "c", AuthorID: 2, AssignedID: 1},
+		{ID: 1, Title: "a", AuthorID: 1, AssignedID: 1},
+		{ID: 2, Title: "b", AuthorID: 1, AssignedID: 2},
+		{ID: 4, Title: "d", AuthorID: 1, AssignedID: 1, Closed: 100},
+	}
+	byLabel := map[string][]client.Task{
+		"10": {tasks[0], tasks[1], tasks[3]},
+		"11": {tasks[1], tasks[2]},
+	}
+
+	mux := http.NewServeMux()
+	write := func(w http.ResponseWriter, v any) {
+		json.NewEncoder(w).Encode(v)
+	}
+	mux.HandleFunc("/allusers", func(w http.ResponseWriter, r *http.Request) { write(w, users) })
+	mux.HandleFunc("/alllabels", func(w http.ResponseWriter, r *http.Request) { write(w, labels) })
+	mux.HandleFunc("/alltasks", func(w http.ResponseWriter, r *http.Request) { write(w, tasks) })
+	mux.HandleFunc("/taskbylabel", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "no token", http.StatusUnauthorized)
+			return
+		}
+		found, ok := byLabel[r.URL.Query().Get("id")]
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		write(w, found)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// run - выполняет команду tm и возвращает ее вывод
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	a := &app{out: &out}
+	root := a.rootCmd()
+	root.SetArgs(args)
+	root.SetOut(&out)
+	root.SetErr(&out)
+	err := root.Execute()
+	return out.String(), err
+}
+
+func TestTaskList(t *testing.T) {
+	srv := testServer(t)
+	config := filepath.Join(t.TempDir(), "config.yaml")
+
+	_, err := run(t, "--config", config, "config", "set", "work", "--server", srv.URL, "--token", "secret", "--user", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		ids  []int
+	}{
+		{[]string{"task", "list"}, []int{1, 2, 3}},
+		{[]string{"task", "list", "--state", "all"}, []int{1, 2, 3, 4}},
+		{[]string{"task", "list", "--state", "closed"}, []int{4}},
+		{[]string{"task", "list", "--assignee", "me"}, []int{1, 3}},
+		{[]string{"task", "list", "--author", "bob"}, []int{3}},
+		{[]string{"task", "list", "--label", "bug", "--assignee", "1"}, []int{1, 3}},
+		{[]string{"task", "list", "--label", "bug", "--label", "Backend"}, []int{1}},
+		{[]string{"task", "list", "--label", "12"}, []int{}},
+	}
+	for _, tt := range tests {
+		out, err := run(t, append([]string{"--config", config, "-o", "json"}, tt.args...)...)
+		if err != nil {
+			t.Errorf("%v: %v", tt.args, err)
+			continue
+		}
+		var tasks []client.Task
+		err = json.Unmarshal([]byte(out), &tasks)
+		if err != nil {
+			t.Errorf("%v: %v: %s", tt.args, err, out)
+			continue
+		}
+		ids := []int{}
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		if got, want := jsonString(ids), jsonString(tt.ids); got != want {
+			t.Errorf("%v: получены задачи %s, ожидались %s", tt.args, got, want)
+		}
+	}
+
+	_, err = run(t, "--config", config, "task", "list", "--label", "nope")
+	if err == nil || !strings.Contains(err.Error(), `label "nope" not found`) {
+		t.Errorf("ожидалась ошибка неизвестной метки, получено %v", err)
+	}
+}
+
+func jsonString(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestTaskTable(t *testing.T) {
+	srv := testServer(t)
+	out, err := run(t, "--config", filepath.Join(t.TempDir(), "none.yaml"), "--server", srv.URL, "task", "list", "--author", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[2], "bob") {
+		t.Errorf("неверная таблица:\n%s", out)
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "tm", "config.yaml")
+	steps := [][]string{
+		{"config", "set", "--server", "http://one"},
+		{"config", "set", "two", "--server", "http://two", "--token", "t"},
+		{"config", "use", "two"},
+	}
+	for _, args := range steps {
+		_, err := run(t, append([]string{"--config", config}, args...)...)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	info, err := os.Stat(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("права файла настроек %v, ожидалось 0600", info.Mode().Perm())
+	}
+
+	a := &app{}
+	a.flags.config = config
+	a.flags.output = formatTable
+	err = a.setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.profile.Server != "http://two" || a.profile.Token != "t" {
+		t.Errorf("выбран неверный профиль: %+v", a.profile)
+	}
+
+	a = &app{}
+	a.flags.config = config
+	a.flags.output = formatTable
+	a.flags.profile = defaultProfile
+	a.flags.token = "flag"
+	err = a.setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.profile.Server != "http://one" || a.profile.Token != "flag" {
+		t.Errorf("флаги не переопределили профиль: %+v", a.profile)
+	}
+
+	_, err = run(t, "--config", config, "config", "use", "three")
+	if err == nil {
+		t.Error("ожидалась ошибка для несуществующего профиля")
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		title, content string
+	}{
+		{"Title", ""},
+		{"Title", "Line one\n\n# Markdown header\nLine two"},
+	}
+	for _, tt := range tests {
+		title, content, err := parseMessage(formatMessage(tt.title, tt.content))
+		if err != nil || title != tt.title || content != tt.content {
+			t.Errorf("parseMessage(formatMessage(%q, %q)) = %q, %q, %v", tt.title, tt.content, title, content, err)
+		}
+	}
+
+	title, content, err := parseMessage("\n  New title \r\nbody\r\n" + editorHelp)
+	if err != nil || title != "New title" || content != "body" {
+		t.Errorf("parseMessage = %q, %q, %v", title, content, err)
+	}
+
+	_, _, err = parseMessage("\n\n" + editorHelp)
+	if err == nil {
+		t.Error("ожидалась ошибка для пустого заголовка")
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	for v, want := range map[string]int{"high": 1, "Medium": 2, "3": 3, "none": 0} {
+		if got, err := parsePriority(v); err != nil || got != want {
+			t.Errorf("parsePriority(%q) = %d, %v", v, got, err)
+		}
+	}
+	for _, v := range []string{"urgent", "4", "-1"} {
+		if _, err := parsePriority(v); err == nil {
+			t.Errorf("parsePriority(%q): ожидалась ошибка", v)
+		}
+	}
+
+	for _, v := range []string{"2024-06-01", "2024-06-01 10:30", "2024-06-01T10:30:00Z"} {
+		if due, err := parseDue(v); err != nil || due == 0 {
+			t.Errorf("parseDue(%q) = %d, %v", v, due, err)
+		}
+	}
+	if due, err := parseDue("none"); err != nil || due != 0 {
+		t.Errorf("parseDue(none) = %d, %v", due, err)
+	}
+	if _, err := parseDue("tomorrow"); err == nil {
+		t.Error("parseDue(tomorrow): ожидалась ошибка")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"TaskManager/pkg/client"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// userCmd - команды работы с пользователями
+func (a *app) userCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "user",
+		Aliases: []string{"users", "u"},
+		Short:   "Manage users",
+	}
+
+	list := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List users",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			users, err := a.api.AllUsers(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return a.printUsers(users)
+		},
+	}
+
+	var email, locale string
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			u, err := a.api.CreateUser(cmd.Context(), &client.User{Name: args[0], Email: email, Locale: locale})
+			if err != nil {
+				return err
+			}
+			return a.printUsers([]client.User{*u})
+		},
+	}
+	create.Flags().StringVar(&email, "email", "", "email address for notifications")
+	create.Flags().StringVar(&locale, "locale", "", "email language: ru or en")
+
+	var to string
+	var dryRun bool
+	offboard := &cobra.Command{
+		Use:   "offboard <user>",
+		Short: "Deactivate a user and reassign their open tasks",
+		Long: "Deactivate a user. Open tasks assigned to the user move to --to or stay unassigned,\n" +
+			"closed tasks and authorship are kept.",
+		Example:           "  tm user offboard alice --to bob --dry-run\n  tm user offboard 7",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: a.completeUsers,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			id, err := a.resolveUser(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			var toID int
+			if to != "" {
+				toID, err = a.resolveUser(ctx, to)
+				if err != nil {
+					return err
+				}
+			}
+			report, err := a.api.OffboardUser(ctx, id, toID, dryRun)
+			if err != nil {
+				return err
+			}
+			assignee := "nobody"
+			if report.ReassignTo != nil {
+				assignee = report.ReassignTo.Name
+			}
+			return a.print(report, func(w *tabwriter.Writer) {
+				fmt.Fprintf(w, "user:\t%d\t%s\tdeactivated %s\n", report.User.ID, report.User.Name, formatTime(report.User.Deactivated))
+				for _, t := range report.Reassigned {
+					fmt.Fprintf(w, "%d\t%s\t-> %s\n", t.ID, t.Title, assignee)
+				}
+				fmt.Fprintf(w, "kept:\t%d closed assigned\t%d authored\n", report.ClosedAssigned, report.Authored)
+				if report.DryRun {
+					fmt.Fprintln(w, "dry run, nothing saved")
+				}
+			})
+		},
+	}
+	offboard.Flags().StringVar(&to, "to", "", `new assignee ID, name or "me"; without it tasks stay unassigned`)
+	offboard.Flags().BoolVar(&dryRun, "dry-run", false, "show what would change without saving")
+	offboard.RegisterFlagCompletionFunc("to", a.completeUsers)
+
+	cmd.AddCommand(list, create, offboard)
+	return cmd
+}
+
+// printUsers - выводит список пользователей
+func (a *app) printUsers(users []client.User) error {
+	if users == nil {
+		users = []client.User{}
+	}
+	return a.print(users, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tEMAIL\tDEACTIVATED")
+		for _, u := range users {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", u.ID, u.Name, u.Email, formatTime(u.Deactivated))
+		}
+	})
+}
+
+// completeUsers - дополнение имен пользователей и "me"
+func (a *app) completeUsers(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if a.setup() != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	users, err := a.api.AllUsers(cmd.Context())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	names := []string{"me"}
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,314 @@
+// Package accessLog - ID запроса и журнал доступа HTTP сервиса.
+package accessLog
+
+import (
+	"TaskManager/pkg/logger"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Форматы журнала доступа.
+const (
+	// Запись журнала сервиса с полями, в формате, выбранном для журнала (text или json)
+	FormatStructured = "structured"
+	// Строка в формате Apache Combined Log Format
+	FormatCombined = "combined"
+	// Журнал доступа отключен, ID запроса по-прежнему назначается
+	FormatOff = "off"
+)
+
+// Config - настройки журнала доступа.
+type Config struct {
+	// Формат: structured, combined или off
+	Format string
+	// Куда писать строки формата combined, по умолчанию stdout
+	Writer io.Writer
+	// Адреса прокси, которым доверяются заголовки X-Forwarded-For и X-Real-IP
+	TrustedProxies []netip.Prefix
+}
+
+// ParseProxies - разбирает список адресов и подсетей через запятую: "10.0.0.0/8,127.0.0.1"
+func ParseProxies(s string) ([]netip.Prefix, error) {
+	var result []netip.Prefix
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, prefix.Masked())
+	}
+	return result, nil
+}
+
+// AccessLog - промежуточный обработчик: ID запроса и журнал доступа.
+type AccessLog struct {
+	config Config
+	mu     sync.Mutex
+}
+
+// New - конструктор
+func New(config Config) (*AccessLog, error) {
+	switch config.Format {
+	case FormatStructured, FormatCombined, FormatOff:
+	case "":
+		config.Format = FormatStructured
+	default:
+		return nil, fmt.Errorf("неизвестный формат журнала доступа: %s", config.Format)
+	}
+	if config.Writer == nil {
+		config.Writer = os.Stdout
+	}
+	return &AccessLog{config: config}, nil
+}
+
+// entry - сведения о запросе, которые обработчики дополняют по ходу обработки.
+type entry struct {
+	mu       sync.Mutex
+	user     string
+	clientIP string
+}
+
+type entryKey struct{}
+
+// SetUser - запоминает пользователя запроса для журнала доступа: имя, ID или "admin"
+func SetUser(ctx context.Context, user string) {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		e.mu.Lock()
+		e.user = user
+		e.mu.Unlock()
+	}
+}
+
+// User - пользователь запроса, заданный SetUser
+func User(ctx context.Context) string {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return e.user
+	}
+	return ""
+}
+
+// ClientIP - адрес клиента с учетом заголовков доверенных прокси
+func ClientIP(ctx context.Context) string {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		return e.clientIP
+	}
+	return ""
+}
+
+// Middleware - назначает запросу ID (из заголовка X-Request-ID или новый), добавляет его
+// в контекст, в заголовок ответа, во все записи журнала с контекстом запроса и в текст ошибок,
+// и после ответа пишет строку журнала доступа
+func (a *AccessLog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		e := &entry{clientIP: a.clientIP(r)}
+		ctx := WithRequestID(r.Context(), id)
+		ctx = context.WithValue(ctx, entryKey{}, e)
+		ctx = logger.With(ctx, "request_id", id)
+		r = r.WithContext(ctx)
+		w.Header().Set(RequestIDHeader, id)
+
+		rw := &responseWriter{ResponseWriter: w, requestID: id}
+		next.ServeHTTP(rw, r)
+
+		if a.config.Format == FormatOff {
+			return
+		}
+		a.write(r, rw, e, time.Since(start))
+	})
+}
+
+// write - пишет строку журнала доступа
+func (a *AccessLog) write(r *http.Request, rw *responseWriter, e *entry, d time.Duration) {
+	route := "unmatched"
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			route = tpl
+		}
+	}
+	user := User(r.Context())
+
+	if a.config.Format == FormatCombined {
+		if user == "" {
+			user = "-"
+		}
+		referer, agent := r.Referer(), r.UserAgent()
+		line := fmt.Sprintf("%s - %s [%s] %q %d %d %q %q %s %s %.3f\n",
+			e.clientIP, user, time.Now().Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method+" "+r.URL.RequestURI()+" "+r.Proto, rw.status(), rw.bytes,
+			referer, agent, RequestID(r.Context()), route, d.Seconds())
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		_, _ = io.WriteString(a.config.Writer, line)
+		return
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case rw.status() >= 500:
+		level = slog.LevelError
+	case rw.status() >= 400:
+		level = slog.LevelWarn
+	}
+	logger.Logger().LogAttrs(r.Context(), level, "request",
+		slog.String("method", r.Method),
+		slog.String("route", route),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", rw.status()),
+		slog.Int64("bytes", rw.bytes),
+		slog.Float64("duration_ms", float64(d.Microseconds())/1000),
+		slog.String("user", user),
+		slog.String("remote_ip", e.clientIP),
+	)
+}
+
+// clientIP - адрес клиента. Если запрос пришел от доверенного прокси, адресом клиента
+// считается последний недоверенный адрес в X-Forwarded-For или X-Real-IP
+func (a *AccessLog) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !a.trusted(remote) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	// Адреса справа добавлены ближайшими прокси
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			break
+		}
+		if !a.trusted(addr) {
+			return addr.String()
+		}
+	}
+	if real, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return real.String()
+	}
+	if len(hops) > 0 {
+		if addr, err := netip.ParseAddr(hops[0]); err == nil {
+			return addr.String()
+		}
+	}
+	return host
+}
+
+// trusted - адрес принадлежит доверенному прокси
+func (a *AccessLog) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range a.config.TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// responseWriter - считает код ответа и размер тела и добавляет ID запроса в текст ошибок
+// http.Error. Flush и Hijack передаются исходному ResponseWriter для SSE и WebSocket.
+type responseWriter struct {
+	http.ResponseWriter
+	requestID string
+	code      int
+	bytes     int64
+	// Ответ - текст ошибки http.Error, к нему дописывается ID запроса
+	errorText bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+		h := w.Header()
+		w.errorText = code >= 400 && h.Get("X-Content-Type-Options") == "nosniff" &&
+			strings.HasPrefix(h.Get("Content-Type"), "text/plain")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	if err == nil && w.errorText {
+		w.errorText = false
+		m, err := fmt.Fprintf(w.ResponseWriter, "request_id: %s\n", w.requestID)
+		w.bytes += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("accessLog: response writer does not support hijacking")
+	}
+	w.code = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Unwrap - исходный ResponseWriter для http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// status - код ответа, 200 если обработчик ничего не записал
+func (w *responseWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,198 @@
+package accessLog
+
+import (
+	"TaskManager/pkg/logger"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// newRouter - маршрутизатор с журналом доступа и обработчиками для проверок
+func newRouter(t *testing.T, config Config) *mux.Router {
+	t.Helper()
+	a, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	r.HandleFunc("/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		SetUser(r.Context(), "admin")
+		logger.InfoContext(r.Context(), "обработка")
+		_, _ = w.Write([]byte(RequestID(r.Context())))
+	})
+	r.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "что-то пошло не так", http.StatusInternalServerError)
+	})
+	r.HandleFunc("/ip", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(ClientIP(r.Context())))
+	})
+	r.Use(a.Middleware)
+	return r
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// captureLog - направляет журнал в файл в формате JSON до конца теста, возвращает путь файла
+func captureLog(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log.json")
+	err := logger.Setup(logger.Config{Format: logger.FormatJSON, Output: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = logger.Setup(logger.Config{}) })
+	return path
+}
+
+func TestRequestID(t *testing.T) {
+	h := newRouter(t, Config{Format: FormatOff})
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/1", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := serve(h, req)
+	if got := rec.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Errorf("ID клиента: ожидался abc-123 в заголовке ответа, получено %q", got)
+	}
+	if rec.Body.String() != "abc-123" {
+		t.Errorf("ID клиента: ожидался abc-123 в контексте, получено %q", rec.Body.String())
+	}
+
+	for _, id := range []string{"", "с пробелом и кириллицей", strings.Repeat("x", maxRequestIDLength+1)} {
+		req = httptest.NewRequest(http.MethodGet, "/tasks/1", nil)
+		req.Header.Set(RequestIDHeader, id)
+		rec = serve(h, req)
+		got := rec.Header().Get(RequestIDHeader)
+		if got == id || len(got) != 32 || rec.Body.String() != got {
+			t.Errorf("ID %q: ожидался новый ID, получено %q", id, got)
+		}
+	}
+}
+
+func TestErrorBody(t *testing.T) {
+	h := newRouter(t, Config{Format: FormatOff})
+	req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := serve(h, req)
+	want := "что-то пошло не так\nrequest_id: abc-123\n"
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != want {
+		t.Errorf("текст ошибки: ожидалось %q, получено %d %q", want, rec.Code, rec.Body.String())
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseProxies("10.0.0.0/8, 127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newRouter(t, Config{Format: FormatOff, TrustedProxies: proxies})
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{"без прокси", "192.0.2.1:1234", "", "", "192.0.2.1"},
+		{"недоверенный адрес подменяет заголовок", "192.0.2.1:1234", "198.51.100.7", "198.51.100.8", "192.0.2.1"},
+		{"доверенный прокси", "10.0.0.2:1234", "198.51.100.7", "", "198.51.100.7"},
+		{"цепочка прокси", "127.0.0.1:1234", "203.0.113.5, 198.51.100.7, 10.1.1.1", "", "198.51.100.7"},
+		{"X-Real-IP", "10.0.0.2:1234", "", "198.51.100.8", "198.51.100.8"},
+		{"только доверенные адреса", "10.0.0.2:1234", "10.0.0.3", "", "10.0.0.3"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.realIP != "" {
+			req.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := serve(h, req).Body.String(); got != tt.want {
+			t.Errorf("%s: ожидался %s, получено %s", tt.name, tt.want, got)
+		}
+	}
+
+	_, err = ParseProxies("10.0.0.0/33")
+	if err == nil {
+		t.Error("ParseProxies: ожидалась ошибка для неверной подсети")
+	}
+}
+
+func TestStructured(t *testing.T) {
+	path := captureLog(t)
+	h := newRouter(t, Config{Format: FormatStructured})
+	req := httptest.NewRequest(http.MethodGet, "/tasks/7", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	serve(h, req)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("ожидались запись обработчика и запись журнала доступа, получено %q", lines)
+	}
+	for _, line := range lines {
+		var record map[string]any
+		err = json.Unmarshal([]byte(line), &record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record["request_id"] != "abc-123" {
+			t.Errorf("ожидался request_id в каждой записи: %s", line)
+		}
+	}
+	var record map[string]any
+	_ = json.Unmarshal([]byte(lines[1]), &record)
+	want := map[string]any{"msg": "request", "method": "GET", "route": "/tasks/{id}", "path": "/tasks/7",
+		"status": float64(200), "bytes": float64(7), "user": "admin", "remote_ip": "192.0.2.1"}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("%s: ожидалось %v, получено %v", k, v, record[k])
+		}
+	}
+	if _, ok := record["duration_ms"].(float64); !ok {
+		t.Errorf("ожидалось duration_ms: %s", lines[1])
+	}
+}
+
+func TestCombined(t *testing.T) {
+	var buf bytes.Buffer
+	h := newRouter(t, Config{Format: FormatCombined, Writer: &buf})
+	req := httptest.NewRequest(http.MethodGet, "/fail?x=1", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	req.Header.Set("User-Agent", "test")
+	rec := serve(h, req)
+
+	line := buf.String()
+	prefix := "192.0.2.1 - - ["
+	if !strings.HasPrefix(line, prefix) {
+		t.Fatalf("ожидалось начало %q, получено %q", prefix, line)
+	}
+	want := `] "GET /fail?x=1 HTTP/1.1" 500 ` + strconv.Itoa(rec.Body.Len()) + ` "" "test" abc-123 /fail `
+	if !strings.Contains(line, want) {
+		t.Errorf("ожидалось %q в %q", want, line)
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Format: "xml"})
+	if err == nil {
+		t.Error("ожидалась ошибка неизвестного формата")
+	}
+}
@@ -0,0 +1,48 @@
+package accessLog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Заголовок с ID запроса во входящем запросе и в ответе.
+const RequestIDHeader = "X-Request-ID"
+
+// Максимальная длина принимаемого ID запроса.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestID - ID запроса из контекста, пустая строка вне запроса
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID - добавляет ID запроса в контекст
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// newRequestID - случайный ID запроса из 32 шестнадцатеричных символов
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID - ID, принятый от клиента, не должен ломать строки журнала и заголовки:
+// разрешены только печатные ASCII символы без пробелов и кавычек
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if c <= ' ' || c > '~' || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
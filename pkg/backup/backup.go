@@ -0,0 +1,621 @@
+package backup
+
+import (
+	"TaskManager/pkg/storage"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Формат и версия архива.
+const (
+	Format  = "taskmanager-backup"
+	Version = 1
+)
+
+// Стратегии разрешения конфликтов при восстановлении.
+const (
+	// Оставить существующую запись, ссылки из архива направить на нее
+	StrategySkip = "skip"
+	// Перезаписать существующую запись данными из архива
+	StrategyOverwrite = "overwrite"
+	// Вставить запись из архива с новым ID и переписать ссылки на нее
+	StrategyRemap = "remap"
+	// Прервать восстановление
+	StrategyFail = "fail"
+)
+
+// Entity - таблица, входящая в архив. Новые сущности добавляются в Entities
+// после тех, на которые они ссылаются.
+type Entity struct {
+	Table string
+	// Колонка с собственным ID (serial), пустая - у таблицы нет собственного ID
+	Key string
+	// Ссылки на другие сущности архива: колонка -> таблица
+	Refs map[string]string
+	// Колонки, определяющие запись таблицы без собственного ID или уникальные вместе
+	// у таблицы с ID
+	Unique []string
+	// Значения колонок, которых нет в архивах старых версий
+	Defaults map[string]any
+}
+
+// Entities - сущности архива в порядке выгрузки и восстановления.
+// События, уведомления и очередь писем - рабочее состояние сервиса и в архив не входят.
+var Entities = []Entity{
+	{Table: "users", Key: "id"},
+	{Table: "labels", Key: "id", Unique: []string{"scope", "name"}, Defaults: map[string]any{"scope": ""}},
+	{Table: "tasks", Key: "id", Refs: map[string]string{"author_id": "users", "assigned_id": "users"}},
+	{Table: "tasks_labels", Refs: map[string]string{"task_id": "tasks", "label_id": "labels"}, Unique: []string{"task_id", "label_id"}},
+	{Table: "notification_prefs", Refs: map[string]string{"user_id": "users"}, Unique: []string{"user_id", "type"}},
+}
+
+// Header - первая строка архива.
+type Header struct {
+	Format   string
+	Version  int
+	Created  int64
+	Entities []string
+}
+
+// Record - строка данных архива.
+type Record struct {
+	Entity string
+	Data   json.RawMessage
+}
+
+// Trailer - последняя строка архива для проверки целостности: количество записей
+// по сущностям и SHA-256 всех строк данных.
+type Trailer struct {
+	End      bool
+	Counts   map[string]int
+	Checksum string
+}
+
+// EntityReport - результат восстановления одной сущности.
+type EntityReport struct {
+	Inserted    int
+	Remapped    int
+	Overwritten int
+	Skipped     int
+}
+
+// Report - результат восстановления.
+type Report struct {
+	Strategy  string
+	DryRun    bool
+	Committed bool
+	Entities  map[string]*EntityReport
+	// Колонки архива, отсутствующие в таблицах текущей схемы
+	IgnoredColumns []string `json:"IgnoredColumns,omitempty"`
+}
+
+func entityByTable(table string) (*Entity, bool) {
+	for i := range Entities {
+		if Entities[i].Table == table {
+			return &Entities[i], true
+		}
+	}
+	return nil, false
+}
+
+func (e *Entity) orderBy() string {
+	if e.Key != "" {
+		return pgx.Identifier{e.Key}.Sanitize()
+	}
+	cols := make([]string, len(e.Unique))
+	for i, c := range e.Unique {
+		cols[i] = pgx.Identifier{c}.Sanitize()
+	}
+	return strings.Join(cols, ", ")
+}
+
+// Export - выгружает все сущности в архив (NDJSON, сжатый gzip) в одной транзакции,
+// чтобы архив был согласованным снимком.
+func Export(ctx context.Context, s *storage.Storage, w io.Writer) error {
+	tx, err := s.DB.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+
+	header := Header{Format: Format, Version: Version, Created: time.Now().Unix()}
+	for _, e := range Entities {
+		header.Entities = append(header.Entities, e.Table)
+	}
+	err = enc.Encode(header)
+	if err != nil {
+		return err
+	}
+
+	trailer := Trailer{End: true, Counts: map[string]int{}}
+	sum := sha256.New()
+	for _, e := range Entities {
+		table := pgx.Identifier{e.Table}.Sanitize()
+		rows, err := tx.Query(ctx, "SELECT row_to_json(t)::text FROM "+table+" as t ORDER BY "+e.orderBy()+";")
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var data string
+			err = rows.Scan(&data)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			line, err := json.Marshal(Record{Entity: e.Table, Data: json.RawMessage(data)})
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			sum.Write(line)
+			_, err = gz.Write(append(line, '\n'))
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			trailer.Counts[e.Table]++
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+	}
+
+	trailer.Checksum = hex.EncodeToString(sum.Sum(nil))
+	err = enc.Encode(trailer)
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// restorer - состояние восстановления
+type restorer struct {
+	ctx      context.Context
+	tx       pgx.Tx
+	strategy string
+	report   *Report
+	// Соответствие ID архива и ID в базе по таблицам
+	ids map[string]map[int64]int64
+	// Колонки таблиц текущей схемы
+	columns map[string]map[string]bool
+	ignored map[string]bool
+}
+
+// Import - восстанавливает архив в одной транзакции. Записи без конфликта вставляются
+// с ID из архива; конфликт (запись с тем же ID или той же уникальной комбинацией колонок)
+// разрешается стратегией. Ссылки переписываются на итоговые ID. Если архив поврежден,
+// неполон или ссылается на отсутствующие в нем записи, ничего не сохраняется.
+func Import(ctx context.Context, s *storage.Storage, r io.Reader, strategy string, dryRun bool) (*Report, error) {
+	switch strategy {
+	case StrategySkip, StrategyOverwrite, StrategyRemap, StrategyFail:
+	default:
+		return nil, fmt.Errorf("неизвестная стратегия: %s", strategy)
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("архив не в формате gzip: %w", err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 1<<20), 64<<20)
+
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("пустой архив: %w", scanner.Err())
+	}
+	var header Header
+	err = json.Unmarshal(scanner.Bytes(), &header)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный заголовок архива: %w", err)
+	}
+	if header.Format != Format {
+		return nil, fmt.Errorf("неизвестный формат архива: %q", header.Format)
+	}
+	if header.Version > Version {
+		return nil, fmt.Errorf("версия архива %d новее поддерживаемой %d", header.Version, Version)
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rs := &restorer{
+		ctx:      ctx,
+		tx:       tx,
+		strategy: strategy,
+		report:   &Report{Strategy: strategy, DryRun: dryRun, Entities: map[string]*EntityReport{}},
+		ids:      map[string]map[int64]int64{},
+		columns:  map[string]map[string]bool{},
+		ignored:  map[string]bool{},
+	}
+	err = rs.loadColumns()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	sum := sha256.New()
+	var trailer *Trailer
+	order := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if trailer != nil {
+			return nil, errors.New("данные после завершающей строки архива")
+		}
+
+		var rec struct {
+			Record
+			Trailer
+		}
+		err = json.Unmarshal(line, &rec)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная строка архива: %w", err)
+		}
+		if rec.End {
+			trailer = &rec.Trailer
+			continue
+		}
+
+		sum.Write(line)
+		entity, ok := entityByTable(rec.Entity)
+		if !ok {
+			return nil, fmt.Errorf("неизвестная сущность в архиве: %s", rec.Entity)
+		}
+		// Сущности должны идти в порядке Entities, иначе ссылки нельзя разрешить
+		for order < len(Entities) && Entities[order].Table != rec.Entity {
+			order++
+		}
+		if order == len(Entities) {
+			return nil, fmt.Errorf("нарушен порядок сущностей архива на %s", rec.Entity)
+		}
+
+		err = rs.restore(entity, rec.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rec.Entity, err)
+		}
+		counts[rec.Entity]++
+	}
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	// Проверка целостности
+	if trailer == nil {
+		return nil, errors.New("архив неполон: нет завершающей строки")
+	}
+	if trailer.Checksum != hex.EncodeToString(sum.Sum(nil)) {
+		return nil, errors.New("контрольная сумма архива не совпадает")
+	}
+	for _, e := range Entities {
+		if counts[e.Table] != trailer.Counts[e.Table] {
+			return nil, fmt.Errorf("количество записей %s не совпадает: %d вместо %d",
+				e.Table, counts[e.Table], trailer.Counts[e.Table])
+		}
+	}
+
+	err = rs.fixSequences()
+	if err != nil {
+		return nil, err
+	}
+
+	for c := range rs.ignored {
+		rs.report.IgnoredColumns = append(rs.report.IgnoredColumns, c)
+	}
+
+	if dryRun {
+		return rs.report, nil
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rs.report.Committed = true
+	// Данные изменены в обход методов хранилища
+	s.InvalidateCache(ctx)
+
+	return rs.report, nil
+}
+
+// loadColumns - загружает колонки таблиц архива из текущей схемы
+func (rs *restorer) loadColumns() error {
+	for _, e := range Entities {
+		rows, err := rs.tx.Query(rs.ctx, `
+			SELECT column_name
+			FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = $1;
+		`, e.Table)
+		if err != nil {
+			return err
+		}
+		cols := map[string]bool{}
+		for rows.Next() {
+			var c string
+			err = rows.Scan(&c)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			cols[c] = true
+		}
+		rows.Close()
+		if rows.Err() != nil {
+			return rows.Err()
+		}
+		if len(cols) == 0 {
+			return fmt.Errorf("таблица %s отсутствует в базе", e.Table)
+		}
+		rs.columns[e.Table] = cols
+		rs.ids[e.Table] = map[int64]int64{}
+		rs.report.Entities[e.Table] = &EntityReport{}
+	}
+	return nil
+}
+
+// restore - восстанавливает одну запись
+func (rs *restorer) restore(e *Entity, data json.RawMessage) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var rec map[string]any
+	err := dec.Decode(&rec)
+	if err != nil {
+		return err
+	}
+
+	// Переписываем ссылки на итоговые ID
+	for col, table := range e.Refs {
+		v, ok := rec[col]
+		if !ok || v == nil {
+			continue
+		}
+		oldID, err := v.(json.Number).Int64()
+		if err != nil {
+			return fmt.Errorf("некорректная ссылка %s: %v", col, v)
+		}
+		newID, ok := rs.ids[table][oldID]
+		if !ok {
+			return fmt.Errorf("ссылка %s = %d на запись %s, отсутствующую в архиве", col, oldID, table)
+		}
+		rec[col] = newID
+	}
+
+	for col, v := range e.Defaults {
+		if _, ok := rec[col]; !ok {
+			rec[col] = v
+		}
+	}
+	var cols []string
+	for col := range rec {
+		if !rs.columns[e.Table][col] {
+			rs.ignored[e.Table+"."+col] = true
+			delete(rec, col)
+			continue
+		}
+		cols = append(cols, col)
+	}
+
+	report := rs.report.Entities[e.Table]
+	table := pgx.Identifier{e.Table}.Sanitize()
+
+	if e.Key == "" {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		exists, err := rs.exists("SELECT EXISTS (SELECT 1 FROM "+table+" as t, json_populate_record(NULL::"+table+", $1::json) as r WHERE "+rs.uniqueMatch(e)+");", string(data))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			report.Inserted++
+			return rs.insert(e, rec, cols, nil)
+		}
+		switch rs.strategy {
+		case StrategyFail:
+			return fmt.Errorf("запись %v уже существует", rec)
+		case StrategyOverwrite:
+			report.Overwritten++
+			return rs.update(e, rec, cols, "WHERE ("+e.uniqueCols("t")+") = (SELECT "+e.uniqueCols("r")+" FROM json_populate_record(NULL::"+table+", $1::json) as r)")
+		}
+		// Для таблиц связей skip и remap означают одно: связь уже есть
+		report.Skipped++
+		return nil
+	}
+
+	v, ok := rec[e.Key]
+	if !ok {
+		return fmt.Errorf("нет колонки %s", e.Key)
+	}
+	oldID, err := v.(json.Number).Int64()
+	if err != nil {
+		return fmt.Errorf("некорректный %s: %v", e.Key, v)
+	}
+
+	if len(e.Unique) > 0 {
+		existingID, found, err := rs.uniqueID(e, rec)
+		if err != nil {
+			return err
+		}
+		// Запись с теми же уникальными колонками под другим ID не дает вставить запись
+		// из архива, а при remap - и ее копию, поэтому ссылки направляются на существующую
+		if found && (existingID != oldID || rs.strategy == StrategyRemap) {
+			rs.ids[e.Table][oldID] = existingID
+			switch rs.strategy {
+			case StrategyFail:
+				return fmt.Errorf("запись с %s = %d: такие же %s уже у записи %d",
+					e.Key, oldID, strings.Join(e.Unique, ", "), existingID)
+			case StrategySkip:
+				report.Skipped++
+				return nil
+			case StrategyOverwrite:
+				report.Overwritten++
+				return rs.update(e, rec, cols, "WHERE t."+pgx.Identifier{e.Key}.Sanitize()+" = $2", existingID)
+			}
+			report.Remapped++
+			return nil
+		}
+	}
+
+	exists, err := rs.exists("SELECT EXISTS (SELECT 1 FROM "+table+" WHERE "+pgx.Identifier{e.Key}.Sanitize()+" = $1);", oldID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		report.Inserted++
+		rs.ids[e.Table][oldID] = oldID
+		return rs.insert(e, rec, cols, nil)
+	}
+
+	switch rs.strategy {
+	case StrategyFail:
+		return fmt.Errorf("запись с %s = %d уже существует", e.Key, oldID)
+	case StrategySkip:
+		report.Skipped++
+		rs.ids[e.Table][oldID] = oldID
+		return nil
+	case StrategyOverwrite:
+		report.Overwritten++
+		rs.ids[e.Table][oldID] = oldID
+		return rs.update(e, rec, cols, "WHERE t."+pgx.Identifier{e.Key}.Sanitize()+" = $2", oldID)
+	}
+
+	// StrategyRemap: вставка с новым ID
+	report.Remapped++
+	var remapped []string
+	for _, c := range cols {
+		if c != e.Key {
+			remapped = append(remapped, c)
+		}
+	}
+	var newID int64
+	err = rs.insert(e, rec, remapped, &newID)
+	if err != nil {
+		return err
+	}
+	rs.ids[e.Table][oldID] = newID
+	return nil
+}
+
+func (e *Entity) uniqueCols(alias string) string {
+	cols := make([]string, len(e.Unique))
+	for i, c := range e.Unique {
+		cols[i] = alias + "." + pgx.Identifier{c}.Sanitize()
+	}
+	return strings.Join(cols, ", ")
+}
+
+func (rs *restorer) uniqueMatch(e *Entity) string {
+	conds := make([]string, len(e.Unique))
+	for i, c := range e.Unique {
+		col := pgx.Identifier{c}.Sanitize()
+		conds[i] = "t." + col + " = r." + col
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// uniqueID - ID существующей записи с теми же уникальными колонками, что у rec
+func (rs *restorer) uniqueID(e *Entity, rec map[string]any) (int64, bool, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return 0, false, err
+	}
+	table := pgx.Identifier{e.Table}.Sanitize()
+	var id int64
+	err = rs.tx.QueryRow(rs.ctx, "SELECT t."+pgx.Identifier{e.Key}.Sanitize()+" FROM "+table+" as t, json_populate_record(NULL::"+
+		table+", $1::json) as r WHERE "+rs.uniqueMatch(e)+";", string(data)).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+func (rs *restorer) exists(sql string, args ...any) (bool, error) {
+	var exists bool
+	err := rs.tx.QueryRow(rs.ctx, sql, args...).Scan(&exists)
+	return exists, err
+}
+
+// insert - вставляет запись с колонками cols; если newID не nil, в него возвращается ID записи
+func (rs *restorer) insert(e *Entity, rec map[string]any, cols []string, newID *int64) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	table := pgx.Identifier{e.Table}.Sanitize()
+	list := sanitizeList(cols)
+	sql := "INSERT INTO " + table + " (" + list + ") SELECT " + list +
+		" FROM json_populate_record(NULL::" + table + ", $1::json)"
+	if newID != nil {
+		return rs.tx.QueryRow(rs.ctx, sql+" RETURNING "+pgx.Identifier{e.Key}.Sanitize()+";", string(data)).Scan(newID)
+	}
+	_, err = rs.tx.Exec(rs.ctx, sql+";", string(data))
+	return err
+}
+
+// update - перезаписывает существующую запись значениями из архива
+func (rs *restorer) update(e *Entity, rec map[string]any, cols []string, where string, args ...any) error {
+	var set []string
+	for _, c := range cols {
+		if c != e.Key {
+			set = append(set, c)
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	table := pgx.Identifier{e.Table}.Sanitize()
+	list := sanitizeList(set)
+	sql := "UPDATE " + table + " as t SET (" + list + ") = (SELECT " + list +
+		" FROM json_populate_record(NULL::" + table + ", $1::json)) " + where + ";"
+	_, err = rs.tx.Exec(rs.ctx, sql, append([]any{string(data)}, args...)...)
+	return err
+}
+
+// fixSequences - сдвигает последовательности ID за максимальный ID таблиц,
+// иначе следующие вставки получат ID, уже занятые восстановленными записями
+func (rs *restorer) fixSequences() error {
+	for _, e := range Entities {
+		if e.Key == "" {
+			continue
+		}
+		table := pgx.Identifier{e.Table}.Sanitize()
+		key := pgx.Identifier{e.Key}.Sanitize()
+		_, err := rs.tx.Exec(rs.ctx, `
+			SELECT setval(pg_get_serial_sequence($1, $2), GREATEST((SELECT max(`+key+`) FROM `+table+`), 1));
+		`, e.Table, e.Key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sanitizeList(cols []string) string {
+	result := make([]string, len(cols))
+	for i, c := range cols {
+		result[i] = pgx.Identifier{c}.Sanitize()
+	}
+	return strings.Join(result, ", ")
+}
@@ -0,0 +1,165 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestEntitiesOrder(t *testing.T) {
+	seen := map[string]bool{}
+	for _, e := range Entities {
+		for col, table := range e.Refs {
+			if !seen[table] {
+				t.Errorf("%s.%s ссылается на %s, которая восстанавливается позже", e.Table, col, table)
+			}
+		}
+		if e.Key == "" && len(e.Unique) == 0 {
+			t.Errorf("%s: нет ни ключа, ни уникальных колонок", e.Table)
+		}
+		seen[e.Table] = true
+	}
+}
+
+func TestEntityByTable(t *testing.T) {
+	e, ok := entityByTable("tasks")
+	if !ok || e.Key != "id" {
+		t.Fatalf("entityByTable(tasks) = %v, %v", e, ok)
+	}
+	if _, ok := entityByTable("events"); ok {
+		t.Error("events не должны входить в архив")
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	e, _ := entityByTable("tasks_labels")
+	if got := e.orderBy(); got != `"task_id", "label_id"` {
+		t.Errorf("orderBy() = %s", got)
+	}
+}
+
+// fakeTx - транзакция с таблицей меток в памяти для проверки разрешения конфликтов
+type fakeTx struct {
+	pgx.Tx
+	// ID -> scope/name существующих меток
+	labels map[int64][2]string
+	execs  []string
+	args   [][]any
+}
+
+type fakeRow struct {
+	value any
+	err   error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	switch d := dest[0].(type) {
+	case *int64:
+		*d = r.value.(int64)
+	case *bool:
+		*d = r.value.(bool)
+	}
+	return nil
+}
+
+func (tx *fakeTx) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	switch {
+	case strings.HasPrefix(sql, "SELECT t.") && strings.Contains(sql, `"labels"`):
+		var rec struct{ Scope, Name string }
+		var m map[string]string
+		json.Unmarshal([]byte(args[0].(string)), &m)
+		rec.Scope, rec.Name = m["scope"], m["name"]
+		for id, l := range tx.labels {
+			if l == [2]string{rec.Scope, rec.Name} {
+				return fakeRow{value: id}
+			}
+		}
+		return fakeRow{err: pgx.ErrNoRows}
+	case strings.HasPrefix(sql, "SELECT EXISTS") && strings.Contains(sql, `"labels"`):
+		_, ok := tx.labels[args[0].(int64)]
+		return fakeRow{value: ok}
+	case strings.HasPrefix(sql, "SELECT EXISTS"):
+		return fakeRow{value: false}
+	}
+	return fakeRow{err: errors.New("неожиданный запрос: " + sql)}
+}
+
+func (tx *fakeTx) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tx.execs = append(tx.execs, sql)
+	tx.args = append(tx.args, args)
+	return nil, nil
+}
+
+func newTestRestorer(tx pgx.Tx, strategy string) *restorer {
+	rs := &restorer{
+		ctx:      context.Background(),
+		tx:       tx,
+		strategy: strategy,
+		report:   &Report{Strategy: strategy, Entities: map[string]*EntityReport{}},
+		ids:      map[string]map[int64]int64{},
+		columns:  map[string]map[string]bool{},
+		ignored:  map[string]bool{},
+	}
+	rs.columns["labels"] = map[string]bool{"id": true, "name": true, "scope": true, "color": true, "description": true}
+	rs.columns["tasks_labels"] = map[string]bool{"task_id": true, "label_id": true}
+	for _, table := range []string{"labels", "tasks", "tasks_labels"} {
+		rs.ids[table] = map[int64]int64{}
+		rs.report.Entities[table] = &EntityReport{}
+	}
+	return rs
+}
+
+func TestRestoreLabelUniqueConflict(t *testing.T) {
+	labels, _ := entityByTable("labels")
+	links, _ := entityByTable("tasks_labels")
+	// В базе метка "bug" с ID 7, в архиве она же с ID 3 и без scope (архив до появления областей)
+	label := json.RawMessage(`{"id": 3, "name": "bug"}`)
+
+	for _, strategy := range []string{StrategySkip, StrategyRemap, StrategyOverwrite} {
+		tx := &fakeTx{labels: map[int64][2]string{3: {"", "feature"}, 7: {"", "bug"}}}
+		rs := newTestRestorer(tx, strategy)
+		rs.ids["tasks"][1] = 1
+		err := rs.restore(labels, label)
+		if err != nil {
+			t.Fatalf("%s: %v", strategy, err)
+		}
+		if got := rs.ids["labels"][3]; got != 7 {
+			t.Errorf("%s: метка 3 восстановлена как %d, ожидалась существующая 7", strategy, got)
+		}
+		report := rs.report.Entities["labels"]
+		if report.Inserted != 0 {
+			t.Errorf("%s: вставлена копия метки: %+v", strategy, report)
+		}
+		if strategy == StrategyOverwrite {
+			if report.Overwritten != 1 || len(tx.execs) != 1 || tx.args[0][1] != int64(7) {
+				t.Errorf("overwrite: ожидалась перезапись метки 7: %+v %v", report, tx.args)
+			}
+			continue
+		}
+		if len(tx.execs) != 0 {
+			t.Errorf("%s: лишние изменения: %v", strategy, tx.execs)
+		}
+
+		err = rs.restore(links, json.RawMessage(`{"task_id": 1, "label_id": 3}`))
+		if err != nil {
+			t.Fatalf("%s: %v", strategy, err)
+		}
+		if len(tx.args) != 1 || !strings.Contains(tx.args[0][0].(string), `"label_id":7`) {
+			t.Errorf("%s: связь не переписана на метку 7: %v", strategy, tx.args)
+		}
+	}
+
+	tx := &fakeTx{labels: map[int64][2]string{7: {"", "bug"}}}
+	rs := newTestRestorer(tx, StrategyFail)
+	if err := rs.restore(labels, label); err == nil {
+		t.Error("fail: конфликт имени не вернул ошибку")
+	}
+}
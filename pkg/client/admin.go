@@ -0,0 +1,54 @@
+package client
+
+import (
+	"TaskManager/pkg/backup"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+//-------------------Администрирование-------------------------
+
+// ExportBackup - выгружает все данные в архив (NDJSON, gzip). Требует Config.Token с токеном
+// администратора. Тело ответа закрывает вызывающий.
+func (c *Client) ExportBackup(ctx context.Context) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/admin/export", idempotent: true, stream: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Стратегии восстановления записей, уже существующих в базе.
+const (
+	StrategySkip      = backup.StrategySkip
+	StrategyOverwrite = backup.StrategyOverwrite
+	StrategyRemap     = backup.StrategyRemap
+	StrategyFail      = backup.StrategyFail
+)
+
+// ImportBackup - восстанавливает данные из архива. strategy - одна из Strategy*, пустая - StrategySkip.
+// Требует Config.Token с токеном администратора.
+func (c *Client) ImportBackup(ctx context.Context, archive io.Reader, strategy string, dryRun bool) (*BackupReport, error) {
+	q := url.Values{}
+	if strategy != "" {
+		q.Set("strategy", strategy)
+	}
+	if dryRun {
+		q.Set("dryrun", "1")
+	}
+	result := &BackupReport{}
+	err := c.call(ctx, request{
+		method:      http.MethodPost,
+		path:        "/admin/import",
+		query:       q,
+		body:        archive,
+		contentType: "application/gzip",
+		stream:      true,
+	}, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
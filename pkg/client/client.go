@@ -0,0 +1,357 @@
+// Package client - типизированный клиент HTTP API TaskManager.
+//
+//	c, err := client.New("http://localhost:8010", client.Config{})
+//	task, err := c.CreateTask(ctx, &client.Task{Title: "Fix login"})
+//
+// Идемпотентные вызовы (чтение, обновление, назначение, метки задач) повторяются при сетевых ошибках
+// и ответах 429, 502, 503, 504 согласно Config.Retry. Создание и удаление не повторяются, чтобы
+// не создать дубликат и не получить ошибку на уже выполненном запросе.
+package client
+
+import (
+	"TaskManager/pkg/backup"
+	"TaskManager/pkg/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Модели API совпадают с моделями хранилища.
+type (
+	Task               = storage.Task
+	User               = storage.User
+	UserOffboardReport = storage.UserOffboardReport
+	Label              = storage.Label
+	LabelUsage         = storage.LabelUsage
+	LabelScope         = storage.LabelScope
+	LabelMergeReport   = storage.LabelMergeReport
+	DuplicateLabels    = storage.DuplicateLabels
+	Notification       = storage.Notification
+	NotificationPref   = storage.NotificationPref
+	Event              = storage.Event
+	ImportReport       = storage.ImportReport
+	TaskChanges        = storage.TaskChanges
+	BulkReport         = storage.BulkReport
+	BackupReport       = backup.Report
+)
+
+// RetryPolicy - повторы идемпотентных запросов с экспоненциальной задержкой.
+type RetryPolicy struct {
+	// Общее число попыток, 1 - без повторов
+	MaxAttempts int
+	// Задержка перед первым повтором, далее удваивается
+	MinBackoff time.Duration
+	// Максимальная задержка между попытками
+	MaxBackoff time.Duration
+}
+
+// DefaultRetry - политика повторов по умолчанию.
+var DefaultRetry = RetryPolicy{MaxAttempts: 3, MinBackoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}
+
+// Config - настройки клиента. Нулевые значения заменяются значениями по умолчанию.
+type Config struct {
+	// Токен для заголовка Authorization: Bearer, нужен административному API
+	Token string
+	// HTTP клиент, по умолчанию http.Client без общего таймаута
+	HTTPClient *http.Client
+	// Таймаут одной попытки запроса, по умолчанию 30 секунд. Не действует на потоки
+	// (события, выгрузки), их время жизни ограничивается только контекстом
+	Timeout time.Duration
+	// Политика повторов, по умолчанию DefaultRetry
+	Retry RetryPolicy
+}
+
+// Client - клиент HTTP API. Безопасен для использования из нескольких горутин.
+type Client struct {
+	baseURL *url.URL
+	config  Config
+}
+
+// New - создает клиент для сервера по адресу baseURL, например http://localhost:8010.
+func New(baseURL string, config Config) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: адрес сервера должен начинаться с http:// или https://: %q", baseURL)
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{}
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.Retry.MaxAttempts == 0 {
+		config.Retry = DefaultRetry
+	}
+	return &Client{baseURL: u, config: config}, nil
+}
+
+// request - описание запроса к API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	// Тело запроса: []byte или io.Reader передаются как есть, остальное кодируется в JSON
+	body        any
+	contentType string
+	// Запрос можно безопасно повторить
+	idempotent bool
+	// Поток: ответ читается вызывающим, таймаут попытки не действует
+	stream bool
+	// Код ошибки, тело которого читается как успешный ответ (отчет импорта с ошибками)
+	accept int
+	// Заголовок Idempotency-Key: сервер выполнит запрос один раз, и его можно повторить
+	idempotencyKey string
+}
+
+// once - запрос с новым ключом идемпотентности, общим для всех попыток
+func once(req request) request {
+	req.idempotencyKey = fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64())
+	req.idempotent = true
+	return req
+}
+
+// do - выполняет запрос с повторами и возвращает успешный ответ. Ответ с кодом ошибки
+// преобразуется в *Error. Тело ответа закрывает вызывающий
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	var reader io.Reader
+	switch b := req.body.(type) {
+	case nil:
+	case []byte:
+		body = b
+	case io.Reader:
+		// Поток нельзя прочитать повторно
+		reader = b
+		req.idempotent = false
+	default:
+		var err error
+		body, err = json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		if req.contentType == "" {
+			req.contentType = "application/json"
+		}
+	}
+
+	attempts := 1
+	if req.idempotent {
+		attempts = max(c.config.Retry.MaxAttempts, 1)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		resp, cancel, err := c.send(ctx, req, reader)
+		retry := attempt < attempts && (retryable(ctx, resp, err) || inProgress(req, resp))
+		if err == nil && !retry {
+			if resp.StatusCode >= 200 && resp.StatusCode <= 299 || resp.StatusCode == req.accept {
+				if cancel != nil {
+					resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+				}
+				return resp, nil
+			}
+			err = responseError(req, resp)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if cancel != nil {
+			cancel()
+		}
+		if !retry {
+			return nil, err
+		}
+
+		timer := time.NewTimer(c.backoff(attempt, resp))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send - одна попытка запроса. cancel снимает таймаут попытки после чтения ответа
+func (c *Client) send(ctx context.Context, req request, body io.Reader) (*http.Response, context.CancelFunc, error) {
+	var cancel context.CancelFunc
+	if !req.stream {
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+	}
+
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+	r, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+	if err == nil {
+		if req.contentType != "" {
+			r.Header.Set("Content-Type", req.contentType)
+		}
+		if c.config.Token != "" {
+			r.Header.Set("Authorization", "Bearer "+c.config.Token)
+		}
+		if req.idempotencyKey != "" {
+			r.Header.Set("Idempotency-Key", req.idempotencyKey)
+		}
+		var resp *http.Response
+		resp, err = c.config.HTTPClient.Do(r)
+		if err == nil {
+			return resp, cancel, nil
+		}
+	}
+	if cancel != nil {
+		cancel()
+	}
+	return nil, nil, err
+}
+
+// cancelBody - тело ответа, при закрытии снимающее таймаут попытки
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// retryable - стоит ли повторить попытку: сетевая ошибка или временная недоступность сервера
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// inProgress - сервер еще выполняет предыдущую попытку запроса с тем же ключом идемпотентности.
+// Такой ответ 409 содержит Retry-After, остальные конфликты (метка уже есть, не выполнен
+// test JSON Patch) не повторяются
+func inProgress(req request, resp *http.Response) bool {
+	return req.idempotencyKey != "" && resp != nil && resp.StatusCode == http.StatusConflict &&
+		resp.Header.Get("Retry-After") != ""
+}
+
+// backoff - задержка перед следующей попыткой: Retry-After сервера или экспоненциальная
+// задержка со случайным разбросом
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	p := c.config.Retry
+	if resp != nil {
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+			return min(time.Duration(s)*time.Second, p.MaxBackoff)
+		}
+	}
+	d := p.MinBackoff << (attempt - 1)
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Разброс от половины до полной задержки, чтобы клиенты не повторяли запросы одновременно
+	return d/2 + rand.N(d/2+1)
+}
+
+// call - выполняет запрос и декодирует JSON ответ в out. Ответ 204 при непустом out возвращает errNoContent
+func (c *Client) call(ctx context.Context, req request, out any) error {
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return errNoContent
+	}
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("client: %s %s: ошибка разбора ответа: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// report - запрос отчета импорта. Ответ 422 содержит отчет со строками, не прошедшими проверку,
+// он возвращается вместе с ошибкой ErrUnprocessable
+func report[T any](ctx context.Context, c *Client, req request) (*T, error) {
+	req.accept = http.StatusUnprocessableEntity
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := new(T)
+	err = json.NewDecoder(resp.Body).Decode(result)
+	if err != nil {
+		return nil, fmt.Errorf("client: %s %s: ошибка разбора ответа: %w", req.method, req.path, err)
+	}
+	if resp.StatusCode == http.StatusUnprocessableEntity {
+		return result, &Error{Method: req.method, Path: req.path, StatusCode: resp.StatusCode, Message: "данные содержат ошибки"}
+	}
+	return result, nil
+}
+
+// Ответ 204: сервер так сообщает об отсутствии данных.
+var errNoContent = errors.New("client: нет данных")
+
+// get - идемпотентный GET запрос
+func get(path string, query url.Values) request {
+	return request{method: http.MethodGet, path: path, query: query, idempotent: true}
+}
+
+// list - запрос массива, ответ 204 дает пустой массив
+func list[T any](ctx context.Context, c *Client, req request) ([]T, error) {
+	var result []T
+	err := c.call(ctx, req, &result)
+	if errors.Is(err, errNoContent) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// one - запрос одной записи, ответ 204 дает ErrNotFound
+func one[T any](ctx context.Context, c *Client, req request, what string) (*T, error) {
+	result := new(T)
+	err := c.call(ctx, req, result)
+	if errors.Is(err, errNoContent) {
+		return nil, fmt.Errorf("client: %s: %w", what, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// params - параметры запроса из пар имя-значение
+func params(kv ...string) url.Values {
+	q := url.Values{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		q.Set(kv[i], kv[i+1])
+	}
+	return q
+}
@@ -0,0 +1,370 @@
+package client
+
+import (
+	"TaskManager/pkg/handlersService"
+	"TaskManager/pkg/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Политика повторов без заметных задержек.
+var fastRetry = RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+// newTestClient - клиент сервера с обработчиком h
+func newTestClient(t *testing.T, h http.Handler, config Config) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	if config.Retry.MaxAttempts == 0 {
+		config.Retry = fastRetry
+	}
+	c, err := New(srv.URL, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// handlersClient - клиент сервера с настоящими обработчиками без базы данных.
+// Подходит только для запросов, отклоняемых до обращения к хранилищу
+func handlersClient(t *testing.T, config handlersService.Config, token string) *Client {
+	h := handlersService.New(&storage.Storage{}, nil, config)
+	return newTestClient(t, h.Router(), Config{Token: token})
+}
+
+func TestErrorsFromHandlers(t *testing.T) {
+	ctx := context.Background()
+
+	c := handlersClient(t, handlersService.Config{}, "")
+	_, err := c.ExportBackup(ctx)
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("административный API без токена: ожидалась ErrForbidden, получено %v", err)
+	}
+	_, err = c.ImportTasks(ctx, strings.NewReader(""), ImportOptions{})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("импорт пустого CSV: ожидалась ErrBadRequest, получено %v", err)
+	}
+	_, err = c.Calendar(ctx, "token", "agenda")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Path != "/calendar.ics" {
+		t.Errorf("неизвестный вид календаря: ожидалась *Error с кодом 400, получено %v", err)
+	}
+	if apiErr != nil && (apiErr.RequestID == "" || strings.Contains(apiErr.Message, "request_id")) {
+		t.Errorf("ID запроса: ожидался в RequestID, а не в тексте ошибки, получено %q, %q", apiErr.RequestID, apiErr.Message)
+	}
+	err = c.GraphQL(ctx, "{ tasks {", nil, nil)
+	var gqlErr GraphQLErrors
+	if !errors.As(err, &gqlErr) || len(gqlErr) == 0 {
+		t.Errorf("синтаксическая ошибка GraphQL: ожидалась GraphQLErrors, получено %v", err)
+	}
+
+	c = handlersClient(t, handlersService.Config{AdminToken: "secret"}, "wrong")
+	_, err = c.ExportBackup(ctx)
+	if !errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrServer) {
+		t.Errorf("неверный токен администратора: ожидалась ErrUnauthorized, получено %v", err)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gettask":
+			w.WriteHeader(http.StatusNoContent)
+		case "/deletetask":
+			http.Error(w, "no rows in result set", http.StatusInternalServerError)
+		case "/alllabels":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}), Config{})
+
+	_, err := c.Task(ctx, 5)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Task: ожидалась ErrNotFound, получено %v", err)
+	}
+	_, err = c.DeleteTask(ctx, 5)
+	if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrServer) {
+		t.Errorf("DeleteTask: ожидалась ErrNotFound, получено %v", err)
+	}
+	labels, err := c.AllLabels(ctx)
+	if err != nil || len(labels) != 0 {
+		t.Errorf("AllLabels: ожидался пустой список, получено %v, %v", labels, err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	var calls atomic.Int32
+	failures := int32(2)
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "перегрузка", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(Task{ID: 7, Title: "task"})
+	}), Config{})
+
+	task, err := c.Task(ctx, 7)
+	if err != nil || task.ID != 7 || calls.Load() != 3 {
+		t.Errorf("идемпотентный запрос: %v, %v, попыток %d, ожидалось 3", task, err, calls.Load())
+	}
+
+	calls.Store(0)
+	_, err = c.ResetCalendarToken(ctx, 1)
+	if !errors.Is(err, ErrServer) || calls.Load() != 1 {
+		t.Errorf("неидемпотентный запрос не должен повторяться: %v, попыток %d", err, calls.Load())
+	}
+
+	calls.Store(0)
+	failures = 10
+	_, err = c.UpdateTask(ctx, &Task{ID: 7})
+	if !errors.Is(err, ErrServer) || calls.Load() != int32(fastRetry.MaxAttempts) {
+		t.Errorf("ожидалось %d попыток, получено %d: %v", fastRetry.MaxAttempts, calls.Load(), err)
+	}
+
+	calls.Store(0)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.Task(cancelled, 7)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("отмененный контекст: ожидалась context.Canceled, получено %v", err)
+	}
+}
+
+// TestIdempotencyKey - создание повторяется с тем же ключом идемпотентности
+func TestIdempotencyKey(t *testing.T) {
+	var keys []string
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		switch len(keys) {
+		case 1:
+			http.Error(w, "перегрузка", http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "еще выполняется", http.StatusConflict)
+		default:
+			json.NewEncoder(w).Encode(Task{ID: 7, Title: "task"})
+		}
+	}), Config{})
+
+	task, err := c.CreateTask(context.Background(), &Task{Title: "task"})
+	if err != nil || task.ID != 7 || len(keys) != 3 {
+		t.Fatalf("создание: %v, %v, попыток %d, ожидалось 3", task, err, len(keys))
+	}
+	if keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("ключ должен быть одним для всех попыток: %q", keys)
+	}
+
+	// Конфликт без Retry-After - ответ обработчика, а не выполняющийся запрос
+	keys = nil
+	c = newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		http.Error(w, "метка с таким названием уже есть в группе", http.StatusConflict)
+	}), Config{})
+	_, err = c.CreateLabel(context.Background(), &Label{Name: "bug"})
+	if !errors.Is(err, ErrConflict) || len(keys) != 1 {
+		t.Errorf("создание метки: %v, попыток %d, ожидалась ErrConflict и одна попытка", err, len(keys))
+	}
+}
+
+func TestPatch(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPatch || r.URL.Query().Get("id") != "7" ||
+			r.Header.Get("Content-Type") != "application/json-patch+json" ||
+			string(body) != `[{"op":"replace","path":"/Priority","value":1}]` {
+			http.Error(w, "неверный запрос: "+string(body), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(Task{ID: 7, Priority: 1})
+	}), Config{})
+
+	task, err := c.PatchTask(context.Background(), 7, JSONPatch(PatchOp{Op: "replace", Path: "/Priority", Value: 1}))
+	if err != nil || task.Priority != 1 {
+		t.Errorf("PatchTask: %v, %v", task, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Client{config: Config{Retry: RetryPolicy{MaxAttempts: 5, MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}}}
+	for attempt, limit := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 10: time.Second} {
+		d := c.backoff(attempt, nil)
+		if d < limit/2 || d > limit {
+			t.Errorf("backoff(%d) = %s, ожидалось от %s до %s", attempt, d, limit/2, limit)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {"30"}}}
+	if d := c.backoff(1, resp); d != time.Second {
+		t.Errorf("Retry-After должен ограничиваться MaxBackoff, получено %s", d)
+	}
+}
+
+func TestIterator(t *testing.T) {
+	ctx := context.Background()
+	for _, total := range []int{0, 6, 7} {
+		var requests int
+		c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			after, _ := strconv.Atoi(r.URL.Query().Get("after"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			var page []Task
+			for id := after + 1; id <= total && len(page) < limit; id++ {
+				page = append(page, Task{ID: id})
+			}
+			if page == nil {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			json.NewEncoder(w).Encode(page)
+		}), Config{})
+
+		tasks, err := c.IterTasks(3).All(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != total {
+			t.Errorf("всего %d: получено %d задач", total, len(tasks))
+		}
+		for i, task := range tasks {
+			if task.ID != i+1 {
+				t.Errorf("всего %d: задача %d имеет ID %d", total, i, task.ID)
+			}
+		}
+		if want := total/3 + 1; requests != want {
+			t.Errorf("всего %d: %d запросов, ожидалось %d", total, requests, want)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("lastEventId") != "3" || q.Get("type") != "task.created,task.deleted" || q.Get("assignee") != "2" {
+			http.Error(w, "неверные параметры: "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": ping\n\n")
+		for _, e := range []Event{{ID: 4, Type: "task.created", TaskID: 1}, {ID: 5, Type: "task.deleted", TaskID: 1}} {
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		}
+	}), Config{})
+
+	filter := EventFilter{AssigneeID: 2, Types: []string{"task.created", "task.deleted"}}
+	stream, err := c.Events(context.Background(), filter, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	for _, want := range []int64{4, 5} {
+		e, err := stream.Next()
+		if err != nil || e.ID != want {
+			t.Fatalf("ожидалось событие %d, получено %v, %v", want, e, err)
+		}
+	}
+	if _, err = stream.Next(); err != io.EOF {
+		t.Errorf("ожидался io.EOF, получено %v", err)
+	}
+	if stream.LastID != 5 {
+		t.Errorf("LastID = %d, ожидалось 5", stream.LastID)
+	}
+}
+
+func TestImportReport(t *testing.T) {
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("dryrun") != "1" || r.Header.Get("Content-Type") != "text/csv" {
+			http.Error(w, "неверный запрос", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(ImportReport{DryRun: true, Failed: 1, Rows: []storage.ImportRowResult{{Row: 2, Errors: []string{"не задан заголовок"}}}})
+	}), Config{})
+
+	report, err := c.ImportTasks(context.Background(), strings.NewReader("title\n\n"), ImportOptions{DryRun: true})
+	if !errors.Is(err, ErrUnprocessable) {
+		t.Errorf("ожидалась ErrUnprocessable, получено %v", err)
+	}
+	if report == nil || report.Failed != 1 || len(report.Rows) != 1 {
+		t.Errorf("отчет не получен: %+v", report)
+	}
+}
+
+// TestHandlers - сквозная проверка клиента с настоящими обработчиками и базой данных
+// из переменной окружения TASKMANAGER_TEST_DB.
+func TestHandlers(t *testing.T) {
+	connStr := os.Getenv("TASKMANAGER_TEST_DB")
+	if connStr == "" {
+		t.Skip("TASKMANAGER_TEST_DB не задана")
+	}
+	s, err := storage.New(connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	c := newTestClient(t, handlersService.New(s, nil, handlersService.Config{}).Router(), Config{})
+
+	user, err := c.CreateUser(ctx, &User{Name: "client-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.DeleteUser(ctx, user.ID)
+	label, err := c.CreateLabel(ctx, &Label{Name: "client-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.DeleteLabel(ctx, label.ID, true)
+
+	task, err := c.CreateTask(ctx, &Task{Title: "client-test", Priority: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.ID == 0 || task.Title != "client-test" || task.Priority != 2 {
+		t.Errorf("CreateTask вернул %+v", task)
+	}
+	_, err = c.AssignTask(ctx, task.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.AddTaskLabel(ctx, task.ID, label.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byLabel, err := c.TasksByLabel(ctx, label.ID)
+	if err != nil || len(byLabel) != 1 || byLabel[0].AssignedID != user.ID {
+		t.Errorf("TasksByLabel = %+v, %v", byLabel, err)
+	}
+
+	found := false
+	it := c.IterTasks(2)
+	for it.Next(ctx) {
+		found = found || it.Value().ID == task.ID
+	}
+	if it.Err() != nil || !found {
+		t.Errorf("IterTasks не вернул созданную задачу: %v", it.Err())
+	}
+
+	_, err = c.DeleteTask(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Task(ctx, task.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("удаленная задача: ожидалась ErrNotFound, получено %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Виды ошибок API для проверки через errors.Is.
+var (
+	ErrBadRequest    = errors.New("client: некорректный запрос")
+	ErrUnauthorized  = errors.New("client: требуется авторизация")
+	ErrForbidden     = errors.New("client: доступ запрещен")
+	ErrNotFound      = errors.New("client: не найдено")
+	ErrConflict      = errors.New("client: конфликт с текущим состоянием")
+	ErrUnprocessable = errors.New("client: данные не приняты")
+	ErrRateLimited   = errors.New("client: превышен лимит запросов")
+	ErrServer        = errors.New("client: ошибка сервера")
+)
+
+// Текст ошибки хранилища, которым сервер отвечает на запрос отсутствующей записи.
+const noRowsMessage = "no rows in result set"
+
+// Заголовок ответа с ID запроса.
+const requestIDHeader = "X-Request-ID"
+
+// Максимальный размер текста ошибки, читаемого из ответа.
+const maxErrorSize = 4 << 10
+
+// Error - ответ сервера с кодом ошибки.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Текст ошибки из тела ответа
+	Message string
+	// ID запроса из заголовка X-Request-ID ответа, по нему запрос находится в журнале сервера
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("client: %s %s: %d %s: %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+	if e.RequestID != "" {
+		msg += " (request_id: " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Is - сопоставляет код ответа с видом ошибки. Отсутствие записи сервер в части эндпоинтов
+// возвращает как 500 с текстом ошибки хранилища, такой ответ тоже считается ErrNotFound
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || e.Message == noRowsMessage
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrUnprocessable:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500 && e.Message != noRowsMessage
+	}
+	return false
+}
+
+// responseError - ошибка из ответа сервера с кодом ошибки
+func responseError(req request, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorSize))
+	id := resp.Header.Get(requestIDHeader)
+	// Сервер дописывает ID запроса последней строкой текста ошибки
+	text := strings.TrimSpace(string(msg))
+	if id != "" {
+		text = strings.TrimSpace(strings.TrimSuffix(text, "request_id: "+id))
+	}
+	return &Error{
+		Method:     req.method,
+		Path:       req.path,
+		StatusCode: resp.StatusCode,
+		Message:    text,
+		RequestID:  id,
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// EventFilter - фильтр потока событий, нулевые поля не ограничивают выборку.
+type EventFilter struct {
+	AssigneeID int
+	AuthorID   int
+	LabelID    int
+	// Типы событий storage.EventTask*
+	Types []string
+}
+
+// EventStream - поток событий задач (Server-Sent Events).
+type EventStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	// ID последнего полученного события, для продолжения потока после обрыва
+	LastID int64
+}
+
+// Events - подписывается на события задач. lastEventID - ID последнего полученного события,
+// сервер повторит пропущенные после него события; 0 - только новые.
+func (c *Client) Events(ctx context.Context, filter EventFilter, lastEventID int64) (*EventStream, error) {
+	q := url.Values{}
+	for name, v := range map[string]int{"assignee": filter.AssigneeID, "author": filter.AuthorID, "label": filter.LabelID} {
+		if v != 0 {
+			q.Set(name, strconv.Itoa(v))
+		}
+	}
+	if len(filter.Types) > 0 {
+		q.Set("type", strings.Join(filter.Types, ","))
+	}
+	if lastEventID != 0 {
+		q.Set("lastEventId", strconv.FormatInt(lastEventID, 10))
+	}
+
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/events", query: q, idempotent: true, stream: true})
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	// Событие содержит задачу целиком вместе с описанием
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	return &EventStream{body: resp.Body, scanner: scanner, LastID: lastEventID}, nil
+}
+
+// Next - ждет следующее событие. При закрытии потока сервером возвращает io.EOF,
+// после этого можно переподключиться с LastID.
+func (s *EventStream) Next() (*Event, error) {
+	var data strings.Builder
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			// Пустая строка завершает сообщение, сообщения без данных (keep-alive) пропускаются
+			if data.Len() == 0 {
+				continue
+			}
+			e := &Event{}
+			err := json.Unmarshal([]byte(data.String()), e)
+			if err != nil {
+				return nil, fmt.Errorf("client: ошибка разбора события: %w", err)
+			}
+			s.LastID = e.ID
+			return e, nil
+		}
+		field, value, _ := strings.Cut(line, ":")
+		if field == "data" {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+		}
+	}
+	err := s.scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	return nil, err
+}
+
+// Close - закрывает поток.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
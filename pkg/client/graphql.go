@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// GraphQLError - ошибка выполнения GraphQL запроса.
+type GraphQLError struct {
+	Message string
+	Path    []any `json:"path,omitempty"`
+}
+
+// GraphQLErrors - ошибки из ответа GraphQL. Данные, полученные вместе с ошибками, все равно
+// декодируются в out.
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i := range e {
+		messages[i] = e[i].Message
+	}
+	return "client: graphql: " + strings.Join(messages, "; ")
+}
+
+// GraphQL - выполняет запрос к /graphql и декодирует поле data ответа в out.
+// Запрос может содержать мутации, поэтому он не повторяется.
+func (c *Client) GraphQL(ctx context.Context, query string, variables map[string]any, out any) error {
+	body := struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables,omitempty"`
+	}{query, variables}
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors GraphQLErrors   `json:"errors"`
+	}
+	err := c.call(ctx, request{method: http.MethodPost, path: "/graphql", body: body}, &resp)
+	if err != nil {
+		return err
+	}
+
+	if out != nil && len(resp.Data) > 0 && string(resp.Data) != "null" {
+		err = json.Unmarshal(resp.Data, out)
+		if err != nil {
+			return err
+		}
+	}
+	if len(resp.Errors) > 0 {
+		return resp.Errors
+	}
+	return nil
+}
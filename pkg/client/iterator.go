@@ -0,0 +1,83 @@
+package client
+
+import "context"
+
+// Размер страницы, если при создании итератора он не задан.
+const DefaultPageSize = 100
+
+// Iterator - постраничный обход списка по возрастанию ID. Следующая страница запрашивается,
+// когда закончилась текущая.
+//
+//	it := c.IterTasks(100)
+//	for it.Next(ctx) {
+//		task := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	pageSize int
+	fetch    func(ctx context.Context, after, limit int) ([]T, error)
+	id       func(*T) int
+
+	page  []T
+	i     int
+	after int
+	// Последняя страница получена
+	last bool
+	err  error
+}
+
+func newIterator[T any](pageSize int, fetch func(ctx context.Context, after, limit int) ([]T, error),
+	id func(*T) int) *Iterator[T] {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return &Iterator[T]{pageSize: pageSize, fetch: fetch, id: id, i: -1}
+}
+
+// Next - переходит к следующей записи, false - записи закончились или произошла ошибка.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.i+1 < len(it.page) {
+		it.i++
+		return true
+	}
+	if it.last {
+		return false
+	}
+
+	page, err := it.fetch(ctx, it.after, it.pageSize)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.page, it.i = page, 0
+	it.last = len(page) < it.pageSize
+	if len(page) == 0 {
+		return false
+	}
+	it.after = it.id(&page[len(page)-1])
+	return true
+}
+
+// Value - текущая запись.
+func (it *Iterator[T]) Value() T {
+	return it.page[it.i]
+}
+
+// Err - ошибка, прервавшая обход.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All - оставшиеся записи одним списком.
+func (it *Iterator[T]) All(ctx context.Context) ([]T, error) {
+	var result []T
+	for it.Next(ctx) {
+		result = append(result, it.Value())
+	}
+	return result, it.Err()
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+//-------------------Метки-------------------------
+
+// AllLabels - все метки одним запросом.
+func (c *Client) AllLabels(ctx context.Context) ([]Label, error) {
+	return list[Label](ctx, c, get("/alllabels", nil))
+}
+
+// LabelsPage - метки с ID больше after, не более limit (0 - все).
+func (c *Client) LabelsPage(ctx context.Context, after, limit int) ([]Label, error) {
+	return list[Label](ctx, c, get("/alllabels", pageQuery(after, limit)))
+}
+
+// IterLabels - постраничный обход всех меток.
+func (c *Client) IterLabels(pageSize int) *Iterator[Label] {
+	return newIterator(pageSize, c.LabelsPage, func(l *Label) int { return l.ID })
+}
+
+// Label - метка по ID.
+func (c *Client) Label(ctx context.Context, id int) (*Label, error) {
+	return one[Label](ctx, c, get("/getlabel", params("id", strconv.Itoa(id))), fmt.Sprintf("метка %d", id))
+}
+
+// CreateLabel - создает метку. Название вида priority::high создает метку high в группе priority.
+func (c *Client) CreateLabel(ctx context.Context, l *Label) (*Label, error) {
+	result := &Label{}
+	err := c.call(ctx, once(request{method: http.MethodPost, path: "/createlabel", body: l}), result)
+	return result, err
+}
+
+// UpdateLabel - заменяет все поля метки.
+func (c *Client) UpdateLabel(ctx context.Context, l *Label) (*Label, error) {
+	result := &Label{}
+	err := c.call(ctx, request{method: http.MethodPut, path: "/updatelabel", body: l, idempotent: true}, result)
+	return result, err
+}
+
+// DeleteLabel - удаляет метку и возвращает ее. Метка, привязанная к задачам, удаляется
+// только при force, иначе возвращается ErrConflict.
+func (c *Client) DeleteLabel(ctx context.Context, id int, force bool) (*Label, error) {
+	query := params("id", strconv.Itoa(id))
+	if force {
+		query.Set("force", "1")
+	}
+	result := &Label{}
+	err := c.call(ctx, request{method: http.MethodGet, path: "/deletelabel", query: query}, result)
+	return result, err
+}
+
+// MergeLabels - переносит задачи с меток sourceIDs на метку targetID и удаляет исходные
+// метки. При dryRun ничего не сохраняется, отчет показывает, что было бы сделано.
+func (c *Client) MergeLabels(ctx context.Context, targetID int, sourceIDs []int, dryRun bool) (*LabelMergeReport, error) {
+	var query url.Values
+	if dryRun {
+		query = params("dryrun", "1")
+	}
+	result := &LabelMergeReport{}
+	err := c.call(ctx, once(request{
+		method: http.MethodPost,
+		path:   "/mergelabels",
+		query:  query,
+		body: struct {
+			TargetID  int
+			SourceIDs []int
+		}{targetID, sourceIDs},
+	}), result)
+	return result, err
+}
+
+// DuplicateLabels - группы меток, полные имена которых совпадают без учета регистра.
+func (c *Client) DuplicateLabels(ctx context.Context) ([]DuplicateLabels, error) {
+	return list[DuplicateLabels](ctx, c, get("/labelduplicates", nil))
+}
+
+// LabelsUsage - метки с количеством задач. scope nil - все метки, иначе только метки
+// группы *scope, пустая строка - метки без группы.
+func (c *Client) LabelsUsage(ctx context.Context, scope *string) ([]LabelUsage, error) {
+	var query url.Values
+	if scope != nil {
+		query = params("scope", *scope)
+	}
+	return list[LabelUsage](ctx, c, get("/labelusage", query))
+}
+
+// LabelScopes - группы меток с количеством меток и задач.
+func (c *Client) LabelScopes(ctx context.Context) ([]LabelScope, error) {
+	return list[LabelScope](ctx, c, get("/labelscopes", nil))
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"TaskManager/pkg/ical"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+//-------------------Уведомления-------------------------
+
+// Notifications - уведомления пользователя, unreadOnly - только непрочитанные.
+func (c *Client) Notifications(ctx context.Context, userID int, unreadOnly bool) ([]Notification, error) {
+	q := params("uid", strconv.Itoa(userID))
+	if unreadOnly {
+		q.Set("unread", "1")
+	}
+	return list[Notification](ctx, c, get("/notifications", q))
+}
+
+// ReadNotification - отмечает уведомление прочитанным.
+func (c *Client) ReadNotification(ctx context.Context, id int) (*Notification, error) {
+	return one[Notification](ctx, c, get("/readnotification", params("id", strconv.Itoa(id))), fmt.Sprintf("уведомление %d", id))
+}
+
+// count - ответ с количеством записей
+type count struct {
+	Count int64
+}
+
+// ReadAllNotifications - отмечает прочитанными все уведомления пользователя, возвращает их количество.
+func (c *Client) ReadAllNotifications(ctx context.Context, userID int) (int64, error) {
+	var result count
+	err := c.call(ctx, get("/readallnotifications", params("uid", strconv.Itoa(userID))), &result)
+	return result.Count, err
+}
+
+// UnreadNotificationsCount - количество непрочитанных уведомлений пользователя.
+func (c *Client) UnreadNotificationsCount(ctx context.Context, userID int) (int, error) {
+	var result count
+	err := c.call(ctx, get("/unreadcount", params("uid", strconv.Itoa(userID))), &result)
+	return int(result.Count), err
+}
+
+// NotificationPrefs - настройки уведомлений пользователя.
+func (c *Client) NotificationPrefs(ctx context.Context, userID int) ([]NotificationPref, error) {
+	return list[NotificationPref](ctx, c, get("/notificationprefs", params("uid", strconv.Itoa(userID))))
+}
+
+// UpdateNotificationPrefs - меняет настройки уведомлений, возвращает все настройки пользователя.
+func (c *Client) UpdateNotificationPrefs(ctx context.Context, userID int, prefs []NotificationPref) ([]NotificationPref, error) {
+	var result []NotificationPref
+	err := c.call(ctx, request{
+		method:     http.MethodPut,
+		path:       "/updatenotificationprefs",
+		query:      params("uid", strconv.Itoa(userID)),
+		body:       prefs,
+		idempotent: true,
+	}, &result)
+	return result, err
+}
+
+//-------------------Календарь-------------------------
+
+// Виды записей календарной ленты.
+const (
+	CalendarTodo  = ical.KindTodo
+	CalendarEvent = ical.KindEvent
+)
+
+// CalendarFeed - ссылки на календарную ленту пользователя.
+type CalendarFeed struct {
+	Token string
+	// Лента задач (VTODO)
+	URL string
+	// Лента событий (VEVENT)
+	EventsURL string
+}
+
+// ResetCalendarToken - выдает пользователю новый токен календарной ленты, прежние ссылки перестают работать.
+// Требует токена администратора в Config.Token.
+func (c *Client) ResetCalendarToken(ctx context.Context, userID int) (*CalendarFeed, error) {
+	result := &CalendarFeed{}
+	err := c.call(ctx, request{method: http.MethodPost, path: "/calendartoken", query: params("uid", strconv.Itoa(userID))}, result)
+	return result, err
+}
+
+// Calendar - лента iCalendar по токену, kind - CalendarTodo или CalendarEvent (пустой - задачи).
+// Тело ответа закрывает вызывающий.
+func (c *Client) Calendar(ctx context.Context, token, kind string) (io.ReadCloser, error) {
+	q := url.Values{"token": {token}}
+	if kind != "" {
+		q.Set("kind", kind)
+	}
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/calendar.ics", query: q, idempotent: true, stream: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"TaskManager/pkg/jsonPatch"
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// Patch - частичное изменение записи: JSON Merge Patch или JSON Patch.
+type Patch struct {
+	contentType string
+	body        any
+}
+
+// MergePatch - изменение JSON Merge Patch (RFC 7396): поля v заменяют поля записи,
+// nil удаляет поле. Например, map[string]any{"Title": "Новый заголовок"}
+func MergePatch(v any) Patch {
+	return Patch{contentType: jsonPatch.MergePatchType, body: v}
+}
+
+// PatchOp - операция JSON Patch (RFC 6902). Поля записей адресуются путем вида /Title.
+type PatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// JSONPatch - изменение JSON Patch (RFC 6902): операции выполняются по порядку,
+// при ошибке любой из них запись не изменяется
+func JSONPatch(ops ...PatchOp) Patch {
+	return Patch{contentType: jsonPatch.JSONPatchType, body: ops}
+}
+
+// patch - запрос PATCH с ключом идемпотентности, поэтому его можно повторить
+func patch[T any](ctx context.Context, c *Client, path string, id int, p Patch) (*T, error) {
+	result := new(T)
+	err := c.call(ctx, once(request{
+		method:      http.MethodPatch,
+		path:        path,
+		query:       params("id", strconv.Itoa(id)),
+		body:        p.body,
+		contentType: p.contentType,
+	}), result)
+	return result, err
+}
+
+// PatchTask - изменяет только переданные поля задачи.
+func (c *Client) PatchTask(ctx context.Context, id int, p Patch) (*Task, error) {
+	return patch[Task](ctx, c, "/patchtask", id, p)
+}
+
+// PatchUser - изменяет только переданные поля пользователя.
+func (c *Client) PatchUser(ctx context.Context, id int, p Patch) (*User, error) {
+	return patch[User](ctx, c, "/patchuser", id, p)
+}
+
+// PatchLabel - изменяет только переданные поля метки.
+func (c *Client) PatchLabel(ctx context.Context, id int, p Patch) (*Label, error) {
+	return patch[Label](ctx, c, "/patchlabel", id, p)
+}
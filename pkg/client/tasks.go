@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+//-------------------Задачи-------------------------
+
+// AllTasks - все задачи одним запросом. Для больших списков удобнее IterTasks.
+func (c *Client) AllTasks(ctx context.Context) ([]Task, error) {
+	return list[Task](ctx, c, get("/alltasks", nil))
+}
+
+// TasksPage - задачи с ID больше after, не более limit (0 - все).
+func (c *Client) TasksPage(ctx context.Context, after, limit int) ([]Task, error) {
+	return list[Task](ctx, c, get("/alltasks", pageQuery(after, limit)))
+}
+
+// IterTasks - постраничный обход всех задач, pageSize задач за запрос.
+func (c *Client) IterTasks(pageSize int) *Iterator[Task] {
+	return newIterator(pageSize, c.TasksPage, func(t *Task) int { return t.ID })
+}
+
+// Task - задача по ID.
+func (c *Client) Task(ctx context.Context, id int) (*Task, error) {
+	return one[Task](ctx, c, get("/gettask", params("id", strconv.Itoa(id))), fmt.Sprintf("задача %d", id))
+}
+
+// Tasks - задачи по ID задачи и ID автора, 0 - без ограничения.
+func (c *Client) Tasks(ctx context.Context, taskID, authorID int) ([]Task, error) {
+	return list[Task](ctx, c, get("/taskby", params("tid", strconv.Itoa(taskID), "aid", strconv.Itoa(authorID))))
+}
+
+// TasksByAuthor - задачи автора.
+func (c *Client) TasksByAuthor(ctx context.Context, authorID int) ([]Task, error) {
+	return list[Task](ctx, c, get("/taskbyauthor", params("id", strconv.Itoa(authorID))))
+}
+
+// TasksByLabel - задачи с меткой.
+func (c *Client) TasksByLabel(ctx context.Context, labelID int) ([]Task, error) {
+	return list[Task](ctx, c, get("/taskbylabel", params("id", strconv.Itoa(labelID))))
+}
+
+// CreateTask - создает задачу. Сервер учитывает заголовок, описание, срок и приоритет.
+func (c *Client) CreateTask(ctx context.Context, t *Task) (*Task, error) {
+	result := &Task{}
+	err := c.call(ctx, once(request{method: http.MethodPost, path: "/createtask", body: t}), result)
+	return result, err
+}
+
+// CreateTasks - создает несколько задач одним запросом.
+func (c *Client) CreateTasks(ctx context.Context, tasks []Task) ([]Task, error) {
+	var result []Task
+	err := c.call(ctx, once(request{method: http.MethodPost, path: "/createtasks", body: tasks}), &result)
+	return result, err
+}
+
+// UpdateTask - обновляет заголовок, описание, срок, приоритет и время закрытия задачи.
+func (c *Client) UpdateTask(ctx context.Context, t *Task) (*Task, error) {
+	result := &Task{}
+	err := c.call(ctx, request{method: http.MethodPut, path: "/updatetask", body: t, idempotent: true}, result)
+	return result, err
+}
+
+// DeleteTask - удаляет задачу и возвращает ее.
+func (c *Client) DeleteTask(ctx context.Context, id int) (*Task, error) {
+	result := &Task{}
+	err := c.call(ctx, request{method: http.MethodGet, path: "/deletetask", query: params("id", strconv.Itoa(id))}, result)
+	return result, err
+}
+
+// AssignTask - назначает исполнителя задачи, userID 0 снимает исполнителя.
+func (c *Client) AssignTask(ctx context.Context, taskID, userID int) (*Task, error) {
+	result := &Task{}
+	err := c.call(ctx, get("/assigntask", params("tid", strconv.Itoa(taskID), "uid", strconv.Itoa(userID))), result)
+	return result, err
+}
+
+// TaskLabels - метки задачи.
+func (c *Client) TaskLabels(ctx context.Context, taskID int) ([]Label, error) {
+	return list[Label](ctx, c, get("/tasklabels", params("id", strconv.Itoa(taskID))))
+}
+
+// AddTaskLabel - добавляет метку задаче, повторное добавление ничего не меняет.
+func (c *Client) AddTaskLabel(ctx context.Context, taskID, labelID int) (*Task, error) {
+	result := &Task{}
+	err := c.call(ctx, get("/addtasklabel", params("tid", strconv.Itoa(taskID), "lid", strconv.Itoa(labelID))), result)
+	return result, err
+}
+
+// RemoveTaskLabel - снимает метку с задачи.
+func (c *Client) RemoveTaskLabel(ctx context.Context, taskID, labelID int) (*Task, error) {
+	result := &Task{}
+	err := c.call(ctx, get("/removetasklabel", params("tid", strconv.Itoa(taskID), "lid", strconv.Itoa(labelID))), result)
+	return result, err
+}
+
+// TaskFilter - фильтр выгрузки задач, 0 - без ограничения.
+type TaskFilter struct {
+	TaskID     int
+	AuthorID   int
+	AssigneeID int
+	LabelID    int
+}
+
+// ExportTasks - выгружает задачи в CSV. columns - колонки выгрузки, пустой список - колонки по умолчанию.
+// Тело ответа закрывает вызывающий.
+func (c *Client) ExportTasks(ctx context.Context, filter TaskFilter, columns []string) (io.ReadCloser, error) {
+	q := url.Values{}
+	for name, v := range map[string]int{"tid": filter.TaskID, "aid": filter.AuthorID, "uid": filter.AssigneeID, "lid": filter.LabelID} {
+		if v != 0 {
+			q.Set(name, strconv.Itoa(v))
+		}
+	}
+	if len(columns) > 0 {
+		q.Set("columns", strings.Join(columns, ","))
+	}
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/exporttasks", query: q, idempotent: true, stream: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ImportOptions - параметры импорта задач из CSV.
+type ImportOptions struct {
+	// Только проверить, ничего не сохраняя
+	DryRun bool
+	// Создать отсутствующих пользователей и метки
+	CreateMissing bool
+}
+
+// ImportTasks - импортирует задачи из CSV. Если в строках есть ошибки, ничего не сохраняется,
+// а отчет возвращается вместе с ошибкой ErrUnprocessable.
+func (c *Client) ImportTasks(ctx context.Context, csv io.Reader, opts ImportOptions) (*ImportReport, error) {
+	q := url.Values{}
+	if opts.DryRun {
+		q.Set("dryrun", "1")
+	}
+	if opts.CreateMissing {
+		q.Set("create", "1")
+	}
+	return report[ImportReport](ctx, c, request{method: http.MethodPost, path: "/importtasks", query: q, body: csv, contentType: "text/csv"})
+}
+
+// BulkRequest - массовая операция над задачами, выбранными списком IDs или фильтром
+// вида "assignee=2 state=open" (условия id, author, assignee, label, state).
+type BulkRequest struct {
+	IDs    []int  `json:"IDs,omitempty"`
+	Filter string `json:"Filter,omitempty"`
+	// storage.BulkAtomic (по умолчанию) или storage.BulkBestEffort
+	Mode string `json:"Mode,omitempty"`
+	// Изменения, только для UpdateTasks
+	TaskChanges
+	// Только показать результат, ничего не сохраняя
+	DryRun bool `json:"-"`
+}
+
+// query - параметры запроса массовой операции
+func (b *BulkRequest) query() url.Values {
+	q := url.Values{}
+	if b.DryRun {
+		q.Set("dryrun", "1")
+	}
+	return q
+}
+
+// UpdateTasks - изменяет выбранные задачи. Если в режиме atomic в задачах есть ошибки,
+// ничего не сохраняется, а отчет возвращается вместе с ошибкой ErrUnprocessable.
+func (c *Client) UpdateTasks(ctx context.Context, req *BulkRequest) (*BulkReport, error) {
+	return report[BulkReport](ctx, c, once(request{method: http.MethodPut, path: "/updatetasks", query: req.query(), body: req}))
+}
+
+// DeleteTasks - удаляет выбранные задачи. Ошибки обрабатываются как в UpdateTasks.
+func (c *Client) DeleteTasks(ctx context.Context, req *BulkRequest) (*BulkReport, error) {
+	return report[BulkReport](ctx, c, once(request{method: http.MethodPost, path: "/deletetasks", query: req.query(), body: req}))
+}
+
+// pageQuery - параметры страницы списка
+func pageQuery(after, limit int) url.Values {
+	q := url.Values{}
+	if after > 0 {
+		q.Set("after", strconv.Itoa(after))
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	return q
+}
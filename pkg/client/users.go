@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+//-------------------Пользователи-------------------------
+
+// AllUsers - все пользователи одним запросом.
+func (c *Client) AllUsers(ctx context.Context) ([]User, error) {
+	return list[User](ctx, c, get("/allusers", nil))
+}
+
+// UsersPage - пользователи с ID больше after, не более limit (0 - все).
+func (c *Client) UsersPage(ctx context.Context, after, limit int) ([]User, error) {
+	return list[User](ctx, c, get("/allusers", pageQuery(after, limit)))
+}
+
+// IterUsers - постраничный обход всех пользователей.
+func (c *Client) IterUsers(pageSize int) *Iterator[User] {
+	return newIterator(pageSize, c.UsersPage, func(u *User) int { return u.ID })
+}
+
+// User - пользователь по ID.
+func (c *Client) User(ctx context.Context, id int) (*User, error) {
+	return one[User](ctx, c, get("/getuser", params("id", strconv.Itoa(id))), fmt.Sprintf("пользователь %d", id))
+}
+
+// CreateUser - создает пользователя.
+func (c *Client) CreateUser(ctx context.Context, u *User) (*User, error) {
+	result := &User{}
+	err := c.call(ctx, once(request{method: http.MethodPost, path: "/createuser", body: u}), result)
+	return result, err
+}
+
+// UpdateUser - переименовывает пользователя.
+func (c *Client) UpdateUser(ctx context.Context, u *User) (*User, error) {
+	result := &User{}
+	err := c.call(ctx, request{method: http.MethodPut, path: "/updateuser", body: u, idempotent: true}, result)
+	return result, err
+}
+
+// UpdateUserMail - обновляет адрес, язык писем и отказ от почтовых уведомлений.
+func (c *Client) UpdateUserMail(ctx context.Context, u *User) (*User, error) {
+	result := &User{}
+	err := c.call(ctx, request{method: http.MethodPut, path: "/updateusermail", body: u, idempotent: true}, result)
+	return result, err
+}
+
+// DeleteUser - удаляет пользователя и возвращает его. Пользователя, который автор или
+// исполнитель задач, удалить нельзя (ErrConflict), его нужно деактивировать через OffboardUser.
+func (c *Client) DeleteUser(ctx context.Context, id int) (*User, error) {
+	result := &User{}
+	err := c.call(ctx, request{method: http.MethodGet, path: "/deleteuser", query: params("id", strconv.Itoa(id))}, result)
+	return result, err
+}
+
+// OffboardUser - деактивирует пользователя и переназначает его открытые задачи на reassignTo
+// (0 - задачи остаются без исполнителя). При dryRun ничего не сохраняется, отчет показывает,
+// что было бы сделано.
+func (c *Client) OffboardUser(ctx context.Context, id, reassignTo int, dryRun bool) (*UserOffboardReport, error) {
+	var query url.Values
+	if dryRun {
+		query = params("dryrun", "1")
+	}
+	result := &UserOffboardReport{}
+	err := c.call(ctx, once(request{
+		method: http.MethodPost,
+		path:   "/offboarduser",
+		query:  query,
+		body: struct {
+			UserID     int
+			ReassignTo int
+		}{id, reassignTo},
+	}), result)
+	return result, err
+}
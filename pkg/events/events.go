@@ -0,0 +1,371 @@
+package events
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/metrics"
+	"TaskManager/pkg/storage"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Размер буфера повтора по умолчанию.
+const DefaultReplaySize = 1000
+
+// Количество событий, дочитываемых из БД за один запрос после переподключения.
+const backfillBatch = 500
+
+// Период удаления старых событий.
+const pruneInterval = time.Hour
+
+// Размер канала подписчика; подписчик, не успевающий читать события, отключается.
+const subscriberBuffer = 64
+
+// Filter - фильтр событий подписчика. Нулевые значения полей означают "любой".
+type Filter struct {
+	AssigneeID int
+	AuthorID   int
+	LabelID    int
+	Types      []string
+}
+
+// Match - проверяет, подходит ли событие под фильтр.
+// Для событий изменения задачи учитывается и предыдущее состояние,
+// чтобы подписчик узнал о переназначении задачи с него на другого.
+func (f Filter) Match(e *storage.Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == e.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.AssigneeID != 0 && e.Task.AssignedID != f.AssigneeID &&
+		(e.Previous == nil || e.Previous.AssignedID != f.AssigneeID) {
+		return false
+	}
+	if f.AuthorID != 0 && e.Task.AuthorID != f.AuthorID {
+		return false
+	}
+	if f.LabelID != 0 {
+		found := false
+		for _, id := range e.Labels {
+			if id == f.LabelID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Subscription - подписка на поток событий.
+type Subscription struct {
+	// События, пропущенные клиентом и найденные в буфере повтора.
+	Replay []storage.Event
+	// Канал новых событий, закрывается при отписке или переполнении.
+	C <-chan storage.Event
+
+	c      chan storage.Event
+	filter Filter
+	hub    *Hub
+	once   sync.Once
+}
+
+// Close - отписывает подписчика от потока событий.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	delete(s.hub.subs, s)
+	s.hub.mu.Unlock()
+	s.close()
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() { close(s.c) })
+}
+
+// Hub - раздает события задач подписчикам текущего экземпляра сервера.
+// События приходят из Postgres через LISTEN/NOTIFY, поэтому подписчики
+// получают изменения, сделанные любым экземпляром.
+type Hub struct {
+	storage *storage.Storage
+
+	mu     sync.RWMutex
+	replay []storage.Event
+	size   int
+	subs   map[*Subscription]struct{}
+	// Опубликованные события: все ID не больше low и ID из seen. События фиксируются
+	// не в порядке ID, поэтому после переподключения дочитывается все, что после low
+	low  int64
+	seen map[int64]struct{}
+	// Наибольший опубликованный ID
+	last int64
+}
+
+// Конструктор, принимает хранилище и размер буфера повтора.
+func New(storage *storage.Storage, replaySize int) *Hub {
+	if replaySize <= 0 {
+		replaySize = DefaultReplaySize
+	}
+	return &Hub{
+		storage: storage,
+		size:    replaySize,
+		subs:    make(map[*Subscription]struct{}),
+		seen:    make(map[int64]struct{}),
+	}
+}
+
+// Subscribe - подписывает на события по фильтру. Если lastID не равен 0,
+// в Replay возвращаются события из буфера, пришедшие после события lastID.
+func (h *Hub) Subscribe(filter Filter, lastID int64) *Subscription {
+	c := make(chan storage.Event, subscriberBuffer)
+	sub := &Subscription{
+		C:      c,
+		c:      c,
+		filter: filter,
+		hub:    h,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if lastID != 0 {
+		sub.Replay = h.replayAfter(filter, lastID)
+	}
+	h.subs[sub] = struct{}{}
+
+	return sub
+}
+
+// replayAfter - возвращает события буфера после lastID. События в буфере идут в порядке
+// фиксации транзакций, поэтому ищется позиция lastID; если она уже вытеснена из буфера,
+// возвращаются все события с большим ID.
+func (h *Hub) replayAfter(filter Filter, lastID int64) []storage.Event {
+	start := -1
+	for i := range h.replay {
+		if h.replay[i].ID == lastID {
+			start = i + 1
+			break
+		}
+	}
+
+	var result []storage.Event
+	for i := range h.replay {
+		e := &h.replay[i]
+		if start >= 0 && i < start {
+			continue
+		}
+		if start < 0 && e.ID <= lastID {
+			continue
+		}
+		if filter.Match(e) {
+			result = append(result, *e)
+		}
+	}
+	return result
+}
+
+// Publish - добавляет событие в буфер повтора и рассылает подписчикам.
+func (h *Hub) Publish(e storage.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.markPublished(e.ID)
+	h.replay = append(h.replay, e)
+	if len(h.replay) > h.size {
+		h.replay = h.replay[len(h.replay)-h.size:]
+	}
+
+	for sub := range h.subs {
+		if !sub.filter.Match(&e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			// Подписчик не успевает, отключаем его: клиент переподключится с Last-Event-ID
+			logger.Warn("Подписчик отключен из-за переполнения очереди событий")
+			delete(h.subs, sub)
+			sub.close()
+		}
+	}
+}
+
+// markPublished - отмечает событие id опубликованным и сдвигает low по непрерывному ряду
+// опубликованных ID. Пропуск в ID остается и от откаченной транзакции, поэтому его ждут
+// не дольше, чем опубликуется size событий после него
+func (h *Hub) markPublished(id int64) {
+	if id > h.last {
+		h.last = id
+	}
+	if id <= h.low {
+		return
+	}
+	h.seen[id] = struct{}{}
+	if len(h.seen) > h.size && h.last-int64(h.size) > h.low {
+		h.low = h.last - int64(h.size)
+		for seen := range h.seen {
+			if seen <= h.low {
+				delete(h.seen, seen)
+			}
+		}
+	}
+	for {
+		if _, ok := h.seen[h.low+1]; !ok {
+			return
+		}
+		delete(h.seen, h.low+1)
+		h.low++
+	}
+}
+
+// published - проверяет, что событие id уже опубликовано: уведомление о нем может прийти
+// и после того, как событие дочитано из БД
+func (h *Hub) published(id int64) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.seen[id]
+	return ok || id <= h.low
+}
+
+// Listen - слушает канал storage.EventsChannel и публикует события до отмены ctx.
+// При потере соединения переподключается с паузой и дочитывает из БД события,
+// уведомления о которых пришли, пока соединения не было.
+func (h *Hub) Listen(ctx context.Context) {
+	for {
+		err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.ErrorContext(ctx, "Ошибка при прослушивании событий: %s", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second * 5):
+		}
+	}
+}
+
+func (h *Hub) listen(ctx context.Context) error {
+	conn, err := h.storage.DB.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "LISTEN "+storage.EventsChannel)
+	if err != nil {
+		return err
+	}
+	// Уведомления приходят только после LISTEN, поэтому пропущенное до него дочитывается
+	// уже после подписки
+	err = h.backfill(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		id, err := strconv.ParseInt(n.Payload, 10, 64)
+		if err != nil {
+			logger.WarnContext(ctx, "Некорректный ID события: %s", n.Payload)
+			continue
+		}
+
+		if h.published(id) {
+			continue
+		}
+		e, err := h.storage.EventById(ctx, id)
+		if err != nil {
+			logger.ErrorContext(ctx, "Ошибка при получении события %d: %s", id, err.Error())
+			continue
+		}
+
+		observeEvent(e)
+		h.Publish(*e)
+	}
+}
+
+// backfill - публикует из БД события после low, которые еще не опубликованы. При первом
+// подключении публиковать нечего, low становится ID последнего события в БД
+func (h *Hub) backfill(ctx context.Context) error {
+	h.mu.RLock()
+	after, last := h.low, h.last
+	h.mu.RUnlock()
+	if last == 0 {
+		last, err := h.storage.LastEventID(ctx)
+		if err != nil {
+			return err
+		}
+		h.mu.Lock()
+		if h.last == 0 {
+			h.low, h.last = last, last
+		}
+		h.mu.Unlock()
+		return nil
+	}
+	for {
+		events, err := h.storage.EventsAfter(ctx, after, backfillBatch)
+		if err != nil {
+			return err
+		}
+		for i := range events {
+			after = events[i].ID
+			if h.published(after) {
+				continue
+			}
+			observeEvent(&events[i])
+			h.Publish(events[i])
+		}
+		if len(events) < backfillBatch {
+			return nil
+		}
+	}
+}
+
+// Prune - раз в pruneInterval удаляет события старше retention до отмены ctx.
+// Буфер повтора и календарные ленты от этого не меняются
+func (h *Hub) Prune(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		deleted, err := h.storage.DeleteEvents(ctx, time.Now().Add(-retention))
+		if err != nil && ctx.Err() == nil {
+			logger.ErrorContext(ctx, "Ошибка удаления старых событий: %s", err.Error())
+		} else if deleted > 0 {
+			logger.InfoContext(ctx, "Удалено старых событий: %d", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// observeEvent - учитывает событие в счетчиках созданных и закрытых задач
+func observeEvent(e *storage.Event) {
+	switch e.Type {
+	case storage.EventTaskCreated:
+		metrics.ObserveTaskEvent(metrics.TaskEvent{Created: true, ClosedAfter: e.Task.Closed})
+	case storage.EventTaskUpdated:
+		if e.Previous != nil {
+			metrics.ObserveTaskEvent(metrics.TaskEvent{ClosedBefore: e.Previous.Closed, ClosedAfter: e.Task.Closed})
+		}
+	}
+}
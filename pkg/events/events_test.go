@@ -0,0 +1,108 @@
+package events
+
+import (
+	"TaskManager/pkg/storage"
+	"testing"
+)
+
+func TestFilter_Match(t *testing.T) {
+	event := &storage.Event{
+		ID:       1,
+		Type:     storage.EventTaskUpdated,
+		Task:     storage.Task{ID: 1, AuthorID: 1, AssignedID: 2},
+		Previous: &storage.Task{ID: 1, AuthorID: 1, AssignedID: 3},
+		Labels:   []int{5, 7},
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{name: "Пустой фильтр", filter: Filter{}, want: true},
+		{name: "Исполнитель", filter: Filter{AssigneeID: 2}, want: true},
+		{name: "Прежний исполнитель", filter: Filter{AssigneeID: 3}, want: true},
+		{name: "Чужой исполнитель", filter: Filter{AssigneeID: 4}, want: false},
+		{name: "Автор", filter: Filter{AuthorID: 1}, want: true},
+		{name: "Метка", filter: Filter{LabelID: 7}, want: true},
+		{name: "Нет метки", filter: Filter{LabelID: 6}, want: false},
+		{name: "Тип", filter: Filter{Types: []string{storage.EventTaskCreated, storage.EventTaskUpdated}}, want: true},
+		{name: "Другой тип", filter: Filter{Types: []string{storage.EventTaskDeleted}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(event); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHub_Subscribe(t *testing.T) {
+	h := New(nil, 3)
+	for id := int64(1); id <= 5; id++ {
+		h.Publish(storage.Event{ID: id, Type: storage.EventTaskCreated})
+	}
+
+	tests := []struct {
+		name   string
+		lastID int64
+		want   []int64
+	}{
+		{name: "Без Last-Event-ID", lastID: 0, want: nil},
+		{name: "Событие в буфере", lastID: 3, want: []int64{4, 5}},
+		{name: "Событие вытеснено из буфера", lastID: 1, want: []int64{3, 4, 5}},
+		{name: "Последнее событие", lastID: 5, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := h.Subscribe(Filter{}, tt.lastID)
+			defer sub.Close()
+
+			if len(sub.Replay) != len(tt.want) {
+				t.Fatalf("Replay = %+v, want %v", sub.Replay, tt.want)
+			}
+			for i, e := range sub.Replay {
+				if e.ID != tt.want[i] {
+					t.Errorf("Replay[%d].ID = %d, want %d", i, e.ID, tt.want[i])
+				}
+			}
+		})
+	}
+
+	sub := h.Subscribe(Filter{Types: []string{storage.EventTaskDeleted}}, 0)
+	h.Publish(storage.Event{ID: 6, Type: storage.EventTaskCreated})
+	h.Publish(storage.Event{ID: 7, Type: storage.EventTaskDeleted})
+	e := <-sub.C
+	if e.ID != 7 {
+		t.Errorf("C = %d, want 7", e.ID)
+	}
+	sub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Errorf("канал не закрыт после Close()")
+	}
+}
+
+func TestHub_Published(t *testing.T) {
+	h := New(nil, 3)
+	// События в порядке фиксации транзакций: 5 зафиксировано раньше 4, 7 - раньше 6
+	for _, id := range []int64{1, 2, 3, 5, 4, 7} {
+		h.Publish(storage.Event{ID: id, Type: storage.EventTaskCreated})
+	}
+	if h.low != 5 || h.last != 7 {
+		t.Errorf("low = %d, last = %d, want 5, 7", h.low, h.last)
+	}
+	// 1 вытеснено из буфера повтора, но опубликовано; 6 еще может прийти при дочитывании
+	for id, want := range map[int64]bool{1: true, 4: true, 5: true, 6: false, 7: true, 8: false} {
+		if got := h.published(id); got != want {
+			t.Errorf("published(%d) = %v, want %v", id, got, want)
+		}
+	}
+
+	// Пропуск от откаченной транзакции ждут не дольше размера буфера
+	for id := int64(8); id <= 11; id++ {
+		h.Publish(storage.Event{ID: id, Type: storage.EventTaskCreated})
+	}
+	if h.low != 11 || len(h.seen) != 0 {
+		t.Errorf("low = %d, seen = %v, want 11 и пустой seen", h.low, h.seen)
+	}
+}
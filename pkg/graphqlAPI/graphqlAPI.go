@@ -0,0 +1,117 @@
+package graphqlAPI
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var Schema string
+
+// Максимальный размер тела запроса.
+const maxRequestSize = 1 << 20
+
+// Limits - ограничения запросов.
+type Limits struct {
+	// Максимальная глубина вложенности полей
+	MaxDepth int
+	// Максимальное количество объектов в ответе
+	MaxComplexity int
+	// Максимальная длина текста запроса
+	MaxQueryLength int
+}
+
+// DefaultLimits - ограничения по умолчанию.
+var DefaultLimits = Limits{
+	MaxDepth:       8,
+	MaxComplexity:  10000,
+	MaxQueryLength: 16 << 10,
+}
+
+// Service - GraphQL API поверх хранилища.
+type Service struct {
+	storage *storage.Storage
+	schema  *graphql.Schema
+	limits  Limits
+}
+
+// New - разбирает схему и проверяет, что резолверы ей соответствуют.
+func New(s *storage.Storage, limits Limits) (*Service, error) {
+	schema, err := graphql.ParseSchema(Schema, &resolver{storage: s},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(limits.MaxDepth),
+		graphql.MaxQueryLength(limits.MaxQueryLength),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{storage: s, schema: schema, limits: limits}, nil
+}
+
+// request - тело запроса GraphQL.
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// ServeHTTP - выполняет запрос GraphQL из тела POST запроса. Ошибки выполнения
+// возвращаются в поле errors ответа с кодом 200, как принято в GraphQL.
+func (g *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req request
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Ошибка при разборе запроса GraphQL: %s", err.Error())
+		return
+	}
+
+	// Загрузчики создаются на каждый запрос, чтобы кэш не переживал запрос и не отдавал устаревшие данные
+	ctx := withLoaders(r.Context(), newLoaders(g.storage, g.limits.MaxComplexity))
+	response := g.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	for _, e := range response.Errors {
+		logger.WarnContext(r.Context(), "GraphQL: %s", e.Error())
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// Playground - страница GraphiQL для отладки запросов, endpoint - адрес GraphQL API.
+func Playground(endpoint string) http.HandlerFunc {
+	page := []byte(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>TaskManager GraphQL</title>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+</head>
+<body style="margin: 0">
+  <div id="graphiql" style="height: 100vh"></div>
+  <script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+  <script>
+    const fetcher = GraphiQL.createFetcher({ url: ` + "`" + `${location.origin}` + endpoint + "`" + ` });
+    ReactDOM.createRoot(document.getElementById('graphiql')).render(React.createElement(GraphiQL, { fetcher }));
+  </script>
+</body>
+</html>
+`)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, err := w.Write(page)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "%s", err.Error())
+		}
+	}
+}
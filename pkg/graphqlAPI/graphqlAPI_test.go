@@ -0,0 +1,84 @@
+package graphqlAPI
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSchemaMatchesResolvers(t *testing.T) {
+	_, err := New(nil, DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	g, err := New(nil, Limits{MaxDepth: 3, MaxComplexity: 100, MaxQueryLength: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := `{ tasks { author { assignedTasks { labels { name } } } } }`
+	response := g.schema.Exec(context.Background(), query, "", nil)
+	if len(response.Errors) == 0 || !strings.Contains(response.Errors[0].Message, "depth") {
+		t.Errorf("ожидалась ошибка глубины запроса, получено %v", response.Errors)
+	}
+}
+
+func TestLoaderBatches(t *testing.T) {
+	var calls [][]int
+	var mu sync.Mutex
+	l := newLoader(func(ctx context.Context, ids []int) (map[int]string, error) {
+		mu.Lock()
+		calls = append(calls, ids)
+		mu.Unlock()
+		result := map[int]string{}
+		for _, id := range ids {
+			if id != 3 {
+				result[id] = "v"
+			}
+		}
+		return result, nil
+	})
+
+	l.Prime(1, 2, 3)
+	var wg sync.WaitGroup
+	for _, id := range []int{1, 2, 3, 1, 2} {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			v, ok, err := l.Load(context.Background(), id)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok != (id != 3) || (ok && v != "v") {
+				t.Errorf("Load(%d) = %q, %v", id, v, ok)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if len(calls) != 1 || len(calls[0]) != 3 {
+		t.Fatalf("ожидался один запрос с тремя ID, получено %v", calls)
+	}
+
+	// Отсутствующий ID не запрашивается повторно
+	l.Prime(3)
+	_, ok, _ := l.Load(context.Background(), 3)
+	if ok || len(calls) != 1 {
+		t.Errorf("повторный запрос отсутствующего ID: %v", calls)
+	}
+}
+
+func TestSpend(t *testing.T) {
+	l := &loaders{}
+	l.budget.Store(5)
+	if err := l.spend(5); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.spend(1); !errors.Is(err, ErrTooComplex) {
+		t.Errorf("ожидалась ErrTooComplex, получено %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package graphqlAPI
+
+import (
+	"TaskManager/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// loader - загрузчик связанных записей по ID для одного запроса. При создании списка
+// записей ID их связей регистрируются через Prime, и первый Load загружает все
+// зарегистрированные ID одним запросом к БД вместо запроса на каждую запись (N+1).
+type loader[V any] struct {
+	fetch func(ctx context.Context, ids []int) (map[int]V, error)
+
+	mu      sync.Mutex
+	pending map[int]struct{}
+	loaded  map[int]V
+	// ID, которых нет в БД, чтобы не запрашивать их снова
+	missing map[int]struct{}
+}
+
+func newLoader[V any](fetch func(ctx context.Context, ids []int) (map[int]V, error)) *loader[V] {
+	return &loader[V]{
+		fetch:   fetch,
+		pending: map[int]struct{}{},
+		loaded:  map[int]V{},
+		missing: map[int]struct{}{},
+	}
+}
+
+// Prime - регистрирует ID для загрузки в следующем пакете
+func (l *loader[V]) Prime(ids ...int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, id := range ids {
+		if !l.known(id) {
+			l.pending[id] = struct{}{}
+		}
+	}
+}
+
+// Load - возвращает запись по ID; ok равен false, если записи нет
+func (l *loader[V]) Load(ctx context.Context, id int) (v V, ok bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if v, ok = l.loaded[id]; ok {
+		return v, true, nil
+	}
+	if _, missing := l.missing[id]; missing {
+		return v, false, nil
+	}
+
+	l.pending[id] = struct{}{}
+	ids := make([]int, 0, len(l.pending))
+	for key := range l.pending {
+		ids = append(ids, key)
+	}
+
+	result, err := l.fetch(ctx, ids)
+	if err != nil {
+		return v, false, err
+	}
+	for _, key := range ids {
+		delete(l.pending, key)
+		if value, found := result[key]; found {
+			l.loaded[key] = value
+		} else {
+			l.missing[key] = struct{}{}
+		}
+	}
+
+	v, ok = l.loaded[id]
+	return v, ok, nil
+}
+
+// known - ID уже загружен или известно, что его нет
+func (l *loader[V]) known(id int) bool {
+	if _, ok := l.loaded[id]; ok {
+		return true
+	}
+	_, ok := l.missing[id]
+	return ok
+}
+
+// loaders - загрузчики одного запроса.
+type loaders struct {
+	users         *loader[storage.User]
+	taskLabels    *loader[[]storage.Label]
+	labelTasks    *loader[[]storage.Task]
+	assignedTasks *loader[[]storage.Task]
+	authoredTasks *loader[[]storage.Task]
+
+	// Оставшийся бюджет сложности запроса: количество объектов, которые еще можно вернуть
+	budget atomic.Int64
+}
+
+func newLoaders(s *storage.Storage, maxComplexity int) *loaders {
+	l := &loaders{
+		users: newLoader(func(ctx context.Context, ids []int) (map[int]storage.User, error) {
+			users, err := s.UsersByIds(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[int]storage.User, len(users))
+			for _, u := range users {
+				result[u.ID] = u
+			}
+			return result, nil
+		}),
+		taskLabels:    newLoader(s.LabelsByTaskIds),
+		labelTasks:    newLoader(s.TasksByLabelIds),
+		assignedTasks: newLoader(s.TasksByAssigneeIds),
+		authoredTasks: newLoader(s.TasksByAuthorIds),
+	}
+	l.budget.Store(int64(maxComplexity))
+	return l
+}
+
+type loadersKey struct{}
+
+// withLoaders - добавляет загрузчики запроса в контекст
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	l, _ := ctx.Value(loadersKey{}).(*loaders)
+	return l
+}
+
+// ErrTooComplex - запрос возвращает больше объектов, чем допускает Limits.MaxComplexity.
+var ErrTooComplex = errors.New("запрос слишком сложный")
+
+// spend - списывает n объектов из бюджета сложности запроса
+func (l *loaders) spend(n int) error {
+	if l.budget.Add(-int64(n)) < 0 {
+		return fmt.Errorf("%w: превышен лимит количества объектов в ответе", ErrTooComplex)
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package graphqlAPI
+
+import (
+	"TaskManager/pkg/storage"
+	"context"
+	"fmt"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Мутации повторяют операции хранилища, поэтому публикуют те же события, что и REST API.
+
+type newTaskInput struct {
+	Title    string
+	Content  *string
+	Due      *graphql.Time
+	Priority *int32
+}
+
+// task - задача из input
+func (in *newTaskInput) task() (*storage.Task, error) {
+	t := &storage.Task{Title: in.Title}
+	if in.Content != nil {
+		t.Content = *in.Content
+	}
+	if in.Due != nil {
+		t.Due = in.Due.Unix()
+	}
+	if in.Priority != nil {
+		p, err := checkPriority(*in.Priority)
+		if err != nil {
+			return nil, err
+		}
+		t.Priority = p
+	}
+	return t, nil
+}
+
+type updateTaskInput struct {
+	Title    *string
+	Content  *string
+	Closed   *bool
+	Due      *graphql.Time
+	ClearDue *bool
+	Priority *int32
+}
+
+func checkPriority(p int32) (int, error) {
+	if p < 0 || p > 3 {
+		return 0, fmt.Errorf("некорректный приоритет %d: допустимы значения от 0 до 3", p)
+	}
+	return int(p), nil
+}
+
+func (r *resolver) CreateTask(ctx context.Context, args struct{ Input newTaskInput }) (*taskResolver, error) {
+	t, err := args.Input.task()
+	if err != nil {
+		return nil, err
+	}
+	err = r.storage.NewTask(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	return newTaskResolver(ctx, t)
+}
+
+func (r *resolver) CreateTasks(ctx context.Context, args struct{ Input []newTaskInput }) ([]*taskResolver, error) {
+	tasks := make([]*storage.Task, len(args.Input))
+	for i := range args.Input {
+		t, err := args.Input[i].task()
+		if err != nil {
+			return nil, fmt.Errorf("задача %d: %w", i+1, err)
+		}
+		tasks[i] = t
+	}
+	err := r.storage.NewTasks(ctx, tasks, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	created := make([]storage.Task, len(tasks))
+	for i, t := range tasks {
+		created[i] = *t
+	}
+	return newTaskResolvers(ctx, created)
+}
+
+func (r *resolver) UpdateTask(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input updateTaskInput
+}) (*taskResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	t, err := r.storage.TaskById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	in := args.Input
+	if in.Title != nil {
+		t.Title = *in.Title
+	}
+	if in.Content != nil {
+		t.Content = *in.Content
+	}
+	if in.Closed != nil {
+		switch {
+		case *in.Closed && t.Closed == 0:
+			t.Closed = time.Now().Unix()
+		case !*in.Closed:
+			t.Closed = 0
+		}
+	}
+	if in.Due != nil {
+		t.Due = in.Due.Unix()
+	}
+	if in.ClearDue != nil && *in.ClearDue {
+		t.Due = 0
+	}
+	if in.Priority != nil {
+		t.Priority, err = checkPriority(*in.Priority)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = r.storage.UpdateTask(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	return newTaskResolver(ctx, t)
+}
+
+func (r *resolver) DeleteTask(ctx context.Context, args struct{ ID graphql.ID }) (*taskResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	t, err := r.storage.DeleteTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return newTaskResolver(ctx, t)
+}
+
+type taskUserArgs struct {
+	TaskID graphql.ID
+	// nil - снять исполнителя
+	UserID *graphql.ID
+}
+
+func (r *resolver) AssignTask(ctx context.Context, args taskUserArgs) (*taskResolver, error) {
+	taskID, err := parseID(args.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	var userID int
+	if args.UserID != nil {
+		userID, err = parseID(*args.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	t, err := r.storage.AssignTask(ctx, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return newTaskResolver(ctx, t)
+}
+
+type taskLabelArgs struct {
+	TaskID  graphql.ID
+	LabelID graphql.ID
+}
+
+func (r *resolver) AddTaskLabel(ctx context.Context, args taskLabelArgs) (*taskResolver, error) {
+	return r.relabelTask(ctx, args, r.storage.AddTaskLabel)
+}
+
+func (r *resolver) RemoveTaskLabel(ctx context.Context, args taskLabelArgs) (*taskResolver, error) {
+	return r.relabelTask(ctx, args, r.storage.RemoveTaskLabel)
+}
+
+func (r *resolver) relabelTask(ctx context.Context, args taskLabelArgs, relabel func(ctx context.Context, taskID, labelID int) (*storage.Task, error)) (*taskResolver, error) {
+	taskID, err := parseID(args.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	labelID, err := parseID(args.LabelID)
+	if err != nil {
+		return nil, err
+	}
+	t, err := relabel(ctx, taskID, labelID)
+	if err != nil {
+		return nil, err
+	}
+	return newTaskResolver(ctx, t)
+}
+
+func (r *resolver) CreateUser(ctx context.Context, args struct{ Name string }) (*userResolver, error) {
+	u := &storage.User{Name: args.Name}
+	err := r.storage.NewUser(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return newUserResolver(ctx, u)
+}
+
+func (r *resolver) UpdateUser(ctx context.Context, args struct {
+	ID   graphql.ID
+	Name string
+}) (*userResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	u := &storage.User{ID: id, Name: args.Name}
+	err = r.storage.UpdateUser(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return newUserResolver(ctx, u)
+}
+
+func (r *resolver) DeleteUser(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	u, err := r.storage.DeleteUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return newUserResolver(ctx, u)
+}
+
+func (r *resolver) CreateLabel(ctx context.Context, args struct {
+	Name        string
+	Color       *string
+	Description *string
+}) (*labelResolver, error) {
+	l := &storage.Label{Name: args.Name}
+	if args.Color != nil {
+		l.Color = *args.Color
+	}
+	if args.Description != nil {
+		l.Description = *args.Description
+	}
+	err := r.storage.NewLabel(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+	return newLabelResolver(ctx, l)
+}
+
+func (r *resolver) UpdateLabel(ctx context.Context, args struct {
+	ID          graphql.ID
+	Name        *string
+	Color       *string
+	Description *string
+}) (*labelResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	l, err := r.storage.PatchLabel(ctx, id, func(l *storage.Label) error {
+		if args.Name != nil {
+			l.Scope, l.Name = storage.SplitLabelName(*args.Name)
+		}
+		if args.Color != nil {
+			l.Color = *args.Color
+		}
+		if args.Description != nil {
+			l.Description = *args.Description
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newLabelResolver(ctx, l)
+}
+
+func (r *resolver) DeleteLabel(ctx context.Context, args struct {
+	ID    graphql.ID
+	Force *bool
+}) (*labelResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	l, err := r.storage.DeleteLabel(ctx, id, args.Force != nil && *args.Force)
+	if err != nil {
+		return nil, err
+	}
+	return newLabelResolver(ctx, l)
+}
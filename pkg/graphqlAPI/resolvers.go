@@ -0,0 +1,289 @@
+package graphqlAPI
+
+import (
+	"TaskManager/pkg/storage"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/jackc/pgx/v4"
+)
+
+// resolver - корневой резолвер запросов и мутаций.
+type resolver struct {
+	storage *storage.Storage
+}
+
+// parseID - ID GraphQL в ID записи
+func parseID(id graphql.ID) (int, error) {
+	n, err := strconv.Atoi(string(id))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("некорректный ID %q", id)
+	}
+	return n, nil
+}
+
+// optionalID - необязательный ID фильтра, nil - 0
+func optionalID(id *graphql.ID) (int, error) {
+	if id == nil {
+		return 0, nil
+	}
+	return parseID(*id)
+}
+
+func toID(id int) graphql.ID {
+	return graphql.ID(strconv.Itoa(id))
+}
+
+// optionalTime - unix time в Time, 0 - null
+func optionalTime(unix int64) *graphql.Time {
+	if unix == 0 {
+		return nil
+	}
+	return &graphql.Time{Time: time.Unix(unix, 0)}
+}
+
+// notFound - отсутствие записи не ошибка, а null в ответе
+func notFound(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows)
+}
+
+//-------------------Резолверы типов-------------------------
+
+type taskResolver struct {
+	t storage.Task
+}
+
+// newTaskResolvers - оборачивает задачи и регистрирует их связи для пакетной загрузки
+func newTaskResolvers(ctx context.Context, tasks []storage.Task) ([]*taskResolver, error) {
+	l := loadersFrom(ctx)
+	err := l.spend(len(tasks))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*taskResolver, len(tasks))
+	for i, t := range tasks {
+		l.users.Prime(t.AuthorID, t.AssignedID)
+		l.taskLabels.Prime(t.ID)
+		result[i] = &taskResolver{t: t}
+	}
+	return result, nil
+}
+
+func newTaskResolver(ctx context.Context, t *storage.Task) (*taskResolver, error) {
+	result, err := newTaskResolvers(ctx, []storage.Task{*t})
+	if err != nil {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+func (r *taskResolver) ID() graphql.ID        { return toID(r.t.ID) }
+func (r *taskResolver) Title() string         { return r.t.Title }
+func (r *taskResolver) Content() string       { return r.t.Content }
+func (r *taskResolver) Opened() graphql.Time  { return graphql.Time{Time: time.Unix(r.t.Opened, 0)} }
+func (r *taskResolver) Closed() *graphql.Time { return optionalTime(r.t.Closed) }
+func (r *taskResolver) Due() *graphql.Time    { return optionalTime(r.t.Due) }
+func (r *taskResolver) Priority() int32       { return int32(r.t.Priority) }
+func (r *taskResolver) Author(ctx context.Context) (*userResolver, error) {
+	return loadUser(ctx, r.t.AuthorID)
+}
+func (r *taskResolver) Assignee(ctx context.Context) (*userResolver, error) {
+	return loadUser(ctx, r.t.AssignedID)
+}
+
+func (r *taskResolver) Labels(ctx context.Context) ([]*labelResolver, error) {
+	labels, _, err := loadersFrom(ctx).taskLabels.Load(ctx, r.t.ID)
+	if err != nil {
+		return nil, err
+	}
+	return newLabelResolvers(ctx, labels)
+}
+
+type userResolver struct {
+	u storage.User
+}
+
+// newUserResolvers - оборачивает пользователей и регистрирует их задачи для пакетной загрузки
+func newUserResolvers(ctx context.Context, users []storage.User) ([]*userResolver, error) {
+	l := loadersFrom(ctx)
+	err := l.spend(len(users))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*userResolver, len(users))
+	for i, u := range users {
+		l.assignedTasks.Prime(u.ID)
+		l.authoredTasks.Prime(u.ID)
+		result[i] = &userResolver{u: u}
+	}
+	return result, nil
+}
+
+func newUserResolver(ctx context.Context, u *storage.User) (*userResolver, error) {
+	result, err := newUserResolvers(ctx, []storage.User{*u})
+	if err != nil {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+// loadUser - пользователь через пакетный загрузчик, nil - пользователь не найден
+func loadUser(ctx context.Context, id int) (*userResolver, error) {
+	u, ok, err := loadersFrom(ctx).users.Load(ctx, id)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return newUserResolver(ctx, &u)
+}
+
+func (r *userResolver) ID() graphql.ID { return toID(r.u.ID) }
+func (r *userResolver) Name() string   { return r.u.Name }
+func (r *userResolver) Email() string  { return r.u.Email }
+func (r *userResolver) Locale() string { return r.u.Locale }
+func (r *userResolver) Deactivated() *graphql.Time {
+	return optionalTime(r.u.Deactivated)
+}
+func (r *userResolver) AssignedTasks(ctx context.Context) ([]*taskResolver, error) {
+	tasks, _, err := loadersFrom(ctx).assignedTasks.Load(ctx, r.u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return newTaskResolvers(ctx, tasks)
+}
+
+func (r *userResolver) AuthoredTasks(ctx context.Context) ([]*taskResolver, error) {
+	tasks, _, err := loadersFrom(ctx).authoredTasks.Load(ctx, r.u.ID)
+	if err != nil {
+		return nil, err
+	}
+	return newTaskResolvers(ctx, tasks)
+}
+
+type labelResolver struct {
+	l storage.Label
+}
+
+// newLabelResolvers - оборачивает метки и регистрирует их задачи для пакетной загрузки
+func newLabelResolvers(ctx context.Context, labels []storage.Label) ([]*labelResolver, error) {
+	l := loadersFrom(ctx)
+	err := l.spend(len(labels))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*labelResolver, len(labels))
+	for i, label := range labels {
+		l.labelTasks.Prime(label.ID)
+		result[i] = &labelResolver{l: label}
+	}
+	return result, nil
+}
+
+func newLabelResolver(ctx context.Context, label *storage.Label) (*labelResolver, error) {
+	result, err := newLabelResolvers(ctx, []storage.Label{*label})
+	if err != nil {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+func (r *labelResolver) ID() graphql.ID      { return toID(r.l.ID) }
+func (r *labelResolver) Name() string        { return r.l.Name }
+func (r *labelResolver) Scope() string       { return r.l.Scope }
+func (r *labelResolver) FullName() string    { return r.l.FullName() }
+func (r *labelResolver) Color() string       { return r.l.Color }
+func (r *labelResolver) Description() string { return r.l.Description }
+func (r *labelResolver) Tasks(ctx context.Context) ([]*taskResolver, error) {
+	tasks, _, err := loadersFrom(ctx).labelTasks.Load(ctx, r.l.ID)
+	if err != nil {
+		return nil, err
+	}
+	return newTaskResolvers(ctx, tasks)
+}
+
+//-------------------Запросы-------------------------
+
+func (r *resolver) Task(ctx context.Context, args struct{ ID graphql.ID }) (*taskResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	t, err := r.storage.TaskById(ctx, id)
+	if notFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newTaskResolver(ctx, t)
+}
+
+func (r *resolver) Tasks(ctx context.Context, args struct {
+	AuthorID   *graphql.ID
+	AssigneeID *graphql.ID
+	LabelID    *graphql.ID
+}) ([]*taskResolver, error) {
+	var filter storage.TaskFilter
+	var err error
+	if filter.AuthorID, err = optionalID(args.AuthorID); err != nil {
+		return nil, err
+	}
+	if filter.AssigneeID, err = optionalID(args.AssigneeID); err != nil {
+		return nil, err
+	}
+	if filter.LabelID, err = optionalID(args.LabelID); err != nil {
+		return nil, err
+	}
+
+	var tasks []storage.Task
+	err = r.storage.EachTaskDetails(ctx, filter, func(t *storage.TaskDetails) error {
+		tasks = append(tasks, t.Task)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newTaskResolvers(ctx, tasks)
+}
+
+func (r *resolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	return loadUser(ctx, id)
+}
+
+func (r *resolver) Users(ctx context.Context) ([]*userResolver, error) {
+	users, err := r.storage.AllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newUserResolvers(ctx, users)
+}
+
+func (r *resolver) Label(ctx context.Context, args struct{ ID graphql.ID }) (*labelResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	label, err := r.storage.LabelById(ctx, id)
+	if notFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newLabelResolver(ctx, label)
+}
+
+func (r *resolver) Labels(ctx context.Context) ([]*labelResolver, error) {
+	labels, err := r.storage.AllLabels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newLabelResolvers(ctx, labels)
+}
@@ -0,0 +1,179 @@
+package grpcService
+
+import (
+	"TaskManager/pkg/api"
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCService - gRPC сервер поверх того же хранилища, что и handlersService.
+type GRPCService struct {
+	storage *storage.Storage
+	server  *grpc.Server
+}
+
+func New(storage *storage.Storage) *GRPCService {
+	g := &GRPCService{
+		storage: storage,
+		server:  grpc.NewServer(grpc.ChainUnaryInterceptor(logErrors)),
+	}
+	api.RegisterTaskServiceServer(g.server, &taskServer{storage: storage})
+	api.RegisterUserServiceServer(g.server, &userServer{storage: storage})
+	api.RegisterLabelServiceServer(g.server, &labelServer{storage: storage})
+	// Описание сервисов для grpcurl и подобных клиентов
+	reflection.Register(g.server)
+	return g
+}
+
+// Serve - принимает соединения на addr до вызова Stop
+func (g *GRPCService) Serve(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	logger.Info("gRPC сервер запущен на %s", addr)
+	return g.server.Serve(lis)
+}
+
+// Stop - останавливает сервер, дожидаясь завершения текущих вызовов
+func (g *GRPCService) Stop() {
+	g.server.GracefulStop()
+}
+
+// Gateway - REST API /v1, транслирующий запросы в gRPC сервер по адресу grpcAddr.
+// Маршруты описаны в api/taskmanager.yaml и генерируются вместе с gRPC кодом,
+// поэтому REST /v1 и gRPC не могут разойтись.
+func Gateway(ctx context.Context, grpcAddr string) (http.Handler, error) {
+	// ":8011" - сервер слушает все интерфейсы, подключаемся к локальному
+	if strings.HasPrefix(grpcAddr, ":") {
+		grpcAddr = "localhost" + grpcAddr
+	}
+	mux := runtime.NewServeMux()
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	register := []func(context.Context, *runtime.ServeMux, string, []grpc.DialOption) error{
+		api.RegisterTaskServiceHandlerFromEndpoint,
+		api.RegisterUserServiceHandlerFromEndpoint,
+		api.RegisterLabelServiceHandlerFromEndpoint,
+	}
+	for _, r := range register {
+		err := r(ctx, mux, grpcAddr, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mux, nil
+}
+
+// logErrors - пишет в лог ошибки вызовов, как это делают HTTP обработчики
+func logErrors(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		logger.ErrorContext(ctx, "%s: %s", info.FullMethod, err.Error())
+	}
+	return resp, err
+}
+
+// toStatus - ошибка хранилища в статус gRPC
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status.Error(codes.NotFound, "запись не найдена")
+	}
+	if errors.Is(err, storage.ErrInvalid) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, storage.ErrLabelExists) {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, storage.ErrLabelInUse) || errors.Is(err, storage.ErrUserInUse) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		// foreign_key_violation
+		case "23503":
+			return status.Error(codes.FailedPrecondition, pgErr.Message)
+		// not_null_violation, check_violation
+		case "23502", "23514":
+			return status.Error(codes.InvalidArgument, pgErr.Message)
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+//-------------------Преобразования-------------------------
+
+func taskToPB(t *storage.Task) *api.Task {
+	return &api.Task{
+		Id:         int64(t.ID),
+		Opened:     t.Opened,
+		Closed:     t.Closed,
+		AuthorId:   int64(t.AuthorID),
+		AssignedId: int64(t.AssignedID),
+		Title:      t.Title,
+		Content:    t.Content,
+		Due:        t.Due,
+		Priority:   int32(t.Priority),
+	}
+}
+
+func taskFromPB(t *api.Task) *storage.Task {
+	return &storage.Task{
+		ID:         int(t.GetId()),
+		Opened:     t.GetOpened(),
+		Closed:     t.GetClosed(),
+		AuthorID:   int(t.GetAuthorId()),
+		AssignedID: int(t.GetAssignedId()),
+		Title:      t.GetTitle(),
+		Content:    t.GetContent(),
+		Due:        t.GetDue(),
+		Priority:   int(t.GetPriority()),
+	}
+}
+
+func userToPB(u *storage.User) *api.User {
+	return &api.User{
+		Id:          int64(u.ID),
+		Name:        u.Name,
+		Email:       u.Email,
+		Locale:      u.Locale,
+		EmailOptOut: u.EmailOptOut,
+	}
+}
+
+func userFromPB(u *api.User) *storage.User {
+	return &storage.User{
+		ID:          int(u.GetId()),
+		Name:        u.GetName(),
+		Email:       u.GetEmail(),
+		Locale:      u.GetLocale(),
+		EmailOptOut: u.GetEmailOptOut(),
+	}
+}
+
+// labelToPB - метка в API gRPC, группа передается в имени: priority::high
+func labelToPB(l *storage.Label) *api.Label {
+	return &api.Label{Id: int64(l.ID), Name: l.FullName()}
+}
+
+func labelFromPB(l *api.Label) *storage.Label {
+	scope, name := storage.SplitLabelName(l.GetName())
+	return &storage.Label{ID: int(l.GetId()), Scope: scope, Name: name}
+}
@@ -0,0 +1,57 @@
+package grpcService
+
+import (
+	"TaskManager/pkg/storage"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTaskConversion(t *testing.T) {
+	task := storage.Task{
+		ID: 1, Opened: 2, Closed: 3, AuthorID: 4, AssignedID: 5,
+		Title: "title", Content: "content", Due: 6, Priority: 2,
+	}
+	if got := *taskFromPB(taskToPB(&task)); got != task {
+		t.Errorf("задача изменилась при преобразовании: %+v", got)
+	}
+
+	user := storage.User{ID: 1, Name: "name", Email: "a@b.c", Locale: "en", EmailOptOut: true}
+	if got := *userFromPB(userToPB(&user)); got != user {
+		t.Errorf("пользователь изменился при преобразовании: %+v", got)
+	}
+
+	for _, label := range []storage.Label{{ID: 1, Name: "name"}, {ID: 2, Scope: "priority", Name: "high"}} {
+		if got := *labelFromPB(labelToPB(&label)); got != label {
+			t.Errorf("метка изменилась при преобразовании: %+v", got)
+		}
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{nil, codes.OK},
+		{pgx.ErrNoRows, codes.NotFound},
+		{fmt.Errorf("обертка: %w", pgx.ErrNoRows), codes.NotFound},
+		{&pgconn.PgError{Code: "23503"}, codes.FailedPrecondition},
+		{&pgconn.PgError{Code: "23514"}, codes.InvalidArgument},
+		{fmt.Errorf("%w: не задано название", storage.ErrInvalid), codes.InvalidArgument},
+		{storage.ErrLabelExists, codes.AlreadyExists},
+		{storage.ErrLabelInUse, codes.FailedPrecondition},
+		{storage.ErrUserInUse, codes.FailedPrecondition},
+		{storage.ErrUserInactive, codes.InvalidArgument},
+		{fmt.Errorf("ошибка"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := status.Code(toStatus(tt.err)); got != tt.code {
+			t.Errorf("toStatus(%v) = %s, ожидалось %s", tt.err, got, tt.code)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package grpcService
+
+import (
+	"TaskManager/pkg/api"
+	"TaskManager/pkg/storage"
+	"context"
+)
+
+type labelServer struct {
+	api.UnimplementedLabelServiceServer
+	storage *storage.Storage
+}
+
+// GetLabel - метка по ID
+func (s *labelServer) GetLabel(ctx context.Context, req *api.IdRequest) (*api.Label, error) {
+	l, err := s.storage.LabelById(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return labelToPB(l), nil
+}
+
+// ListLabels - все метки
+func (s *labelServer) ListLabels(req *api.ListLabelsRequest, stream api.LabelService_ListLabelsServer) error {
+	labels, err := s.storage.AllLabels(stream.Context())
+	if err != nil {
+		return toStatus(err)
+	}
+	for i := range labels {
+		err = stream.Send(labelToPB(&labels[i]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateLabel - создает метку
+func (s *labelServer) CreateLabel(ctx context.Context, req *api.Label) (*api.Label, error) {
+	l := labelFromPB(req)
+	err := s.storage.NewLabel(ctx, l)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return labelToPB(l), nil
+}
+
+// UpdateLabel - переименовывает метку, имя вида группа::название переносит ее в группу.
+// Цвета и описания в API gRPC нет, они не изменяются
+func (s *labelServer) UpdateLabel(ctx context.Context, req *api.Label) (*api.Label, error) {
+	l, err := s.storage.PatchLabel(ctx, int(req.GetId()), func(l *storage.Label) error {
+		l.Scope, l.Name = storage.SplitLabelName(req.GetName())
+		return nil
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return labelToPB(l), nil
+}
+
+// DeleteLabel - удаляет метку и возвращает удаленную запись. Метка, привязанная к задачам,
+// не удаляется
+func (s *labelServer) DeleteLabel(ctx context.Context, req *api.IdRequest) (*api.Label, error) {
+	l, err := s.storage.DeleteLabel(ctx, int(req.GetId()), false)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return labelToPB(l), nil
+}
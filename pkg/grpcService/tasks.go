@@ -0,0 +1,124 @@
+package grpcService
+
+import (
+	"TaskManager/pkg/api"
+	"TaskManager/pkg/storage"
+	"context"
+)
+
+type taskServer struct {
+	api.UnimplementedTaskServiceServer
+	storage *storage.Storage
+}
+
+// GetTask - задача по ID
+func (s *taskServer) GetTask(ctx context.Context, req *api.IdRequest) (*api.Task, error) {
+	t, err := s.storage.TaskById(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return taskToPB(t), nil
+}
+
+// ListTasks - задачи с фильтром, отправляются по мере чтения из БД
+func (s *taskServer) ListTasks(req *api.ListTasksRequest, stream api.TaskService_ListTasksServer) error {
+	filter := storage.TaskFilter{
+		TaskID:     int(req.GetTaskId()),
+		AuthorID:   int(req.GetAuthorId()),
+		AssigneeID: int(req.GetAssigneeId()),
+		LabelID:    int(req.GetLabelId()),
+	}
+	err := s.storage.EachTaskDetails(stream.Context(), filter, func(t *storage.TaskDetails) error {
+		return stream.Send(taskToPB(&t.Task))
+	})
+	return toStatus(err)
+}
+
+// CreateTask - создает задачу
+func (s *taskServer) CreateTask(ctx context.Context, req *api.Task) (*api.Task, error) {
+	t := taskFromPB(req)
+	err := s.storage.NewTask(ctx, t)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return taskToPB(t), nil
+}
+
+// CreateTasks - создает задачи в одной транзакции
+func (s *taskServer) CreateTasks(ctx context.Context, req *api.CreateTasksRequest) (*api.CreateTasksResponse, error) {
+	tasks := make([]*storage.Task, len(req.GetTasks()))
+	for i, t := range req.GetTasks() {
+		tasks[i] = taskFromPB(t)
+	}
+	err := s.storage.NewTasks(ctx, tasks, nil)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &api.CreateTasksResponse{}
+	for _, t := range tasks {
+		resp.Tasks = append(resp.Tasks, taskToPB(t))
+	}
+	return resp, nil
+}
+
+// UpdateTask - обновляет задачу
+func (s *taskServer) UpdateTask(ctx context.Context, req *api.Task) (*api.Task, error) {
+	t := taskFromPB(req)
+	err := s.storage.UpdateTask(ctx, t)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return taskToPB(t), nil
+}
+
+// DeleteTask - удаляет задачу и возвращает удаленную запись
+func (s *taskServer) DeleteTask(ctx context.Context, req *api.IdRequest) (*api.Task, error) {
+	t, err := s.storage.DeleteTask(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return taskToPB(t), nil
+}
+
+// AssignTask - назначает исполнителя задачи
+func (s *taskServer) AssignTask(ctx context.Context, req *api.AssignTaskRequest) (*api.Task, error) {
+	t, err := s.storage.AssignTask(ctx, int(req.GetTaskId()), int(req.GetUserId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return taskToPB(t), nil
+}
+
+// ListTaskLabels - метки задачи
+func (s *taskServer) ListTaskLabels(req *api.IdRequest, stream api.TaskService_ListTaskLabelsServer) error {
+	labels, err := s.storage.TaskLabels(stream.Context(), int(req.GetId()))
+	if err != nil {
+		return toStatus(err)
+	}
+	for i := range labels {
+		err = stream.Send(labelToPB(&labels[i]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddTaskLabel - привязывает метку к задаче
+func (s *taskServer) AddTaskLabel(ctx context.Context, req *api.TaskLabelRequest) (*api.Task, error) {
+	t, err := s.storage.AddTaskLabel(ctx, int(req.GetTaskId()), int(req.GetLabelId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return taskToPB(t), nil
+}
+
+// RemoveTaskLabel - отвязывает метку от задачи
+func (s *taskServer) RemoveTaskLabel(ctx context.Context, req *api.TaskLabelRequest) (*api.Task, error) {
+	t, err := s.storage.RemoveTaskLabel(ctx, int(req.GetTaskId()), int(req.GetLabelId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return taskToPB(t), nil
+}
@@ -0,0 +1,75 @@
+package grpcService
+
+import (
+	"TaskManager/pkg/api"
+	"TaskManager/pkg/storage"
+	"context"
+)
+
+type userServer struct {
+	api.UnimplementedUserServiceServer
+	storage *storage.Storage
+}
+
+// GetUser - пользователь по ID
+func (s *userServer) GetUser(ctx context.Context, req *api.IdRequest) (*api.User, error) {
+	u, err := s.storage.UserById(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return userToPB(u), nil
+}
+
+// ListUsers - все пользователи
+func (s *userServer) ListUsers(req *api.ListUsersRequest, stream api.UserService_ListUsersServer) error {
+	users, err := s.storage.AllUsers(stream.Context())
+	if err != nil {
+		return toStatus(err)
+	}
+	for i := range users {
+		err = stream.Send(userToPB(&users[i]))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateUser - создает пользователя
+func (s *userServer) CreateUser(ctx context.Context, req *api.User) (*api.User, error) {
+	u := userFromPB(req)
+	err := s.storage.NewUser(ctx, u)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return userToPB(u), nil
+}
+
+// UpdateUser - изменяет имя пользователя
+func (s *userServer) UpdateUser(ctx context.Context, req *api.User) (*api.User, error) {
+	u := userFromPB(req)
+	err := s.storage.UpdateUser(ctx, u)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return userToPB(u), nil
+}
+
+// UpdateUserMail - изменяет почтовые настройки пользователя
+func (s *userServer) UpdateUserMail(ctx context.Context, req *api.User) (*api.User, error) {
+	u := userFromPB(req)
+	err := s.storage.UpdateUserMail(ctx, u)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return userToPB(u), nil
+}
+
+// DeleteUser - удаляет пользователя и возвращает удаленную запись
+func (s *userServer) DeleteUser(ctx context.Context, req *api.IdRequest) (*api.User, error) {
+	u, err := s.storage.DeleteUser(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return userToPB(u), nil
+}
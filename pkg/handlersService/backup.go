@@ -0,0 +1,86 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/accessLog"
+	"TaskManager/pkg/backup"
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/utilities"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Максимальный размер загружаемого архива.
+const maxArchiveSize = 1 << 30
+
+// adminOnly - пропускает запрос только с заголовком Authorization: Bearer <AdminToken>.
+// Если токен администратора не задан, административные эндпоинты отключены.
+func (h *HandlersService) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.config.AdminToken == "" {
+			http.Error(w, "Административный API отключен", http.StatusForbidden)
+			logger.WarnContext(r.Context(), "Запрос к административному API при незаданном токене")
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.config.AdminToken)) != 1 {
+			http.Error(w, "Требуется токен администратора", http.StatusUnauthorized)
+			logger.WarnContext(r.Context(), "Неверный токен администратора")
+			return
+		}
+		accessLog.SetUser(r.Context(), "admin")
+		next(w, r)
+	}
+}
+
+// ExportBackup - эндпоинт /admin/export, выгружает все данные в архив (NDJSON, gzip)
+func (h *HandlersService) ExportBackup(w http.ResponseWriter, r *http.Request) {
+	// Выгрузка может идти дольше WriteTimeout сервера
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil {
+		logger.WarnContext(r.Context(), "Не удалось снять таймаут записи: %s", err.Error())
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="taskmanager-%s.ndjson.gz"`, time.Now().Format("20060102-150405")))
+
+	// Заголовок ответа уже отправлен, поэтому ошибку можно только записать в лог,
+	// клиент увидит неполный архив без завершающей строки
+	err = backup.Export(r.Context(), h.storage, w)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Ошибка при выгрузке архива: %s", err.Error())
+	}
+}
+
+// ImportBackup - эндпоинт /admin/import?strategy={skip|overwrite|remap|fail}&dryrun=1, восстанавливает
+// данные из архива в теле запроса и возвращает отчет в JSON или ошибку
+func (h *HandlersService) ImportBackup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+	strategy := q.Get("strategy")
+	if strategy == "" {
+		strategy = backup.StrategySkip
+	}
+
+	err := http.NewResponseController(w).SetReadDeadline(time.Time{})
+	if err != nil {
+		logger.WarnContext(r.Context(), "Не удалось снять таймаут чтения: %s", err.Error())
+	}
+
+	report, err := backup.Import(r.Context(), h.storage, http.MaxBytesReader(w, r.Body, maxArchiveSize),
+		strategy, q.Get("dryrun") == "1")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		logger.ErrorContext(r.Context(), "Ошибка при восстановлении архива: %s", err.Error())
+		return
+	}
+
+	str := utilities.ToJSON(report)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
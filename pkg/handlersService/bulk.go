@@ -0,0 +1,157 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"TaskManager/pkg/utilities"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Максимальный размер тела запроса массовой операции.
+const maxBulkRequestSize = 1 << 20
+
+// bulkRequest - тело запросов /updatetasks и /deletetasks. Задачи выбираются списком IDs
+// или, если он пуст, выражением Filter. Изменения задаются только для /updatetasks.
+type bulkRequest struct {
+	IDs    []int
+	Filter string
+	// atomic (по умолчанию) или best-effort
+	Mode string
+	storage.TaskChanges
+}
+
+// parseTaskFilter - разбирает выражение фильтра задач: условия key=value через пробел или
+// запятую, все условия должны выполняться. Ключи: id, author, assignee, label и state (open, closed)
+func parseTaskFilter(expr string) (storage.TaskFilter, error) {
+	var f storage.TaskFilter
+	terms := strings.FieldsFunc(expr, func(r rune) bool { return r == ' ' || r == ',' })
+	for _, term := range terms {
+		key, value, ok := strings.Cut(term, "=")
+		if !ok {
+			return f, fmt.Errorf("некорректное условие фильтра %q, ожидается key=value", term)
+		}
+		var dst *int
+		switch key {
+		case "id":
+			dst = &f.TaskID
+		case "author":
+			dst = &f.AuthorID
+		case "assignee":
+			dst = &f.AssigneeID
+		case "label":
+			dst = &f.LabelID
+		case "state":
+			if value != storage.TaskStateOpen && value != storage.TaskStateClosed {
+				return f, fmt.Errorf("некорректное состояние %q, ожидается open или closed", value)
+			}
+			f.State = value
+			continue
+		default:
+			return f, fmt.Errorf("неизвестное условие фильтра %q", key)
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return f, fmt.Errorf("некорректный ID в условии %q", term)
+		}
+		*dst = id
+	}
+	return f, nil
+}
+
+// bulkParams - разбирает тело запроса массовой операции и параметр dryrun
+func bulkParams(w http.ResponseWriter, r *http.Request) (bulkRequest, storage.TaskSelector, storage.BulkOptions, error) {
+	var req bulkRequest
+	var sel storage.TaskSelector
+	opts := storage.BulkOptions{DryRun: r.URL.Query().Get("dryrun") == "1"}
+
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBulkRequestSize)).Decode(&req)
+	if err != nil {
+		return req, sel, opts, err
+	}
+	switch req.Mode {
+	case "", storage.BulkAtomic, storage.BulkBestEffort:
+		opts.Mode = req.Mode
+	default:
+		return req, sel, opts, fmt.Errorf("неизвестный режим %q, ожидается atomic или best-effort", req.Mode)
+	}
+	sel.IDs = req.IDs
+	sel.Filter, err = parseTaskFilter(req.Filter)
+	if err != nil {
+		return req, sel, opts, err
+	}
+	if len(sel.IDs) > 0 && !sel.Filter.Empty() {
+		return req, sel, opts, errors.New("задачи выбираются либо списком IDs, либо фильтром")
+	}
+	if len(sel.IDs) == 0 && sel.Filter.Empty() {
+		return req, sel, opts, storage.ErrBulkEmpty
+	}
+	return req, sel, opts, nil
+}
+
+// writeBulkReport - отвечает отчетом массовой операции. Если изменения не сохранены
+// из-за ошибок в задачах, возвращается 422
+func writeBulkReport(w http.ResponseWriter, r *http.Request, report *storage.BulkReport, err error) {
+	if err != nil {
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, storage.ErrBulkEmpty) || errors.Is(err, storage.ErrBulkTooLarge):
+			code = http.StatusBadRequest
+		case errors.Is(err, storage.ErrInvalid):
+			code = http.StatusUnprocessableEntity
+		}
+		http.Error(w, err.Error(), code)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	if report.Failed > 0 && !report.Committed {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	str := utilities.ToJSON(report)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// UpdateTasks - эндпоинт /updatetasks?dryrun=1, изменяет задачи по списку ID или фильтру:
+// исполнитель, приоритет, срок, закрытие и открытие, добавление и удаление меток.
+// Возвращает отчет по каждой задаче в JSON.
+func (h *HandlersService) UpdateTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	req, sel, opts, err := bulkParams(w, r)
+	if err == nil && req.TaskChanges.Empty() {
+		err = errors.New("не заданы изменения задач")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Ошибка в запросе массового изменения: %s", err.Error())
+		return
+	}
+
+	report, err := h.storage.UpdateTasks(r.Context(), sel, req.TaskChanges, opts)
+	writeBulkReport(w, r, report, err)
+}
+
+// DeleteTasks - эндпоинт /deletetasks?dryrun=1, удаляет задачи по списку ID или фильтру.
+// Возвращает отчет с удаленными задачами в JSON.
+func (h *HandlersService) DeleteTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	req, sel, opts, err := bulkParams(w, r)
+	if err == nil && !req.TaskChanges.Empty() {
+		err = errors.New("изменения задач не задаются при удалении")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Ошибка в запросе массового удаления: %s", err.Error())
+		return
+	}
+
+	report, err := h.storage.DeleteTasks(r.Context(), sel, opts)
+	writeBulkReport(w, r, report, err)
+}
@@ -0,0 +1,48 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTaskFilter(t *testing.T) {
+	f, err := parseTaskFilter("assignee=2, label=5 state=open")
+	want := storage.TaskFilter{AssigneeID: 2, LabelID: 5, State: storage.TaskStateOpen}
+	if err != nil || f != want {
+		t.Errorf("получено %+v, %v, ожидалось %+v", f, err, want)
+	}
+	f, err = parseTaskFilter("")
+	if err != nil || !f.Empty() {
+		t.Errorf("пустое выражение: %+v, %v", f, err)
+	}
+	for _, expr := range []string{"assignee", "assignee=x", "id=0", "state=done", "title=a"} {
+		if _, err := parseTaskFilter(expr); err == nil {
+			t.Errorf("%q: ожидалась ошибка", expr)
+		}
+	}
+}
+
+// TestBulkBadRequest - запросы с ошибками отклоняются до обращения к хранилищу
+func TestBulkBadRequest(t *testing.T) {
+	router := New(nil, nil, Config{}).Router()
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{http.MethodPut, "/updatetasks", `{"IDs": [1]}`},
+		{http.MethodPut, "/updatetasks", `{"Closed": true}`},
+		{http.MethodPut, "/updatetasks", `{"IDs": [1], "Filter": "label=1", "Closed": true}`},
+		{http.MethodPut, "/updatetasks", `{"Filter": "state=done", "Closed": true}`},
+		{http.MethodPut, "/updatetasks", `{"IDs": [1], "Mode": "some", "Closed": true}`},
+		{http.MethodPost, "/deletetasks", `{"IDs": [1], "Priority": 1}`},
+		{http.MethodPost, "/deletetasks", `[]`},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: код %d, ожидался 400: %s", tc.path, tc.body, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/storage"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// fakePostgres - сервер протокола Postgres, отвечающий на каждый запрос строками rows
+// с текстовыми колонками cols. Возвращает хранилище с пулом к нему и счетчик запросов
+func fakePostgres(t *testing.T, cols []string, rows [][]string) (*storage.Storage, *atomic.Int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var queries atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakePostgres(conn, cols, rows, &queries)
+		}
+	}()
+
+	config, err := pgxpool.ParseConfig("postgres://test@" + ln.Addr().String() + "/test?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ConnConfig.PreferSimpleProtocol = true
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	db, err := pgxpool.ConnectConfig(ctx, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	return &storage.Storage{DB: db}, &queries
+}
+
+func serveFakePostgres(conn net.Conn, cols []string, rows [][]string, queries *atomic.Int32) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn)
+	_, err := backend.ReceiveStartupMessage()
+	if err != nil {
+		return
+	}
+	ready := &pgproto3.ReadyForQuery{TxStatus: 'I'}
+	for _, msg := range []pgproto3.BackendMessage{
+		&pgproto3.AuthenticationOk{},
+		// Без них pgx не выполняет запросы простым протоколом
+		&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"},
+		&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"},
+		&pgproto3.BackendKeyData{},
+		ready,
+	} {
+		if backend.Send(msg) != nil {
+			return
+		}
+	}
+
+	description := &pgproto3.RowDescription{}
+	for _, c := range cols {
+		// Типы колонок меток и пользователей, остальные - text
+		oid := map[string]uint32{"id": 23, "email_opt_out": 16, "deactivated": 20}[c]
+		if oid == 0 {
+			oid = 25
+		}
+		description.Fields = append(description.Fields, pgproto3.FieldDescription{
+			Name: []byte(c), DataTypeOID: oid, DataTypeSize: -1, TypeModifier: -1,
+		})
+	}
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		if _, ok := msg.(*pgproto3.Query); !ok {
+			return
+		}
+		queries.Add(1)
+		messages := []pgproto3.BackendMessage{description}
+		for _, row := range rows {
+			values := make([][]byte, len(row))
+			for i, v := range row {
+				values[i] = []byte(v)
+			}
+			messages = append(messages, &pgproto3.DataRow{Values: values})
+		}
+		messages = append(messages, &pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")}, ready)
+		for _, m := range messages {
+			if backend.Send(m) != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestAllCached(t *testing.T) {
+	tests := []struct {
+		path string
+		cols []string
+		row  []string
+		want string
+	}{
+		{"/alllabels", []string{"id", "scope", "name", "color", "description"}, []string{"1", "priority", "high", "", ""}, `"Name": "high"`},
+		{"/allusers", []string{"id", "name", "email", "locale", "email_opt_out", "deactivated"}, []string{"1", "default", "", "ru", "f", "0"}, `"Name": "default"`},
+	}
+	for _, tt := range tests {
+		s, queries := fakePostgres(t, tt.cols, [][]string{tt.row})
+		s.EnableCache(storage.CacheConfig{TTL: time.Minute, MaxEntries: 10})
+		router := New(s, nil, Config{}).Router()
+
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.want) {
+				t.Fatalf("%s: код %d, %s", tt.path, w.Code, w.Body.String())
+			}
+		}
+		if got := queries.Load(); got != 1 {
+			t.Errorf("%s: запросов к БД %d, ожидался 1", tt.path, got)
+		}
+
+		// Страницы читаются из БД
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path+"?limit=1", nil))
+		if got := queries.Load(); w.Code != http.StatusOK || got != 2 {
+			t.Errorf("%s?limit=1: код %d, запросов к БД %d, ожидалось 2", tt.path, w.Code, got)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/accessLog"
+	"TaskManager/pkg/ical"
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/utilities"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4"
+)
+
+// CalendarFeed - ссылка на календарную ленту пользователя.
+type CalendarFeed struct {
+	Token string
+	// Лента задач (VTODO)
+	URL string
+	// Лента событий (VEVENT) для календарей без поддержки задач
+	EventsURL string
+}
+
+// Calendar - эндпоинт /calendar.ics?token={token}[&kind=todo|event], возвращает ленту iCalendar
+// с задачами пользователя, у которых задан срок. Токен выдается эндпоинтом /calendartoken.
+func (h *HandlersService) Calendar(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	switch kind {
+	case "":
+		kind = ical.KindTodo
+	case ical.KindTodo, ical.KindEvent:
+	default:
+		http.Error(w, fmt.Sprintf("неизвестный вид записей: %s", kind), http.StatusBadRequest)
+		logger.WarnContext(r.Context(), "Неизвестный вид записей календаря: %s", kind)
+		return
+	}
+
+	user, err := h.storage.UserByCalendarToken(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		http.Error(w, "Календарь не найден", http.StatusNotFound)
+		logger.WarnContext(r.Context(), "Календарь не найден: %s", err.Error())
+		return
+	}
+
+	accessLog.SetUser(r.Context(), strconv.Itoa(user.ID))
+
+	tasks, err := h.storage.CalendarTasks(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="tasks.ics"`)
+	feed := ical.Feed{
+		Name:    "TaskManager: " + user.Name,
+		Kind:    kind,
+		BaseURL: h.config.PublicURL,
+	}
+	err = ical.Write(w, feed, tasks, time.Now())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// ResetCalendarToken - эндпоинт /calendartoken?uid={uid}, выдает пользователю новый токен календарной
+// ленты и возвращает ссылки на ленту в JSON. Прежние ссылки перестают работать. Доступен только
+// с токеном администратора: токен ленты открывает задачи пользователя без авторизации.
+func (h *HandlersService) ResetCalendarToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := strconv.Atoi(mux.Vars(r)["uid"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	token, err := h.storage.ResetCalendarToken(r.Context(), userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Пользователь не найден", http.StatusNotFound)
+		logger.WarnContext(r.Context(), "Пользователь %d не найден", userID)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	feedURL := h.config.PublicURL + "/calendar.ics?token=" + url.QueryEscape(token)
+	str := utilities.ToJSON(CalendarFeed{
+		Token:     token,
+		URL:       feedURL,
+		EventsURL: feedURL + "&kind=" + ical.KindEvent,
+	})
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
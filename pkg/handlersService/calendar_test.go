@@ -0,0 +1,22 @@
+package handlersService
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResetCalendarTokenAnonymous(t *testing.T) {
+	for token, want := range map[string]int{
+		"":       http.StatusForbidden,
+		"secret": http.StatusUnauthorized,
+	} {
+		router := New(nil, nil, Config{AdminToken: token}).Router()
+		r := httptest.NewRequest(http.MethodPost, "/calendartoken?uid=1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("токен администратора %q: код %d, ожидался %d", token, w.Code, want)
+		}
+	}
+}
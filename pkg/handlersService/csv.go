@@ -0,0 +1,108 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"TaskManager/pkg/tasksCSV"
+	"TaskManager/pkg/utilities"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Максимальный размер загружаемого CSV файла.
+const maxImportSize = 32 << 20
+
+// taskFilter - разбирает фильтр задач из параметров запроса ?tid=&aid=&uid=&lid=
+// (задача, автор, исполнитель, метка). Отсутствующие параметры не ограничивают выборку.
+func taskFilter(r *http.Request) (storage.TaskFilter, error) {
+	var f storage.TaskFilter
+	var err error
+	q := r.URL.Query()
+
+	params := []struct {
+		name string
+		dst  *int
+	}{
+		{"tid", &f.TaskID},
+		{"aid", &f.AuthorID},
+		{"uid", &f.AssigneeID},
+		{"lid", &f.LabelID},
+	}
+	for _, p := range params {
+		if v := q.Get(p.name); v != "" {
+			*p.dst, err = strconv.Atoi(v)
+			if err != nil {
+				return f, fmt.Errorf("некорректный параметр %s: %w", p.name, err)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// ExportTasks - эндпоинт /exporttasks?tid={tid}&aid={aid}&uid={uid}&lid={lid}&columns={columns},
+// выгружает задачи в CSV. Все параметры необязательные, columns - список колонок через запятую.
+func (h *HandlersService) ExportTasks(w http.ResponseWriter, r *http.Request) {
+	filter, err := taskFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	columns, err := tasksCSV.ParseColumns(r.URL.Query().Get("columns"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	// Большая выгрузка может идти дольше WriteTimeout сервера
+	err = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil {
+		logger.WarnContext(r.Context(), "Не удалось снять таймаут записи: %s", err.Error())
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+
+	// Заголовок ответа уже отправлен, поэтому ошибку можно только записать в лог
+	err = tasksCSV.Export(r.Context(), w, h.storage, filter, columns)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// ImportTasks - эндпоинт /importtasks?dryrun=1&create=1, создает задачи из CSV в теле запроса.
+// dryrun - только проверить, create - создать отсутствующих пользователей и метки.
+// Возвращает отчет по строкам в JSON, при ошибках в строках ничего не сохраняется и возвращается 422.
+func (h *HandlersService) ImportTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+	dryRun := q.Get("dryrun") == "1"
+	createMissing := q.Get("create") == "1"
+
+	tasks, err := tasksCSV.Parse(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Ошибка при разборе CSV: %s", err.Error())
+		return
+	}
+
+	report, err := h.storage.ImportTasks(r.Context(), tasks, createMissing, dryRun)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	if report.Failed > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	str := utilities.ToJSON(report)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
@@ -0,0 +1,21 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/logger"
+	_ "embed"
+	"net/http"
+)
+
+// Спецификация HTTP API, при добавлении маршрута в Router ее нужно дополнить.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPI - эндпоинт /openapi.json, возвращает спецификацию OpenAPI 3 HTTP API
+func (h *HandlersService) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, err := w.Write(openAPISpec)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
@@ -0,0 +1,175 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/rateLimit"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// Маршруты-префиксы, которые не описываются в openapi.json: сама страница документации
+// и REST шлюз gRPC, описанный в api/taskmanager.proto.
+var undocumentedPrefixes = map[string]bool{
+	"/docs/": true,
+	"/v1/":   true,
+}
+
+type specParameter struct {
+	Name     string
+	In       string
+	Required bool
+}
+
+type spec struct {
+	Paths map[string]map[string]struct {
+		Parameters []specParameter
+	}
+}
+
+func loadSpec(t *testing.T) spec {
+	t.Helper()
+	var s spec
+	err := json.Unmarshal(openAPISpec, &s)
+	if err != nil {
+		t.Fatalf("openapi.json: %s", err)
+	}
+	return s
+}
+
+// Каждый маршрут роутера должен быть описан в спецификации вместе с обязательными параметрами запроса,
+// и спецификация не должна описывать несуществующие маршруты.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	s := loadSpec(t)
+	h := New(nil, nil, Config{Dev: true, GRPCAddr: ":0"})
+	routed := map[string]bool{}
+
+	err := h.Router().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			if !undocumentedPrefixes[path] {
+				t.Errorf("маршрут %s без методов не описан", path)
+			}
+			return nil
+		}
+		queries, _ := route.GetQueriesTemplates()
+
+		for _, method := range methods {
+			if method == http.MethodOptions {
+				continue
+			}
+			key := strings.ToLower(method)
+			routed[path+" "+key] = true
+			op, ok := s.Paths[path][key]
+			if !ok {
+				t.Errorf("%s %s отсутствует в openapi.json", method, path)
+				continue
+			}
+			for _, q := range queries {
+				name, _, _ := strings.Cut(q, "=")
+				found := false
+				for _, p := range op.Parameters {
+					if p.In == "query" && p.Name == name && p.Required {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("%s %s: обязательный параметр %s не описан", method, path, name)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, ops := range s.Paths {
+		for method := range ops {
+			if !routed[path+" "+method] {
+				t.Errorf("openapi.json описывает несуществующий маршрут %s %s", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// Все ссылки $ref спецификации должны указывать на существующие компоненты.
+func TestOpenAPIRefs(t *testing.T) {
+	var raw map[string]any
+	err := json.Unmarshal(openAPISpec, &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	components := raw["components"].(map[string]any)
+
+	refs := regexp.MustCompile(`"\$ref":\s*"#/components/(\w+)/(\w+)"`).FindAllSubmatch(openAPISpec, -1)
+	if len(refs) == 0 {
+		t.Fatal("ссылки не найдены")
+	}
+	for _, ref := range refs {
+		group, _ := components[string(ref[1])].(map[string]any)
+		if _, ok := group[string(ref[2])]; !ok {
+			t.Errorf("ссылка на отсутствующий компонент %s/%s", ref[1], ref[2])
+		}
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	h := New(nil, nil, Config{})
+	for _, path := range []string{"/openapi.json", "/docs/", "/docs/swagger_spec"} {
+		rec := httptest.NewRecorder()
+		h.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s: код %d", path, rec.Code)
+		}
+	}
+}
+
+// Виды запросов для ограничения частоты задаются для существующих маршрутов.
+func TestRouteClasses(t *testing.T) {
+	h := New(nil, nil, Config{})
+	routed := map[string]bool{}
+	_ = h.Router().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err == nil {
+			routed[path] = true
+		}
+		return nil
+	})
+	for path := range routeClasses {
+		if !routed[path] {
+			t.Errorf("вид запроса задан для несуществующего маршрута %s", path)
+		}
+	}
+
+	// Вид запроса определяется в промежуточном обработчике после выбора маршрута
+	var got rateLimit.Class
+	r := h.Router()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = RouteClass(r) })
+	})
+	for path, want := range map[string]rateLimit.Class{
+		"/createtasks":     rateLimit.ClassBulk,
+		"/deletetask?id=1": rateLimit.ClassWrite,
+		"/alltasks":        rateLimit.ClassRead,
+		"/healthz":         rateLimit.ClassNone,
+	} {
+		method := http.MethodGet
+		if want == rateLimit.ClassBulk {
+			method = http.MethodPost
+		}
+		got = "unset"
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+		if got != want {
+			t.Errorf("%s: ожидался вид %q, получено %q", path, want, got)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/events"
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Интервал отправки keep-alive сообщений в поток событий.
+const eventsPingInterval = time.Second * 25
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// CORS у сервиса открыт для всех источников, поэтому и WebSocket принимаем от любых
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// eventsFilter - разбирает фильтр событий из параметров запроса ?assignee=&author=&label=&type=
+func eventsFilter(r *http.Request) (events.Filter, error) {
+	var f events.Filter
+	var err error
+	q := r.URL.Query()
+
+	ints := map[string]*int{
+		"assignee": &f.AssigneeID,
+		"author":   &f.AuthorID,
+		"label":    &f.LabelID,
+	}
+	for name, dst := range ints {
+		if v := q.Get(name); v != "" {
+			*dst, err = strconv.Atoi(v)
+			if err != nil {
+				return f, fmt.Errorf("некорректный параметр %s: %w", name, err)
+			}
+		}
+	}
+
+	for _, v := range q["type"] {
+		for _, t := range strings.Split(v, ",") {
+			if t != "" {
+				f.Types = append(f.Types, t)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// lastEventID - возвращает ID последнего полученного клиентом события из заголовка
+// Last-Event-ID или параметра lastEventId (для клиентов, не умеющих задавать заголовки)
+func lastEventID(r *http.Request) (int64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("lastEventId")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// Events - эндпоинт /events?assignee={id}&author={id}&label={id}&type={type}, поток событий задач (Server-Sent Events).
+// Поддерживает продолжение с места обрыва по заголовку Last-Event-ID.
+func (h *HandlersService) Events(w http.ResponseWriter, r *http.Request) {
+	filter, err := eventsFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	lastID, err := lastEventID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// Поток живет дольше WriteTimeout сервера
+	err = rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		logger.WarnContext(r.Context(), "Не удалось снять таймаут записи: %s", err.Error())
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	sub := h.events.Subscribe(filter, lastID)
+	defer sub.Close()
+
+	for _, e := range sub.Replay {
+		err = writeSSE(w, &e)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "%s", err.Error())
+			return
+		}
+	}
+	err = rc.Flush()
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	ticker := time.NewTicker(eventsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			err = writeSSE(w, &e)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			logger.ErrorContext(r.Context(), "%s", err.Error())
+			return
+		}
+	}
+}
+
+// writeSSE - записывает событие в формате text/event-stream
+func writeSSE(w http.ResponseWriter, e *storage.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// EventsWS - эндпоинт /events/ws?assignee={id}&author={id}&label={id}&type={type}, поток событий задач через WebSocket.
+// Каждое событие отправляется отдельным JSON сообщением, lastEventId передается параметром запроса.
+func (h *HandlersService) EventsWS(w http.ResponseWriter, r *http.Request) {
+	filter, err := eventsFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	lastID, err := lastEventID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	sub := h.events.Subscribe(filter, lastID)
+	defer sub.Close()
+
+	// Чтение нужно для обработки ping/close от клиента, входящие сообщения игнорируются
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, e := range sub.Replay {
+		err = conn.WriteJSON(e)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "%s", err.Error())
+			return
+		}
+	}
+
+	ticker := time.NewTicker(eventsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second*10))
+		case e, ok := <-sub.C:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber is too slow"),
+					time.Now().Add(time.Second*10))
+				return
+			}
+			err = conn.WriteJSON(e)
+		}
+		if err != nil {
+			logger.ErrorContext(r.Context(), "%s", err.Error())
+			return
+		}
+	}
+}
@@ -1,12 +1,23 @@
 package handlersService
 
 import (
+	"TaskManager/pkg/accessLog"
+	"TaskManager/pkg/events"
+	"TaskManager/pkg/graphqlAPI"
+	"TaskManager/pkg/grpcService"
+	"TaskManager/pkg/health"
+	"TaskManager/pkg/idempotency"
 	"TaskManager/pkg/logger"
+	"TaskManager/pkg/metrics"
+	"TaskManager/pkg/rateLimit"
 	"TaskManager/pkg/storage"
+	"TaskManager/pkg/tracing"
 	"TaskManager/pkg/utilities"
 	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
+	"github.com/flowchartsman/swaggerui"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	"log"
@@ -14,21 +25,82 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 )
 
+var wait = flag.Duration("graceful-timeout", time.Second*15, "the duration for which the server gracefully wait for existing connections to finish - e.g. 15s or 1m")
+
+// Config - настройки HTTP сервиса.
+type Config struct {
+	// Токен административного API, пустой - административный API отключен
+	AdminToken string
+	// Публичный адрес сервиса для ссылок
+	PublicURL string
+	// Режим разработки: включает GraphQL playground
+	Dev bool
+	// Адрес gRPC сервера для REST шлюза /v1, пустой - шлюз отключен
+	GRPCAddr string
+	// Вызывается после остановки HTTP сервера, до завершения процесса
+	OnShutdown func(ctx context.Context)
+	// Проверки готовности и фоновые обработчики, nil - только состояние самого HTTP сервиса
+	Health *health.Health
+	// Сколько /readyz отвечает ошибкой перед остановкой сервера, чтобы балансировщик
+	// успел перестать направлять запросы
+	DrainDelay time.Duration
+	// ID запросов и журнал доступа, nil - только ID запросов
+	AccessLog *accessLog.AccessLog
+	// Ограничение частоты запросов, nil - без ограничения. Вид запроса и пользователя
+	// определяют RouteClass и RequestUser
+	RateLimit *rateLimit.Limiter
+	// Ключи идемпотентности (заголовок Idempotency-Key), nil - заголовок не обрабатывается
+	Idempotency *idempotency.Idempotency
+}
+
 type HandlersService struct {
 	storage *storage.Storage
+	events  *events.Hub
+	config  Config
 }
 
-func New(storage *storage.Storage) *HandlersService {
-	return &HandlersService{storage: storage}
+func New(storage *storage.Storage, events *events.Hub, config Config) *HandlersService {
+	if config.Health == nil {
+		config.Health = health.New()
+	}
+	if config.AccessLog == nil {
+		config.AccessLog, _ = accessLog.New(accessLog.Config{Format: accessLog.FormatOff})
+	}
+	return &HandlersService{storage: storage, events: events, config: config}
 }
 
-func (h *HandlersService) PreloadRoutes() {
-	var wait time.Duration
-	flag.DurationVar(&wait, "graceful-timeout", time.Second*15, "the duration for which the server gracefully wait for existing connections to finish - e.g. 15s or 1m")
-	flag.Parse()
+// pageParams - разбирает страницу списка из параметров запроса ?after=&limit=.
+// Без параметров возвращается весь список
+func pageParams(r *http.Request) (storage.Page, error) {
+	var p storage.Page
+	var err error
+	q := r.URL.Query()
+
+	params := []struct {
+		name string
+		dst  *int
+	}{
+		{"after", &p.After},
+		{"limit", &p.Limit},
+	}
+	for _, param := range params {
+		if v := q.Get(param.name); v != "" {
+			*param.dst, err = strconv.Atoi(v)
+			if err != nil || *param.dst < 0 {
+				return p, fmt.Errorf("некорректный параметр %s: %q", param.name, v)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// Router - создает роутер со всеми маршрутами сервиса. Каждый маршрут должен быть описан в openapi.json.
+func (h *HandlersService) Router() *mux.Router {
 	r := mux.NewRouter()
 	//Задачи
 	{
@@ -40,6 +112,8 @@ func (h *HandlersService) PreloadRoutes() {
 		r.HandleFunc("/createtask", h.CreateTask).Methods(http.MethodPost, http.MethodOptions)
 		//Обновление задачи
 		r.HandleFunc("/updatetask", h.UpdateTask).Methods(http.MethodPut, http.MethodOptions)
+		//Частичное изменение задачи (JSON Merge Patch или JSON Patch)
+		r.HandleFunc("/patchtask", h.PatchTask).Queries("id", "{id}").Methods(http.MethodPatch, http.MethodOptions)
 		//Удаление задачи
 		r.HandleFunc("/deletetask", h.DeleteTask).Queries("id", "{id}").Methods(http.MethodGet, http.MethodOptions)
 		//Поиск задачи по taskID и authorID
@@ -53,6 +127,96 @@ func (h *HandlersService) PreloadRoutes() {
 		//Поиск задач по метке и labelID
 		r.HandleFunc("/taskbylabel", h.TaskByLabel).Queries("id", "{id}").Methods(http.MethodGet,
 			http.MethodOptions)
+		//Назначение исполнителя userID на задачу taskID
+		r.HandleFunc("/assigntask", h.AssignTask).Queries("tid", "{tid}").Queries("uid", "{uid}").Methods(http.MethodGet,
+			http.MethodOptions)
+		//Выгрузка задач в CSV
+		r.HandleFunc("/exporttasks", h.ExportTasks).Methods(http.MethodGet, http.MethodOptions)
+		//Загрузка задач из CSV
+		r.HandleFunc("/importtasks", h.ImportTasks).Methods(http.MethodPost, http.MethodOptions)
+		//Массовое изменение задач по списку ID или фильтру
+		r.HandleFunc("/updatetasks", h.UpdateTasks).Methods(http.MethodPut, http.MethodOptions)
+		//Массовое удаление задач по списку ID или фильтру
+		r.HandleFunc("/deletetasks", h.DeleteTasks).Methods(http.MethodPost, http.MethodOptions)
+		//Метки задачи по taskID
+		r.HandleFunc("/tasklabels", h.TaskLabels).Queries("id", "{id}").Methods(http.MethodGet, http.MethodOptions)
+		//Привязка метки labelID к задаче taskID
+		r.HandleFunc("/addtasklabel", h.AddTaskLabel).Queries("tid", "{tid}").Queries("lid", "{lid}").Methods(http.MethodGet,
+			http.MethodOptions)
+		//Отвязка метки labelID от задачи taskID
+		r.HandleFunc("/removetasklabel", h.RemoveTaskLabel).Queries("tid", "{tid}").Queries("lid", "{lid}").Methods(http.MethodGet,
+			http.MethodOptions)
+	}
+
+	//Уведомления
+	{
+		//Уведомления пользователя
+		r.HandleFunc("/notifications", h.Notifications).Queries("uid", "{uid}").Methods(http.MethodGet, http.MethodOptions)
+		//Отметка уведомления прочитанным
+		r.HandleFunc("/readnotification", h.ReadNotification).Queries("id", "{id}").Methods(http.MethodGet, http.MethodOptions)
+		//Отметка всех уведомлений пользователя прочитанными
+		r.HandleFunc("/readallnotifications", h.ReadAllNotifications).Queries("uid", "{uid}").Methods(http.MethodGet,
+			http.MethodOptions)
+		//Количество непрочитанных уведомлений
+		r.HandleFunc("/unreadcount", h.UnreadNotificationsCount).Queries("uid", "{uid}").Methods(http.MethodGet,
+			http.MethodOptions)
+		//Настройки уведомлений пользователя
+		r.HandleFunc("/notificationprefs", h.NotificationPrefs).Queries("uid", "{uid}").Methods(http.MethodGet,
+			http.MethodOptions)
+		//Обновление настроек уведомлений пользователя
+		r.HandleFunc("/updatenotificationprefs", h.UpdateNotificationPrefs).Queries("uid", "{uid}").Methods(http.MethodPut,
+			http.MethodOptions)
+	}
+
+	//Календарь
+	{
+		//Лента iCalendar задач пользователя со сроком
+		r.HandleFunc("/calendar.ics", h.Calendar).Queries("token", "{token}").Methods(http.MethodGet, http.MethodOptions)
+		//Выдача нового токена ленты, токен дает доступ к задачам пользователя
+		r.HandleFunc("/calendartoken", h.adminOnly(h.ResetCalendarToken)).Queries("uid", "{uid}").Methods(http.MethodPost,
+			http.MethodOptions)
+	}
+
+	//Администрирование
+	{
+		//Выгрузка всех данных в архив
+		r.HandleFunc("/admin/export", h.adminOnly(h.ExportBackup)).Methods(http.MethodGet, http.MethodOptions)
+		//Восстановление данных из архива
+		r.HandleFunc("/admin/import", h.adminOnly(h.ImportBackup)).Methods(http.MethodPost, http.MethodOptions)
+	}
+
+	//События
+	{
+		//Поток событий задач (Server-Sent Events)
+		r.HandleFunc("/events", h.Events).Methods(http.MethodGet, http.MethodOptions)
+		//Поток событий задач (WebSocket)
+		r.HandleFunc("/events/ws", h.EventsWS).Methods(http.MethodGet, http.MethodOptions)
+	}
+
+	//GraphQL
+	{
+		gql, err := graphqlAPI.New(h.storage, graphqlAPI.DefaultLimits)
+		if err != nil {
+			logger.Error("GraphQL error: %s", err.Error())
+		} else {
+			//Запросы и мутации GraphQL
+			r.Handle("/graphql", gql).Methods(http.MethodPost, http.MethodOptions)
+			if h.config.Dev {
+				//Отладочная страница GraphiQL
+				r.HandleFunc("/graphql/playground", graphqlAPI.Playground("/graphql")).Methods(http.MethodGet,
+					http.MethodOptions)
+			}
+		}
+	}
+
+	//REST шлюз gRPC API
+	if h.config.GRPCAddr != "" {
+		gw, err := grpcService.Gateway(context.Background(), h.config.GRPCAddr)
+		if err != nil {
+			logger.Error("gRPC gateway error: %s", err.Error())
+		} else {
+			r.PathPrefix("/v1/").Handler(gw)
+		}
 	}
 
 	//Пользователи
@@ -65,8 +229,14 @@ func (h *HandlersService) PreloadRoutes() {
 		r.HandleFunc("/createuser", h.CreateUser).Methods(http.MethodPost, http.MethodOptions)
 		//Обновление юзера
 		r.HandleFunc("/updateuser", h.UpdateUser).Methods(http.MethodPut, http.MethodOptions)
+		//Частичное изменение пользователя
+		r.HandleFunc("/patchuser", h.PatchUser).Queries("id", "{id}").Methods(http.MethodPatch, http.MethodOptions)
+		//Обновление почтовых настроек юзера
+		r.HandleFunc("/updateusermail", h.UpdateUserMail).Methods(http.MethodPut, http.MethodOptions)
 		//Удаление юзера
 		r.HandleFunc("/deleteuser", h.DeleteUser).Queries("id", "{id}").Methods(http.MethodGet, http.MethodOptions)
+		//Деактивация юзера с переназначением его открытых задач
+		r.HandleFunc("/offboarduser", h.OffboardUser).Methods(http.MethodPost, http.MethodOptions)
 	}
 
 	//Метки
@@ -79,11 +249,55 @@ func (h *HandlersService) PreloadRoutes() {
 		r.HandleFunc("/createlabel", h.CreateLabel).Methods(http.MethodPost, http.MethodOptions)
 		//Обновление метки
 		r.HandleFunc("/updatelabel", h.UpdateLabel).Methods(http.MethodPut, http.MethodOptions)
+		//Частичное изменение метки
+		r.HandleFunc("/patchlabel", h.PatchLabel).Queries("id", "{id}").Methods(http.MethodPatch, http.MethodOptions)
 		//Удаление метки
 		r.HandleFunc("/deletelabel", h.DeleteLabel).Queries("id", "{id}").Methods(http.MethodGet, http.MethodOptions)
+		//Метки с количеством задач
+		r.HandleFunc("/labelusage", h.LabelsUsage).Methods(http.MethodGet, http.MethodOptions)
+		//Группы меток с количеством меток и задач
+		r.HandleFunc("/labelscopes", h.LabelScopes).Methods(http.MethodGet, http.MethodOptions)
+		//Метки, названия которых совпадают без учета регистра
+		r.HandleFunc("/labelduplicates", h.DuplicateLabels).Methods(http.MethodGet, http.MethodOptions)
+		//Слияние меток
+		r.HandleFunc("/mergelabels", h.MergeLabels).Methods(http.MethodPost, http.MethodOptions)
+	}
+
+	//Мониторинг
+	{
+		//Метрики Prometheus
+		r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet, http.MethodOptions)
+		//Процесс жив
+		r.HandleFunc("/healthz", h.Healthz).Methods(http.MethodGet, http.MethodOptions)
+		//Готовность принимать запросы
+		r.HandleFunc("/readyz", h.Readyz).Methods(http.MethodGet, http.MethodOptions)
+		//Версия сборки и схемы БД
+		r.HandleFunc("/version", h.Version).Methods(http.MethodGet, http.MethodOptions)
 	}
 
-	r.Use(cors.Default().Handler, mux.CORSMethodMiddleware(r))
+	//Документация
+	{
+		//Спецификация OpenAPI
+		r.HandleFunc("/openapi.json", h.OpenAPI).Methods(http.MethodGet, http.MethodOptions)
+		//Swagger UI
+		r.PathPrefix("/docs/").Handler(http.StripPrefix("/docs", swaggerui.Handler(openAPISpec)))
+	}
+
+	r.NotFoundHandler = h.config.AccessLog.Middleware(http.NotFoundHandler())
+	r.Use(h.config.AccessLog.Middleware, tracing.Middleware(tracing.DefaultServiceName), metrics.Middleware, cors.Default().Handler, mux.CORSMethodMiddleware(r))
+	if h.config.RateLimit != nil {
+		// После CORS, чтобы ответ 429 был доступен браузеру
+		r.Use(h.config.RateLimit.Middleware)
+	}
+	if h.config.Idempotency != nil {
+		// После ограничения запросов: отклоненный запрос не занимает ключ
+		r.Use(h.config.Idempotency.Middleware)
+	}
+	return r
+}
+
+func (h *HandlersService) PreloadRoutes() {
+	r := h.Router()
 	// CORS обработчик
 	crs := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
@@ -109,41 +323,62 @@ func (h *HandlersService) PreloadRoutes() {
 	}()
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	<-c
 
-	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	// Сначала /readyz начинает отвечать ошибкой, и только после того, как балансировщик
+	// это заметит, сервер перестает принимать соединения
+	h.config.Health.Drain()
+	logger.Info("draining for %s", h.config.DrainDelay)
+	time.Sleep(h.config.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *wait)
 	defer cancel()
 	err := srv.Shutdown(ctx)
 	if err != nil {
 		logger.Error("%s", err.Error())
 	}
+	if h.config.OnShutdown != nil {
+		h.config.OnShutdown(ctx)
+	}
 	log.Println("shutting down")
 	os.Exit(0)
 }
 
 //----------------------------------Метки-------------------------------------------------------------
 
-// AllLabels - эндпоинт /alllabels, возвращает массив меток в JSON или 204 код при отсутствии данных
+// AllLabels - эндпоинт /alllabels?after={id}&limit={n}, возвращает массив меток в JSON или 204 код при отсутствии данных
 func (h *HandlersService) AllLabels(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	allLabels, err := h.storage.AllLabels()
+	page, err := pageParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	// Полный список берется из кэша хранилища, страницы читаются из БД
+	var allLabels []storage.Label
+	if page == (storage.Page{}) {
+		allLabels, err = h.storage.AllLabels(r.Context())
+	} else {
+		allLabels, err = h.storage.LabelsPage(r.Context(), page)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
 	str := utilities.ToJSON(allLabels)
 	if str == "null" {
 		http.Error(w, "Метки отсутствуют", http.StatusNoContent)
-		logger.Warn("Пустой массив")
+		logger.WarnContext(r.Context(), "Пустой массив")
 		return
 	}
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -154,125 +389,143 @@ func (h *HandlersService) LabelById(w http.ResponseWriter, r *http.Request) {
 	labelID, err := strconv.Atoi(vars["id"])
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
-	label, err := h.storage.LabelById(labelID)
+	label, err := h.storage.LabelById(r.Context(), labelID)
 	if err != nil && err.Error() != "no rows in result set" {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
 	str := utilities.ToJSON(label)
 	if str == "null" || label.ID == 0 {
 		http.Error(w, "Метка отсутствуют", http.StatusNoContent)
-		logger.Warn("Задача отсутствуют")
+		logger.WarnContext(r.Context(), "Задача отсутствуют")
 		return
 	}
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
-// CreateLabel - эндпоинт /createlabel, возвращает новую метку в JSON или ошибку
+// CreateLabel - эндпоинт /createlabel, возвращает новую метку в JSON или ошибку.
+// Название вида priority::high создает метку high в группе priority
 func (h HandlersService) CreateLabel(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	newLabel := &storage.Label{}
 
 	if err := json.NewDecoder(r.Body).Decode(newLabel); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
-		logger.Error("Ошибка при декодировании тела запроса: %s", err.Error())
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
 		return
 	}
 
-	err := h.storage.NewLabel(newLabel)
+	err := h.storage.NewLabel(r.Context(), newLabel)
 	if err != nil {
-		logger.Error("%s", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		http.Error(w, err.Error(), labelStatus(err))
 		return
 	}
 
 	str := utilities.ToJSON(newLabel)
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
-// UpdateLabel - эндпоинт /updatelabel, возвращает обновленную метку в JSON или ошибку
+// UpdateLabel - эндпоинт /updatelabel, возвращает обновленную метку в JSON или ошибку.
+// Меняются только переданные поля, тело {ID, Name} только переименовывает метку
 func (h HandlersService) UpdateLabel(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	updateLabel := &storage.Label{}
+	update := &labelUpdate{}
 
-	if err := json.NewDecoder(r.Body).Decode(updateLabel); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(update); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
-		logger.Error("Ошибка при декодировании тела запроса: %s", err.Error())
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
 		return
 	}
 
-	logger.Info("update label: %s", utilities.ToJSON(updateLabel))
+	logger.InfoContext(r.Context(), "update label: %s", utilities.ToJSON(update))
 
-	err := h.storage.UpdateLabel(updateLabel)
+	updateLabel, err := h.storage.PatchLabel(r.Context(), update.ID, func(l *storage.Label) error {
+		update.apply(l)
+		return nil
+	})
 	if err != nil {
-		logger.Error("%s", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		http.Error(w, err.Error(), labelStatus(err))
 		return
 	}
 
 	str := utilities.ToJSON(updateLabel)
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
-// DeleteLabel - эндпоинт /deletelabel?id={id}, возвращает удаленную метку в JSON или ошибку
+// DeleteLabel - эндпоинт /deletelabel?id={id}&force=1, возвращает удаленную метку в JSON
+// или ошибку. Метка, привязанная к задачам, удаляется только с force=1, иначе 409
 func (h HandlersService) DeleteLabel(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	deletedLabel, err := h.storage.DeleteLabel(id)
+	deletedLabel, err := h.storage.DeleteLabel(r.Context(), id, r.URL.Query().Get("force") == "1")
 	if err != nil {
-		logger.Error("%s", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		http.Error(w, err.Error(), labelStatus(err))
 		return
 	}
 
 	str := utilities.ToJSON(deletedLabel)
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
 //----------------------------------Пользователи-------------------------------------------------------------
 
-// AllUsers - эндпоинт /allusers, возвращает массив пользователей в JSON или 204 код при отсутствии данных
+// AllUsers - эндпоинт /allusers?after={id}&limit={n}, возвращает массив пользователей в JSON или 204 код при отсутствии данных
 func (h *HandlersService) AllUsers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	allUsers, err := h.storage.AllUsers()
+	page, err := pageParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	// Полный список берется из кэша хранилища, страницы читаются из БД
+	var allUsers []storage.User
+	if page == (storage.Page{}) {
+		allUsers, err = h.storage.AllUsers(r.Context())
+	} else {
+		allUsers, err = h.storage.UsersPage(r.Context(), page)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
 	str := utilities.ToJSON(allUsers)
 	if str == "null" {
 		http.Error(w, "Пользователи отсутствуют", http.StatusNoContent)
-		logger.Warn("Пустой массив")
+		logger.WarnContext(r.Context(), "Пустой массив")
 		return
 	}
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -283,25 +536,25 @@ func (h *HandlersService) UserById(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.Atoi(vars["id"])
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
-	user, err := h.storage.UserById(userID)
+	user, err := h.storage.UserById(r.Context(), userID)
 	if err != nil && err.Error() != "no rows in result set" {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
 	str := utilities.ToJSON(user)
 	if str == "null" || user.ID == 0 {
 		http.Error(w, "Задача отсутствуют", http.StatusNoContent)
-		logger.Warn("Задача отсутствуют")
+		logger.WarnContext(r.Context(), "Задача отсутствуют")
 		return
 	}
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -312,13 +565,13 @@ func (h HandlersService) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(newUser); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
-		logger.Error("Ошибка при декодировании тела запроса: %s", err.Error())
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
 		return
 	}
 
-	err := h.storage.NewUser(newUser)
+	err := h.storage.NewUser(r.Context(), newUser)
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -326,7 +579,7 @@ func (h HandlersService) CreateUser(w http.ResponseWriter, r *http.Request) {
 	str := utilities.ToJSON(newUser)
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -337,15 +590,15 @@ func (h HandlersService) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(updateUser); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
-		logger.Error("Ошибка при декодировании тела запроса: %s", err.Error())
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
 		return
 	}
 
-	logger.Info("update user: %s", utilities.ToJSON(updateUser))
+	logger.InfoContext(r.Context(), "update user: %s", utilities.ToJSON(updateUser))
 
-	err := h.storage.UpdateUser(updateUser)
+	err := h.storage.UpdateUser(r.Context(), updateUser)
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -353,31 +606,58 @@ func (h HandlersService) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	str := utilities.ToJSON(updateUser)
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// UpdateUserMail - эндпоинт /updateusermail, принимает ID, Email, Locale и EmailOptOut пользователя,
+// возвращает обновленного юзера в JSON или ошибку
+func (h HandlersService) UpdateUserMail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	updateUser := &storage.User{}
+
+	if err := json.NewDecoder(r.Body).Decode(updateUser); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
+		return
+	}
+
+	err := h.storage.UpdateUserMail(r.Context(), updateUser)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	str := utilities.ToJSON(updateUser)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
-// DeleteUser - эндпоинт /deleteuser?id={id}, возвращает удаленного юзера в JSON или ошибку
+// DeleteUser - эндпоинт /deleteuser?id={id}, возвращает удаленного юзера в JSON или ошибку.
+// Юзера, который автор или исполнитель задач, удалить нельзя (409), его нужно деактивировать
 func (h HandlersService) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	deletedUser, err := h.storage.DeleteUser(id)
+	deletedUser, err := h.storage.DeleteUser(r.Context(), id)
 	if err != nil {
-		logger.Error("%s", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		http.Error(w, err.Error(), userStatus(err))
 		return
 	}
 
 	str := utilities.ToJSON(deletedUser)
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -390,26 +670,26 @@ func (h *HandlersService) TaskByLabel(w http.ResponseWriter, r *http.Request) {
 	labelID, err := strconv.Atoi(vars["id"])
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
-	task, err := h.storage.TasksByLabel(labelID)
+	task, err := h.storage.TasksByLabel(r.Context(), labelID)
 	if err != nil && err.Error() != "no rows in result set" {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
 	str := utilities.ToJSON(task)
 	if str == "null" {
 		http.Error(w, "Задача отсутствуют", http.StatusNoContent)
-		logger.Warn("Задача отсутствуют")
+		logger.WarnContext(r.Context(), "Задача отсутствуют")
 		return
 	}
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -420,26 +700,26 @@ func (h *HandlersService) TaskByAuthor(w http.ResponseWriter, r *http.Request) {
 	authorID, err := strconv.Atoi(vars["id"])
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
-	task, err := h.storage.TasksByAuthor(authorID)
+	task, err := h.storage.TasksByAuthor(r.Context(), authorID)
 	if err != nil && err.Error() != "no rows in result set" {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
 	str := utilities.ToJSON(task)
 	if str == "null" {
 		http.Error(w, "Задача отсутствуют", http.StatusNoContent)
-		logger.Warn("Задача отсутствуют")
+		logger.WarnContext(r.Context(), "Задача отсутствуют")
 		return
 	}
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -450,32 +730,32 @@ func (h *HandlersService) TaskBy(w http.ResponseWriter, r *http.Request) {
 	taskID, err := strconv.Atoi(vars["tid"])
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 	authorID, err := strconv.Atoi(vars["aid"])
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
-	task, err := h.storage.Tasks(taskID, authorID)
+	task, err := h.storage.Tasks(r.Context(), taskID, authorID)
 	if err != nil && err.Error() != "no rows in result set" {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
 	str := utilities.ToJSON(task)
 	if str == "null" {
 		http.Error(w, "Задача отсутствуют", http.StatusNoContent)
-		logger.Warn("Задача отсутствуют")
+		logger.WarnContext(r.Context(), "Задача отсутствуют")
 		return
 	}
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -486,47 +766,53 @@ func (h *HandlersService) TaskById(w http.ResponseWriter, r *http.Request) {
 	taskID, err := strconv.ParseInt(vars["id"], 10, 64)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
-	task, err := h.storage.TaskById(int(taskID))
+	task, err := h.storage.TaskById(r.Context(), int(taskID))
 	if err != nil && err.Error() != "no rows in result set" {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
 	str := utilities.ToJSON(task)
 	if str == "null" || task.ID == 0 {
 		http.Error(w, "Задача отсутствуют", http.StatusNoContent)
-		logger.Warn("Задача отсутствуют")
+		logger.WarnContext(r.Context(), "Задача отсутствуют")
 		return
 	}
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
-// AllTasks - эндпоинт /alltasks, возвращает массив задач в JSON или 204 код при отсутствии данных
+// AllTasks - эндпоинт /alltasks?after={id}&limit={n}, возвращает массив задач в JSON или 204 код при отсутствии данных
 func (h *HandlersService) AllTasks(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	allTasks, err := h.storage.AllTasks()
+	page, err := pageParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	allTasks, err := h.storage.TasksPage(r.Context(), page)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		return
 	}
 
 	str := utilities.ToJSON(allTasks)
 	if str == "null" {
 		http.Error(w, "Задачи отсутствуют", http.StatusNoContent)
-		logger.Warn("Пустой массив")
+		logger.WarnContext(r.Context(), "Пустой массив")
 		return
 	}
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -537,52 +823,60 @@ func (h HandlersService) CreateTask(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(newTask); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
-		logger.Error("Ошибка при декодировании тела запроса: %s", err.Error())
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
 		return
 	}
 
-	err := h.storage.NewTask(newTask)
+	err := h.storage.NewTask(r.Context(), newTask)
 	if err != nil {
-		logger.Error("%s", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		http.Error(w, err.Error(), patchStatus(err))
 		return
 	}
 
 	str := utilities.ToJSON(newTask)
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
-// CreateTasks - эндпоинт /createtasks, возвращает созданные задачи в JSON или ошибку
+// newTaskRequest - задача в запросе /createtasks с необязательным списком ID меток
+type newTaskRequest struct {
+	storage.Task
+	Labels []int
+}
+
+// CreateTasks - эндпоинт /createtasks, создает задачи с метками в одной транзакции,
+// возвращает созданные задачи в JSON или ошибку
 func (h HandlersService) CreateTasks(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	newTasks := []*storage.Task{}
+	var req []newTaskRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&newTasks); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
-		logger.Error("Ошибка при декодировании тела запроса: %s", err.Error())
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
 		return
 	}
 
-	logger.Info("Массив задач: %s", utilities.ToJSON(newTasks))
+	logger.InfoContext(r.Context(), "Создание %d задач", len(req))
 
-	err := h.storage.NewTasks(newTasks)
+	newTasks := make([]*storage.Task, len(req))
+	labels := make([][]int, len(req))
+	for i := range req {
+		newTasks[i], labels[i] = &req[i].Task, req[i].Labels
+	}
+	err := h.storage.NewTasks(r.Context(), newTasks, labels)
 	if err != nil {
-		logger.Error("%s", err.Error())
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		http.Error(w, err.Error(), patchStatus(err))
 		return
 	}
 
-	for i, task := range newTasks {
-		newTasks[i], err = h.storage.TaskById(task.ID)
-	}
-
 	str := utilities.ToJSON(newTasks)
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -593,15 +887,15 @@ func (h HandlersService) UpdateTask(w http.ResponseWriter, r *http.Request) {
 
 	if err := json.NewDecoder(r.Body).Decode(updateTask); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
-		logger.Error("Ошибка при декодировании тела запроса: %s", err.Error())
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
 		return
 	}
 
-	logger.Info("update task: %s", utilities.ToJSON(updateTask))
+	logger.InfoContext(r.Context(), "update task: %s", utilities.ToJSON(updateTask))
 
-	err := h.storage.UpdateTask(updateTask)
+	err := h.storage.UpdateTask(r.Context(), updateTask)
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -609,7 +903,7 @@ func (h HandlersService) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	str := utilities.ToJSON(updateTask)
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
 
@@ -618,14 +912,14 @@ func (h HandlersService) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	deletedTask, err := h.storage.DeleteTask(int(id))
+	deletedTask, err := h.storage.DeleteTask(r.Context(), int(id))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -633,6 +927,108 @@ func (h HandlersService) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	str := utilities.ToJSON(deletedTask)
 	_, err = w.Write([]byte(str))
 	if err != nil {
-		logger.Error("%s", err.Error())
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// AssignTask - эндпоинт /assigntask?tid={tid}&uid={uid}, возвращает задачу с новым исполнителем в JSON или ошибку.
+// uid=0 снимает исполнителя, назначить задачу на деактивированного юзера нельзя (422)
+func (h HandlersService) AssignTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["tid"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	userID, err := strconv.Atoi(vars["uid"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	task, err := h.storage.AssignTask(r.Context(), taskID, userID)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		http.Error(w, err.Error(), userStatus(err))
+		return
+	}
+
+	str := utilities.ToJSON(task)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// TaskLabels - эндпоинт /tasklabels?id={id}, возвращает метки задачи в JSON или 204 код при отсутствии данных
+func (h *HandlersService) TaskLabels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	taskID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	labels, err := h.storage.TaskLabels(r.Context(), taskID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	str := utilities.ToJSON(labels)
+	if str == "null" {
+		http.Error(w, "Метки отсутствуют", http.StatusNoContent)
+		logger.WarnContext(r.Context(), "Метки отсутствуют")
+		return
+	}
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// AddTaskLabel - эндпоинт /addtasklabel?tid={tid}&lid={lid}, возвращает задачу в JSON или ошибку
+func (h HandlersService) AddTaskLabel(w http.ResponseWriter, r *http.Request) {
+	h.relabelTask(w, r, h.storage.AddTaskLabel)
+}
+
+// RemoveTaskLabel - эндпоинт /removetasklabel?tid={tid}&lid={lid}, возвращает задачу в JSON или ошибку
+func (h HandlersService) RemoveTaskLabel(w http.ResponseWriter, r *http.Request) {
+	h.relabelTask(w, r, h.storage.RemoveTaskLabel)
+}
+
+// relabelTask - общая часть эндпоинтов привязки и отвязки меток
+func (h HandlersService) relabelTask(w http.ResponseWriter, r *http.Request, relabel func(ctx context.Context, taskID, labelID int) (*storage.Task, error)) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+	taskID, err := strconv.Atoi(vars["tid"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	labelID, err := strconv.Atoi(vars["lid"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	task, err := relabel(r.Context(), taskID, labelID)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	str := utilities.ToJSON(task)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
 	}
 }
@@ -0,0 +1,63 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/health"
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/migrator"
+	"TaskManager/pkg/utilities"
+	"context"
+	"net/http"
+)
+
+// writeJSON - пишет v в JSON с кодом code
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_, err := w.Write([]byte(utilities.ToJSON(v)))
+	if err != nil {
+		logger.Error("%s", err.Error())
+	}
+}
+
+// Healthz - эндпоинт /healthz, отвечает 200, пока процесс обрабатывает запросы.
+// Зависимости не проверяются, чтобы недоступность БД не приводила к перезапуску процесса
+func (h *HandlersService) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, health.Report{Status: health.StatusOK, Checks: []health.Result{}})
+}
+
+// Readyz - эндпоинт /readyz, результаты проверок готовности: БД, версия схемы, фоновые обработчики
+// и режим остановки. При непройденной проверке отвечает 503
+func (h *HandlersService) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := h.config.Health.Ready(r.Context())
+	code := http.StatusOK
+	if !report.OK() {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, report)
+}
+
+// versionInfo - ответ /version.
+type versionInfo struct {
+	health.Build
+	// Версия схемы БД, с которой работает сборка
+	SchemaVersion int
+	// Версия схемы в БД, отсутствует если БД недоступна
+	DatabaseSchemaVersion *int `json:",omitempty"`
+}
+
+// Version - эндпоинт /version, версия сборки, Go и схемы БД
+func (h *HandlersService) Version(w http.ResponseWriter, r *http.Request) {
+	info := versionInfo{Build: health.BuildInfo(), SchemaVersion: migrator.SchemaVersion}
+	if h.storage != nil && h.storage.DB != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), health.DefaultCheckTimeout)
+		defer cancel()
+		version, err := migrator.Version(ctx, h.storage)
+		if err != nil {
+			logger.WarnContext(r.Context(), "schema version: %s", err.Error())
+		} else {
+			info.DatabaseSchemaVersion = &version
+		}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
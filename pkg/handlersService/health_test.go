@@ -0,0 +1,44 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/health"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	status := health.New()
+	router := New(nil, nil, Config{Health: status}).Router()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/readyz"); w.Code != http.StatusOK {
+		t.Errorf("/readyz = %d, ожидался 200: %s", w.Code, w.Body)
+	}
+	status.Drain()
+	w := get("/readyz")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz при остановке = %d, ожидался 503", w.Code)
+	}
+	var report health.Report
+	err := json.Unmarshal(w.Body.Bytes(), &report)
+	if err != nil || report.Status != health.StatusFail {
+		t.Errorf("/readyz: %s, %v", w.Body, err)
+	}
+	if w := get("/healthz"); w.Code != http.StatusOK {
+		t.Errorf("/healthz при остановке = %d, ожидался 200", w.Code)
+	}
+
+	var version versionInfo
+	err = json.Unmarshal(get("/version").Body.Bytes(), &version)
+	if err != nil || version.GoVersion != runtime.Version() || version.SchemaVersion == 0 {
+		t.Errorf("/version = %+v, %v", version, err)
+	}
+}
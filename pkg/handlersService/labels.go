@@ -0,0 +1,167 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"TaskManager/pkg/utilities"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// labelStatus - код ответа на ошибку создания, изменения, удаления или слияния меток
+func labelStatus(err error) int {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrLabelExists), errors.Is(err, storage.ErrLabelInUse):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// labelUpdate - тело /updatelabel: не переданные поля (nil) не меняются
+type labelUpdate struct {
+	ID          int
+	Name        *string
+	Scope       *string
+	Color       *string
+	Description *string
+}
+
+// apply - переносит переданные поля в метку. Название вида группа::название без Scope
+// переносит метку в группу, иначе без Scope группа сохраняется
+func (u *labelUpdate) apply(l *storage.Label) {
+	if u.Name != nil {
+		if u.Scope == nil {
+			scope, name := storage.SplitLabelName(*u.Name)
+			if scope != "" {
+				l.Scope = scope
+			}
+			l.Name = name
+		} else {
+			l.Name = *u.Name
+		}
+	}
+	if u.Scope != nil {
+		l.Scope = *u.Scope
+	}
+	if u.Color != nil {
+		l.Color = *u.Color
+	}
+	if u.Description != nil {
+		l.Description = *u.Description
+	}
+}
+
+// LabelsUsage - эндпоинт /labelusage?scope={scope}, возвращает метки с количеством всех
+// и открытых задач в JSON. Без scope возвращаются все метки, с пустым scope - метки без группы
+func (h *HandlersService) LabelsUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var scope *string
+	if values, ok := r.URL.Query()["scope"]; ok {
+		scope = &values[0]
+	}
+	usage, err := h.storage.LabelsUsage(r.Context(), scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	if usage == nil {
+		usage = []storage.LabelUsage{}
+	}
+
+	str := utilities.ToJSON(usage)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// LabelScopes - эндпоинт /labelscopes, возвращает группы меток с количеством меток
+// и задач в JSON. Метки без группы собраны в группу с пустым названием
+func (h *HandlersService) LabelScopes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	scopes, err := h.storage.LabelScopes(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	if scopes == nil {
+		scopes = []storage.LabelScope{}
+	}
+
+	str := utilities.ToJSON(scopes)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// DuplicateLabels - эндпоинт /labelduplicates, возвращает группы меток, полные имена
+// которых совпадают без учета регистра, с количеством задач у каждой метки в JSON
+func (h *HandlersService) DuplicateLabels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	groups, err := h.storage.DuplicateLabels(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	if groups == nil {
+		groups = []storage.DuplicateLabels{}
+	}
+
+	str := utilities.ToJSON(groups)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// mergeLabelsRequest - тело запроса слияния меток
+type mergeLabelsRequest struct {
+	// Метка, которая остается
+	TargetID int
+	// Метки, связи которых переносятся на TargetID, после чего они удаляются
+	SourceIDs []int
+}
+
+// MergeLabels - эндпоинт /mergelabels?dryrun=1, сливает метки SourceIDs в метку TargetID
+// и возвращает отчет в JSON или ошибку
+func (h *HandlersService) MergeLabels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req mergeLabelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
+		return
+	}
+
+	report, err := h.storage.MergeLabels(r.Context(), req.TargetID, req.SourceIDs, r.URL.Query().Get("dryrun") == "1")
+	if err != nil {
+		code := labelStatus(err)
+		http.Error(w, err.Error(), code)
+		if code == http.StatusInternalServerError {
+			logger.ErrorContext(r.Context(), "%s", err.Error())
+		} else {
+			logger.WarnContext(r.Context(), "Слияние меток отклонено: %s", err.Error())
+		}
+		return
+	}
+	if report.Committed {
+		logger.InfoContext(r.Context(), "Метки %v слиты в %d: задач %d", req.SourceIDs, req.TargetID, report.Tasks)
+	}
+
+	str := utilities.ToJSON(report)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
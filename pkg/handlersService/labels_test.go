@@ -0,0 +1,60 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func TestLabelStatus(t *testing.T) {
+	for err, want := range map[error]int{
+		fmt.Errorf("метка 3: %w", pgx.ErrNoRows): http.StatusNotFound,
+		storage.ErrLabelExists:                   http.StatusConflict,
+		storage.ErrLabelInUse:                    http.StatusConflict,
+		storage.ErrInvalid:                       http.StatusUnprocessableEntity,
+		errors.New("нет соединения с БД"):        http.StatusInternalServerError,
+	} {
+		if got := labelStatus(err); got != want {
+			t.Errorf("%v: код %d, ожидался %d", err, got, want)
+		}
+	}
+}
+
+func TestMergeLabelsBadRequest(t *testing.T) {
+	router := New(nil, nil, Config{}).Router()
+	r := httptest.NewRequest(http.MethodPost, "/mergelabels", strings.NewReader(`{"TargetID":"bug"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("код %d, ожидался 400", w.Code)
+	}
+}
+
+func TestLabelUpdateApply(t *testing.T) {
+	current := storage.Label{ID: 3, Scope: "priority", Name: "high", Color: "#ff0000", Description: "срочно"}
+	tests := map[string]storage.Label{
+		// Тело клиентов до появления групп, цвета и описания
+		`{"ID":3,"Name":"urgent"}`:              {ID: 3, Scope: "priority", Name: "urgent", Color: "#ff0000", Description: "срочно"},
+		`{"ID":3,"Name":"area::api"}`:           {ID: 3, Scope: "area", Name: "api", Color: "#ff0000", Description: "срочно"},
+		`{"ID":3,"Name":"high","Scope":""}`:     {ID: 3, Name: "high", Color: "#ff0000", Description: "срочно"},
+		`{"ID":3,"Color":"","Description":"-"}`: {ID: 3, Scope: "priority", Name: "high", Description: "-"},
+	}
+	for body, want := range tests {
+		update := &labelUpdate{}
+		if err := json.Unmarshal([]byte(body), update); err != nil {
+			t.Fatal(err)
+		}
+		got := current
+		update.apply(&got)
+		if got != want {
+			t.Errorf("%s: получено %+v, ожидалось %+v", body, got, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"TaskManager/pkg/utilities"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Notifications - эндпоинт /notifications?uid={uid}[&unread=1], возвращает уведомления пользователя в JSON
+// или 204 код при отсутствии данных
+func (h *HandlersService) Notifications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := strconv.Atoi(mux.Vars(r)["uid"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "1"
+
+	notifications, err := h.storage.Notifications(r.Context(), userID, unreadOnly)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	str := utilities.ToJSON(notifications)
+	if str == "null" {
+		http.Error(w, "Уведомления отсутствуют", http.StatusNoContent)
+		logger.WarnContext(r.Context(), "Пустой массив")
+		return
+	}
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// ReadNotification - эндпоинт /readnotification?id={id}, отмечает уведомление прочитанным и возвращает его в JSON
+func (h *HandlersService) ReadNotification(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	notification, err := h.storage.MarkNotificationRead(r.Context(), id)
+	if err != nil && err.Error() != "no rows in result set" {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+	if notification.ID == 0 {
+		http.Error(w, "Уведомление отсутствует", http.StatusNoContent)
+		logger.WarnContext(r.Context(), "Уведомление отсутствует")
+		return
+	}
+
+	str := utilities.ToJSON(notification)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// ReadAllNotifications - эндпоинт /readallnotifications?uid={uid}, отмечает прочитанными все уведомления
+// пользователя и возвращает их количество в JSON
+func (h *HandlersService) ReadAllNotifications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := strconv.Atoi(mux.Vars(r)["uid"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	count, err := h.storage.MarkAllNotificationsRead(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	str := utilities.ToJSON(map[string]int64{"Count": count})
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// UnreadNotificationsCount - эндпоинт /unreadcount?uid={uid}, возвращает количество непрочитанных
+// уведомлений пользователя в JSON
+func (h *HandlersService) UnreadNotificationsCount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := strconv.Atoi(mux.Vars(r)["uid"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	count, err := h.storage.UnreadNotificationsCount(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	str := utilities.ToJSON(map[string]int{"Count": count})
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// NotificationPrefs - эндпоинт /notificationprefs?uid={uid}, возвращает настройки уведомлений пользователя в JSON
+func (h *HandlersService) NotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := strconv.Atoi(mux.Vars(r)["uid"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	prefs, err := h.storage.NotificationPrefs(r.Context(), userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	str := utilities.ToJSON(prefs)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// UpdateNotificationPrefs - эндпоинт /updatenotificationprefs?uid={uid}, принимает массив настроек
+// и возвращает все настройки уведомлений пользователя в JSON или ошибку
+func (h *HandlersService) UpdateNotificationPrefs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := strconv.Atoi(mux.Vars(r)["uid"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	prefs := []storage.NotificationPref{}
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
+		return
+	}
+	for _, p := range prefs {
+		if !slices.Contains(storage.NotificationTypes, p.Type) {
+			http.Error(w, "Неизвестный тип уведомлений: "+p.Type, http.StatusBadRequest)
+			logger.ErrorContext(r.Context(), "Неизвестный тип уведомлений: %s", p.Type)
+			return
+		}
+	}
+
+	prefs, err = h.storage.SetNotificationPrefs(r.Context(), userID, prefs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+		return
+	}
+
+	str := utilities.ToJSON(prefs)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
@@ -0,0 +1,131 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/jsonPatch"
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"TaskManager/pkg/utilities"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Максимальный размер тела запроса PATCH.
+const maxPatchSize = 1 << 20
+
+// patchFunc - метод хранилища, изменяющий запись с ID функцией apply в транзакции
+type patchFunc[T any] func(ctx context.Context, id int, apply func(v *T) error) (*T, error)
+
+// applyPatch - функция изменения записи по телу запроса: запись кодируется в JSON, к ней
+// применяется изменение, и результат разбирается обратно. Удаленное поле получает нулевое
+// значение, неизвестное поле - ошибка проверки
+func applyPatch[T any](contentType string, patch []byte) func(v *T) error {
+	return func(v *T) error {
+		doc, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		patched, err := jsonPatch.Apply(contentType, doc, patch)
+		if err != nil {
+			return err
+		}
+		var result T
+		d := json.NewDecoder(bytes.NewReader(patched))
+		d.DisallowUnknownFields()
+		err = d.Decode(&result)
+		if err != nil {
+			return fmt.Errorf("%w: %s", storage.ErrInvalid, err.Error())
+		}
+		*v = result
+		return nil
+	}
+}
+
+// patchStatus - код ответа на ошибку изменения
+func patchStatus(err error) int {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, jsonPatch.ErrMalformed):
+		return http.StatusBadRequest
+	case errors.Is(err, jsonPatch.ErrTestFailed), errors.Is(err, storage.ErrLabelExists):
+		return http.StatusConflict
+	case errors.Is(err, jsonPatch.ErrPath), errors.Is(err, storage.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	case errors.As(err, &pgErr) && pgErr.Code == "23503":
+		// Ссылка на несуществующего пользователя
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// servePatch - общий обработчик PATCH: тип изменения определяется заголовком Content-Type,
+// application/merge-patch+json (RFC 7396) или application/json-patch+json (RFC 6902)
+func servePatch[T any](w http.ResponseWriter, r *http.Request, patch patchFunc[T]) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Ошибка в переданном ID: %s", err.Error())
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if !jsonPatch.Supported(contentType) {
+		w.Header().Set("Accept-Patch", jsonPatch.MergePatchType+", "+jsonPatch.JSONPatchType)
+		http.Error(w, jsonPatch.ErrUnsupportedType.Error(), http.StatusUnsupportedMediaType)
+		logger.ErrorContext(r.Context(), "Неподдерживаемый тип изменения: %s", contentType)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Ошибка при чтении тела запроса: %s", err.Error())
+		return
+	}
+
+	result, err := patch(r.Context(), id, applyPatch[T](contentType, body))
+	if err != nil {
+		code := patchStatus(err)
+		http.Error(w, err.Error(), code)
+		if code == http.StatusInternalServerError {
+			logger.ErrorContext(r.Context(), "%s", err.Error())
+		} else {
+			logger.WarnContext(r.Context(), "Изменение отклонено: %s", err.Error())
+		}
+		return
+	}
+
+	str := utilities.ToJSON(result)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
+
+// PatchTask - эндпоинт /patchtask?id={id}, изменяет только переданные поля задачи,
+// возвращает измененную задачу в JSON или ошибку
+func (h *HandlersService) PatchTask(w http.ResponseWriter, r *http.Request) {
+	servePatch(w, r, h.storage.PatchTask)
+}
+
+// PatchUser - эндпоинт /patchuser?id={id}, изменяет только переданные поля пользователя,
+// возвращает измененного пользователя в JSON или ошибку
+func (h *HandlersService) PatchUser(w http.ResponseWriter, r *http.Request) {
+	servePatch(w, r, h.storage.PatchUser)
+}
+
+// PatchLabel - эндпоинт /patchlabel?id={id}, изменяет только переданные поля метки,
+// возвращает измененную метку в JSON или ошибку
+func (h *HandlersService) PatchLabel(w http.ResponseWriter, r *http.Request) {
+	servePatch(w, r, h.storage.PatchLabel)
+}
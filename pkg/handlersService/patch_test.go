@@ -0,0 +1,91 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/jsonPatch"
+	"TaskManager/pkg/storage"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestApplyPatch(t *testing.T) {
+	task := storage.Task{ID: 1, Title: "Задача", Content: "Описание", Closed: 100, Priority: 2}
+	tests := []struct {
+		name, contentType, patch string
+		want                     storage.Task
+		err                      error
+	}{
+		{"merge patch", jsonPatch.MergePatchType, `{"Title":"Новая"}`,
+			storage.Task{ID: 1, Title: "Новая", Content: "Описание", Closed: 100, Priority: 2}, nil},
+		{"merge patch null", jsonPatch.MergePatchType, `{"Content":null,"Closed":0}`,
+			storage.Task{ID: 1, Title: "Задача", Priority: 2}, nil},
+		{"json patch", jsonPatch.JSONPatchType, `[{"op":"test","path":"/Priority","value":2},{"op":"replace","path":"/Priority","value":1}]`,
+			storage.Task{ID: 1, Title: "Задача", Content: "Описание", Closed: 100, Priority: 1}, nil},
+		{"неизвестное поле", jsonPatch.MergePatchType, `{"Status":"done"}`, task, storage.ErrInvalid},
+		{"неверный тип", jsonPatch.JSONPatchType, `[{"op":"replace","path":"/Priority","value":"high"}]`, task, storage.ErrInvalid},
+		{"test", jsonPatch.JSONPatchType, `[{"op":"test","path":"/Title","value":"Другая"}]`, task, jsonPatch.ErrTestFailed},
+	}
+	for _, tt := range tests {
+		got := task
+		err := applyPatch[storage.Task](tt.contentType, []byte(tt.patch))(&got)
+		if !errors.Is(err, tt.err) || (tt.err == nil && got != tt.want) {
+			t.Errorf("%s: %+v, %v, ожидалось %+v, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestPatchStatus(t *testing.T) {
+	for err, want := range map[error]int{
+		pgx.ErrNoRows:                     http.StatusNotFound,
+		jsonPatch.ErrMalformed:            http.StatusBadRequest,
+		jsonPatch.ErrTestFailed:           http.StatusConflict,
+		storage.ErrLabelExists:            http.StatusConflict,
+		jsonPatch.ErrPath:                 http.StatusUnprocessableEntity,
+		storage.ErrInvalid:                http.StatusUnprocessableEntity,
+		&pgconn.PgError{Code: "23503"}:    http.StatusUnprocessableEntity,
+		errors.New("нет соединения с БД"): http.StatusInternalServerError,
+	} {
+		if got := patchStatus(err); got != want {
+			t.Errorf("%v: код %d, ожидался %d", err, got, want)
+		}
+	}
+}
+
+func TestPatchUnsupportedType(t *testing.T) {
+	router := New(nil, nil, Config{}).Router()
+	r := httptest.NewRequest(http.MethodPatch, "/patchtask?id=1", strings.NewReader(`{"Title":"a"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType || w.Header().Get("Accept-Patch") == "" {
+		t.Errorf("код %d, Accept-Patch %q, ожидался 415", w.Code, w.Header().Get("Accept-Patch"))
+	}
+}
+
+func TestCreateTasksStatus(t *testing.T) {
+	// Ошибки NewTasks приходят с номером задачи
+	for err, want := range map[error]int{
+		fmt.Errorf("задача 1: %w: 4", storage.ErrUserInactive):             http.StatusUnprocessableEntity,
+		fmt.Errorf("задача 0: %w: метка 9 не найдена", storage.ErrInvalid): http.StatusUnprocessableEntity,
+		fmt.Errorf("задачи: %w", &pgconn.PgError{Code: "23503"}):           http.StatusUnprocessableEntity,
+		fmt.Errorf("задача 2: %w", errors.New("нет соединения с БД")):      http.StatusInternalServerError,
+	} {
+		if got := patchStatus(err); got != want {
+			t.Errorf("%v: код %d, ожидался %d", err, got, want)
+		}
+	}
+
+	router := New(nil, nil, Config{}).Router()
+	r := httptest.NewRequest(http.MethodPost, "/createtasks", strings.NewReader(`[{"Title":"a","Labels":"bug"}]`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("код %d, ожидался 400", w.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/rateLimit"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// routeClasses - виды запросов маршрутов, которые не определяются методом: массовые
+// операции, изменения данных через GET и не ограничиваемый мониторинг.
+var routeClasses = map[string]rateLimit.Class{
+	"/createtasks":  rateLimit.ClassBulk,
+	"/importtasks":  rateLimit.ClassBulk,
+	"/exporttasks":  rateLimit.ClassBulk,
+	"/updatetasks":  rateLimit.ClassBulk,
+	"/deletetasks":  rateLimit.ClassBulk,
+	"/mergelabels":  rateLimit.ClassBulk,
+	"/offboarduser": rateLimit.ClassBulk,
+	"/admin/export": rateLimit.ClassBulk,
+	"/admin/import": rateLimit.ClassBulk,
+
+	"/deletetask":           rateLimit.ClassWrite,
+	"/assigntask":           rateLimit.ClassWrite,
+	"/addtasklabel":         rateLimit.ClassWrite,
+	"/removetasklabel":      rateLimit.ClassWrite,
+	"/readnotification":     rateLimit.ClassWrite,
+	"/readallnotifications": rateLimit.ClassWrite,
+	"/deleteuser":           rateLimit.ClassWrite,
+	"/deletelabel":          rateLimit.ClassWrite,
+
+	"/metrics": rateLimit.ClassNone,
+	"/healthz": rateLimit.ClassNone,
+	"/readyz":  rateLimit.ClassNone,
+	"/version": rateLimit.ClassNone,
+}
+
+// RouteClass - вид запроса для ограничения частоты запросов: по маршруту из routeClasses,
+// для остальных маршрутов - по методу
+func RouteClass(r *http.Request) rateLimit.Class {
+	if route := mux.CurrentRoute(r); route != nil && r.Method != http.MethodOptions {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			if class, ok := routeClasses[tpl]; ok {
+				return class
+			}
+		}
+	}
+	return rateLimit.ClassifyMethod(r)
+}
+
+// RequestUser - пользователь, от имени которого выполняется запрос: параметр uid
+// эндпоинтов уведомлений и календаря
+func RequestUser(r *http.Request) string {
+	return mux.Vars(r)["uid"]
+}
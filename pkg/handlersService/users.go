@@ -0,0 +1,66 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"TaskManager/pkg/utilities"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// userStatus - код ответа на ошибку удаления или деактивации пользователя и назначения задачи
+func userStatus(err error) int {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrUserInUse):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// offboardUserRequest - тело запроса деактивации пользователя
+type offboardUserRequest struct {
+	UserID int
+	// Новый исполнитель открытых задач пользователя, 0 - оставить задачи без исполнителя
+	ReassignTo int
+}
+
+// OffboardUser - эндпоинт /offboarduser?dryrun=1, деактивирует пользователя UserID
+// с переназначением его открытых задач на ReassignTo и возвращает отчет в JSON или ошибку
+func (h *HandlersService) OffboardUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var req offboardUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		logger.ErrorContext(r.Context(), "Ошибка при декодировании тела запроса: %s", err.Error())
+		return
+	}
+
+	report, err := h.storage.OffboardUser(r.Context(), req.UserID, req.ReassignTo, r.URL.Query().Get("dryrun") == "1")
+	if err != nil {
+		code := userStatus(err)
+		http.Error(w, err.Error(), code)
+		if code == http.StatusInternalServerError {
+			logger.ErrorContext(r.Context(), "%s", err.Error())
+		} else {
+			logger.WarnContext(r.Context(), "Деактивация пользователя отклонена: %s", err.Error())
+		}
+		return
+	}
+	if report.Committed {
+		logger.InfoContext(r.Context(), "Пользователь %d деактивирован, открытых задач переназначено: %d",
+			req.UserID, len(report.Reassigned))
+	}
+
+	str := utilities.ToJSON(report)
+	_, err = w.Write([]byte(str))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "%s", err.Error())
+	}
+}
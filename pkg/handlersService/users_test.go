@@ -0,0 +1,36 @@
+package handlersService
+
+import (
+	"TaskManager/pkg/storage"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func TestUserStatus(t *testing.T) {
+	for err, want := range map[error]int{
+		fmt.Errorf("пользователь 3: %w", pgx.ErrNoRows): http.StatusNotFound,
+		storage.ErrUserInUse:              http.StatusConflict,
+		storage.ErrUserInactive:           http.StatusUnprocessableEntity,
+		errors.New("нет соединения с БД"): http.StatusInternalServerError,
+	} {
+		if got := userStatus(err); got != want {
+			t.Errorf("%v: код %d, ожидался %d", err, got, want)
+		}
+	}
+}
+
+func TestOffboardUserBadRequest(t *testing.T) {
+	router := New(nil, nil, Config{}).Router()
+	r := httptest.NewRequest(http.MethodPost, "/offboarduser?dryrun=1", strings.NewReader(`{"UserID":"alice"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("код %d, ожидался 400", w.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package health
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Commit - ревизия сборки, задается при сборке:
+// go build -ldflags "-X TaskManager/pkg/health.Commit=$(git rev-parse HEAD)".
+// Если не задана, берется из сведений о VCS, которые go build добавляет в бинарный файл.
+var Commit = ""
+
+// Build - сведения о сборке.
+type Build struct {
+	Commit string
+	// Время коммита в формате RFC 3339, если известно
+	CommitTime string `json:",omitempty"`
+	// В рабочей копии были незафиксированные изменения
+	Modified  bool
+	GoVersion string
+}
+
+// BuildInfo - сведения о текущей сборке
+func BuildInfo() Build {
+	b := Build{Commit: Commit, GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if b.Commit == "" {
+					b.Commit = s.Value
+				}
+			case "vcs.time":
+				b.CommitTime = s.Value
+			case "vcs.modified":
+				b.Modified = s.Value == "true"
+			}
+		}
+	}
+	if b.Commit == "" {
+		b.Commit = "unknown"
+	}
+	return b
+}
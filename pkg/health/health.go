@@ -0,0 +1,188 @@
+// Package health - состояние сервиса для оркестратора: проверки готовности,
+// фоновые обработчики, режим остановки и сведения о сборке.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Статусы проверок.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Время, за которое должна завершиться каждая проверка.
+const DefaultCheckTimeout = 2 * time.Second
+
+// Check - проверка зависимости, nil - зависимость доступна.
+type Check func(ctx context.Context) error
+
+// Health - проверки готовности и фоновые обработчики сервиса.
+type Health struct {
+	mu      sync.Mutex
+	checks  map[string]Check
+	workers map[string]*worker
+
+	draining atomic.Bool
+	timeout  time.Duration
+}
+
+// worker - состояние фонового обработчика.
+type worker struct {
+	running bool
+	err     error
+}
+
+// New - конструктор
+func New() *Health {
+	return &Health{
+		checks:  map[string]Check{},
+		workers: map[string]*worker{},
+		timeout: DefaultCheckTimeout,
+	}
+}
+
+// AddCheck - добавляет проверку готовности
+func (h *Health) AddCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// Go - запускает фоновый обработчик в горутине. Пока run не вернулся, обработчик считается
+// работающим; завершившийся обработчик делает сервис неготовым
+func (h *Health) Go(ctx context.Context, name string, run func(ctx context.Context) error) {
+	w := &worker{running: true}
+	h.mu.Lock()
+	h.workers[name] = w
+	h.mu.Unlock()
+
+	go func() {
+		err := run(ctx)
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		w.running = false
+		w.err = err
+	}()
+}
+
+// Drain - переводит сервис в режим остановки: проверка готовности не проходит,
+// и балансировщик перестает направлять новые запросы
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// Draining - сервис останавливается
+func (h *Health) Draining() bool {
+	return h.draining.Load()
+}
+
+// Result - результат одной проверки.
+type Result struct {
+	Name   string
+	Status string
+	Error  string `json:",omitempty"`
+	// Длительность проверки в миллисекундах
+	DurationMs float64
+}
+
+// Report - результат всех проверок готовности.
+type Report struct {
+	Status string
+	Checks []Result
+}
+
+// OK - все проверки прошли
+func (r *Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Ready - выполняет проверки готовности параллельно. В отчете сначала проверки по имени,
+// затем обработчики (worker:имя) и режим остановки (shutdown)
+func (h *Health) Ready(ctx context.Context) *Report {
+	h.mu.Lock()
+	checks := make(map[string]Check, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	var workers []Result
+	for name, w := range h.workers {
+		r := Result{Name: "worker:" + name, Status: StatusOK}
+		if !w.running {
+			r.Status = StatusFail
+			r.Error = "обработчик остановлен"
+			if w.err != nil {
+				r.Error += ": " + w.err.Error()
+			}
+		}
+		workers = append(workers, r)
+	}
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	results := make([]Result, 0, len(checks)+len(workers)+1)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			r := run(ctx, name, check, h.timeout)
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Name < workers[j].Name })
+	results = append(results, workers...)
+
+	shutdown := Result{Name: "shutdown", Status: StatusOK}
+	if h.Draining() {
+		shutdown.Status = StatusFail
+		shutdown.Error = "сервис останавливается"
+	}
+	results = append(results, shutdown)
+
+	report := &Report{Status: StatusOK, Checks: results}
+	for _, r := range results {
+		if r.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// run - выполняет проверку, паника считается ошибкой проверки
+func run(ctx context.Context, name string, check Check, timeout time.Duration) (r Result) {
+	start := time.Now()
+	r = Result{Name: name, Status: StatusOK}
+	defer func() {
+		if p := recover(); p != nil {
+			r.Status = StatusFail
+			r.Error = fmt.Sprintf("паника при проверке: %v", p)
+		}
+		r.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	err := check(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("проверка не завершилась за %s", timeout)
+	}
+	if err != nil {
+		r.Status = StatusFail
+		r.Error = err.Error()
+	}
+	return r
+}
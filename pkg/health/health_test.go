@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReady(t *testing.T) {
+	h := New()
+	h.AddCheck("database", func(ctx context.Context) error { return nil })
+	stop := make(chan struct{})
+	h.Go(context.Background(), "events", func(ctx context.Context) error {
+		<-stop
+		return errors.New("соединение закрыто")
+	})
+
+	report := h.Ready(context.Background())
+	if !report.OK() {
+		t.Fatalf("ожидалась готовность: %+v", report)
+	}
+	names := []string{"database", "worker:events", "shutdown"}
+	if len(report.Checks) != len(names) {
+		t.Fatalf("проверки %+v, ожидались %v", report.Checks, names)
+	}
+	for i, name := range names {
+		if report.Checks[i].Name != name {
+			t.Errorf("проверка %d: %s, ожидалась %s", i, report.Checks[i].Name, name)
+		}
+	}
+
+	close(stop)
+	deadline := time.Now().Add(time.Second)
+	for h.Ready(context.Background()).OK() {
+		if time.Now().After(deadline) {
+			t.Fatal("остановленный обработчик не сделал сервис неготовым")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	r := h.Ready(context.Background()).Checks[1]
+	if r.Status != StatusFail || r.Error != "обработчик остановлен: соединение закрыто" {
+		t.Errorf("worker:events = %+v", r)
+	}
+}
+
+func TestReadyFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		check Check
+		want  string
+	}{
+		{"Ошибка", func(ctx context.Context) error { return errors.New("connection refused") }, "connection refused"},
+		{"Паника", func(ctx context.Context) error { panic("nil pool") }, "паника при проверке: nil pool"},
+		{"Таймаут", func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() }, "проверка не завершилась за 10ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New()
+			h.timeout = 10 * time.Millisecond
+			h.AddCheck("database", tt.check)
+			report := h.Ready(context.Background())
+			if report.OK() || report.Checks[0].Status != StatusFail || report.Checks[0].Error != tt.want {
+				t.Errorf("Ready() = %+v, ожидалась ошибка %q", report, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	h := New()
+	if !h.Ready(context.Background()).OK() {
+		t.Fatal("сервис без проверок должен быть готов")
+	}
+	h.Drain()
+	report := h.Ready(context.Background())
+	if report.OK() || report.Checks[0].Name != "shutdown" {
+		t.Errorf("при остановке ожидалась неготовность: %+v", report)
+	}
+}
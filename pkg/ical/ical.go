@@ -0,0 +1,171 @@
+package ical
+
+import (
+	"TaskManager/pkg/storage"
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Вид записей ленты.
+const (
+	// Задачи (VTODO), поддерживаются Apple Calendar, Thunderbird и др.
+	KindTodo = "todo"
+	// События (VEVENT) в момент срока, для календарей без поддержки задач (Google Calendar)
+	KindEvent = "event"
+)
+
+// Максимальная длина строки в октетах без CRLF (RFC 5545, 3.1).
+const maxLineLength = 75
+
+// Feed - параметры календарной ленты.
+type Feed struct {
+	// Название календаря
+	Name string
+	// Вид записей: KindTodo или KindEvent
+	Kind string
+	// Публичный адрес сервиса для ссылок на задачи; его хост входит в UID записей
+	BaseURL string
+}
+
+// Write - пишет ленту в формате iCalendar. Все времена передаются в UTC, поэтому
+// клиент показывает их в своем часовом поясе без VTIMEZONE. UID записи зависит
+// только от ID задачи, а SEQUENCE растет с каждым изменением, поэтому при
+// обновлении ленты клиенты обновляют записи, а не дублируют их.
+func Write(w io.Writer, feed Feed, tasks []storage.CalendarTask, now time.Time) error {
+	host := "taskmanager"
+	if u, err := url.Parse(feed.BaseURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", "-//TaskManager//Tasks//RU")
+	e.line("CALSCALE", "GREGORIAN")
+	e.line("METHOD", "PUBLISH")
+	e.line("X-WR-CALNAME", escape(feed.Name))
+	e.line("X-PUBLISHED-TTL", "PT1H")
+	e.line("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+
+	for i := range tasks {
+		t := &tasks[i]
+		component := "VTODO"
+		if feed.Kind == KindEvent {
+			component = "VEVENT"
+		}
+		link := fmt.Sprintf("%s/gettask?id=%d", feed.BaseURL, t.ID)
+
+		e.line("BEGIN", component)
+		e.line("UID", fmt.Sprintf("task-%d@%s", t.ID, host))
+		e.line("DTSTAMP", formatTime(now))
+		e.line("CREATED", formatTime(time.Unix(t.Opened, 0)))
+		e.line("LAST-MODIFIED", formatTime(time.Unix(t.Modified, 0)))
+		e.line("SEQUENCE", fmt.Sprint(t.Sequence))
+		e.line("SUMMARY", escape(t.Title))
+		description := t.Content
+		if description != "" {
+			description += "\n\n"
+		}
+		e.line("DESCRIPTION", escape(description+link))
+		e.line("URL", link)
+		if len(t.Labels) > 0 {
+			categories := make([]string, len(t.Labels))
+			for i, l := range t.Labels {
+				categories[i] = escape(l)
+			}
+			e.line("CATEGORIES", strings.Join(categories, ","))
+		}
+		if p := priority(t.Priority); p != 0 {
+			e.line("PRIORITY", fmt.Sprint(p))
+		}
+
+		due := formatTime(time.Unix(t.Due, 0))
+		if feed.Kind == KindEvent {
+			e.line("DTSTART", due)
+			e.line("DURATION", "PT0S")
+			e.line("TRANSP", "TRANSPARENT")
+			e.line("STATUS", "CONFIRMED")
+		} else {
+			e.line("DUE", due)
+			if t.Closed != 0 {
+				e.line("STATUS", "COMPLETED")
+				e.line("COMPLETED", formatTime(time.Unix(t.Closed, 0)))
+				e.line("PERCENT-COMPLETE", "100")
+			} else {
+				e.line("STATUS", "NEEDS-ACTION")
+			}
+		}
+		e.line("END", component)
+	}
+
+	e.line("END", "VCALENDAR")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// encoder - пишет свойства iCalendar с переносом длинных строк
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line - пишет свойство name:value, перенося строку длиннее maxLineLength октетов
+// пробелом в начале продолжения, не разрывая символы UTF-8
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	s := name + ":" + value
+	limit := maxLineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		_, e.err = e.w.WriteString(s[:cut] + "\r\n ")
+		if e.err != nil {
+			return
+		}
+		s = s[cut:]
+		// Пробел в начале продолжения занимает один октет
+		limit = maxLineLength - 1
+	}
+	_, e.err = e.w.WriteString(s + "\r\n")
+}
+
+// escape - экранирует значение типа TEXT (RFC 5545, 3.3.11)
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+// formatTime - время в UTC в формате DATE-TIME
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// priority - приоритет задачи (0-3) в шкале iCalendar: 1 - высокий, 5 - средний, 9 - низкий, 0 - не задан
+func priority(p int) int {
+	switch p {
+	case 1:
+		return 1
+	case 2:
+		return 5
+	case 3:
+		return 9
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package ical
+
+import (
+	"TaskManager/pkg/storage"
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testTasks() []storage.CalendarTask {
+	return []storage.CalendarTask{
+		{
+			TaskDetails: storage.TaskDetails{
+				Task: storage.Task{
+					ID:       7,
+					Opened:   1700000000,
+					Due:      1700086400,
+					Title:    "Отчет, квартал; итоги",
+					Content:  "первая строка\nвторая",
+					Priority: 1,
+				},
+				Labels: []string{"работа", "a,b"},
+			},
+			Modified: 1700001000,
+			Sequence: 2,
+		},
+		{
+			TaskDetails: storage.TaskDetails{
+				Task: storage.Task{ID: 8, Opened: 1700000000, Closed: 1700050000, Due: 1700086400, Title: "Готово"},
+			},
+			Modified: 1700050000,
+		},
+	}
+}
+
+func TestWriteTodo(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("MSK", 3*3600))
+	err := Write(&buf, Feed{Name: "Задачи", Kind: KindTodo, BaseURL: "https://tm.example.com"}, testTasks(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:task-7@tm.example.com\r\n",
+		"DTSTAMP:20240102T000405Z\r\n",
+		"DUE:20231115T221320Z\r\n",
+		"SEQUENCE:2\r\n",
+		`SUMMARY:Отчет\, квартал\; итоги` + "\r\n",
+		`CATEGORIES:работа,a\,b` + "\r\n",
+		"PRIORITY:1\r\n",
+		"STATUS:NEEDS-ACTION\r\n",
+		"STATUS:COMPLETED\r\n",
+		"COMPLETED:20231115T120640Z\r\n",
+		"URL:https://tm.example.com/gettask?id=8\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("нет строки %q в\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "BEGIN:VTODO") != 2 || strings.Contains(out, "VEVENT") {
+		t.Errorf("ожидались две записи VTODO:\n%s", out)
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Feed{Kind: KindEvent, BaseURL: "http://localhost:8010"}, testTasks()[:1], time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"BEGIN:VEVENT\r\n", "DTSTART:20231115T221320Z\r\n", "UID:task-7@localhost\r\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("нет строки %q в\n%s", want, out)
+		}
+	}
+}
+
+func TestFolding(t *testing.T) {
+	var buf bytes.Buffer
+	e := &encoder{w: bufio.NewWriter(&buf)}
+	e.line("SUMMARY", strings.Repeat("ж", 100))
+	e.w.Flush()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	if len(lines) < 3 {
+		t.Fatalf("ожидался перенос строки: %q", buf.String())
+	}
+	var joined strings.Builder
+	for i, l := range lines {
+		if len(l) > maxLineLength {
+			t.Errorf("строка %d длиннее %d октетов: %d", i, maxLineLength, len(l))
+		}
+		if i > 0 {
+			if !strings.HasPrefix(l, " ") {
+				t.Errorf("продолжение %d без пробела", i)
+			}
+			l = l[1:]
+		}
+		joined.WriteString(l)
+	}
+	if joined.String() != "SUMMARY:"+strings.Repeat("ж", 100) {
+		t.Errorf("после склейки строка изменилась: %q", joined.String())
+	}
+}
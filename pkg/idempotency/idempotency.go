@@ -0,0 +1,253 @@
+// Package idempotency - ключи идемпотентности для HTTP API: ответ на запрос с заголовком
+// Idempotency-Key сохраняется, и повтор запроса с тем же ключом получает сохраненный ответ,
+// а не выполняется второй раз.
+package idempotency
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/metrics"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Заголовки ключа и признака повторного ответа.
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// Максимальная длина ключа.
+const maxKeyLength = 255
+
+// Response - сохраненный ответ. Status 0 - запрос еще выполняется.
+type Response struct {
+	// Отпечаток запроса: метод, путь, параметры и тело
+	Fingerprint string
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// Store - хранилище ключей.
+type Store interface {
+	// Claim - занимает key для запроса с отпечатком fingerprint от имени claim - случайного
+	// идентификатора выполнения запроса. Возвращает nil, если ключ занят этим вызовом, иначе
+	// сохраненный ответ или выполняющийся запрос с этим ключом. Ключ хранится ttl,
+	// выполняющийся дольше lock запрос с тем же отпечатком можно повторить
+	Claim(ctx context.Context, key, claim, fingerprint string, ttl, lock time.Duration) (*Response, error)
+	// Save - сохраняет ответ, если ключ все еще занят claim, а не перехвачен повтором
+	Save(ctx context.Context, key, claim string, r *Response) error
+	// Release - освобождает ключ невыполненного запроса, если он все еще занят claim
+	Release(ctx context.Context, key, claim string) error
+}
+
+// Config - настройки.
+type Config struct {
+	// Сколько хранится ответ, по умолчанию 24 часа
+	TTL time.Duration
+	// Через сколько выполняющийся запрос считается прерванным, по умолчанию минута
+	LockTimeout time.Duration
+	// Максимальный размер тела запроса с ключом, по умолчанию 32 МБ
+	MaxBodySize int64
+	// Хранилище ключей, nil - в памяти процесса
+	Store Store
+}
+
+// Idempotency - промежуточный обработчик ключей идемпотентности.
+type Idempotency struct {
+	config Config
+}
+
+// New - конструктор
+func New(config Config) *Idempotency {
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = time.Minute
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 32 << 20
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	return &Idempotency{config: config}
+}
+
+// Middleware - обрабатывает запросы с заголовком Idempotency-Key, кроме GET, HEAD и OPTIONS.
+// Первый запрос с ключом выполняется, и его ответ сохраняется; ответы с кодом 5xx не
+// сохраняются, чтобы запрос можно было повторить. Повтор получает сохраненный ответ
+// с заголовком Idempotent-Replayed: true, повтор во время выполнения - 409, тот же ключ
+// с другим запросом - 422. При ошибке хранилища запрос выполняется без ключа
+func (i *Idempotency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			http.Error(w, "Слишком длинный Idempotency-Key", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, i.config.MaxBodySize))
+		if err != nil {
+			http.Error(w, "Ошибка чтения тела запроса: "+err.Error(), http.StatusRequestEntityTooLarge)
+			logger.ErrorContext(r.Context(), "Ошибка чтения тела запроса с Idempotency-Key: %s", err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key = scope(r) + ":" + key
+		fp := fingerprint(r, body)
+		claim, err := newClaim()
+		if err == nil {
+			var saved *Response
+			saved, err = i.config.Store.Claim(r.Context(), key, claim, fp, i.config.TTL, i.config.LockTimeout)
+			if err == nil && saved != nil {
+				i.replay(w, r, saved, fp)
+				return
+			}
+		}
+		if err != nil {
+			logger.ErrorContext(r.Context(), "Ошибка хранилища ключей идемпотентности: %s", err.Error())
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			// Новый контекст: запрос мог быть отменен, а ключ все равно нужно освободить или сохранить
+			ctx := context.WithoutCancel(r.Context())
+			if !completed || rec.status() >= http.StatusInternalServerError {
+				err := i.config.Store.Release(ctx, key, claim)
+				if err != nil {
+					logger.ErrorContext(ctx, "Ошибка освобождения ключа идемпотентности: %s", err.Error())
+				}
+				return
+			}
+			err := i.config.Store.Save(ctx, key, claim, &Response{
+				Fingerprint: fp,
+				Status:      rec.status(),
+				Header:      storedHeader(rec.Header()),
+				Body:        rec.body.Bytes(),
+			})
+			if err != nil {
+				logger.ErrorContext(ctx, "Ошибка сохранения ответа с ключом идемпотентности: %s", err.Error())
+			}
+		}()
+		next.ServeHTTP(rec, r)
+		completed = true
+	})
+}
+
+// newClaim - случайный идентификатор выполнения запроса с ключом: запрос, ключ которого
+// перехватил повтор после LockTimeout, не освободит и не перезапишет ключ повтора
+func newClaim() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// replay - отвечает на повтор запроса с занятым ключом
+func (i *Idempotency) replay(w http.ResponseWriter, r *http.Request, saved *Response, fp string) {
+	switch {
+	case saved.Fingerprint != fp:
+		metrics.ObserveIdempotency("mismatch")
+		http.Error(w, "Idempotency-Key уже использован с другим запросом", http.StatusUnprocessableEntity)
+	case saved.Status == 0:
+		metrics.ObserveIdempotency("in_progress")
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Запрос с этим Idempotency-Key еще выполняется", http.StatusConflict)
+	default:
+		metrics.ObserveIdempotency("replayed")
+		for name, values := range saved.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(saved.Status)
+		_, err := w.Write(saved.Body)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "%s", err.Error())
+		}
+	}
+}
+
+// scope - владелец ключа: хэш токена из Authorization, чтобы ключи разных клиентов не совпадали
+func scope(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// fingerprint - отпечаток запроса: метод, путь с параметрами и тело
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Заголовки ответа, которые сохраняются для повтора. Остальные (RateLimit-*, X-Request-ID)
+// относятся к конкретному запросу.
+var storedHeaders = []string{"Content-Type", "Location", "Cache-Control"}
+
+func storedHeader(h http.Header) http.Header {
+	stored := http.Header{}
+	for _, name := range storedHeaders {
+		if values := h.Values(name); len(values) > 0 {
+			stored[name] = values
+		}
+	}
+	return stored
+}
+
+// recorder - передает ответ клиенту и запоминает его для сохранения.
+type recorder struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// status - код ответа, 200 если обработчик ничего не записал
+func (r *recorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
+
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
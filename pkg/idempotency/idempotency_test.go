@@ -0,0 +1,146 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func post(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/createtask", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestReplay(t *testing.T) {
+	var calls atomic.Int32
+	h := New(Config{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-ID", "req")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ID":` + string('0'+n) + `}`))
+	}))
+
+	first := post(h, "k1", `{"Title":"a"}`)
+	second := post(h, "k1", `{"Title":"a"}`)
+	if calls.Load() != 1 {
+		t.Fatalf("обработчик вызван %d раз, ожидался 1", calls.Load())
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("повтор: %d %s, ожидался %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(ReplayedHeader) != "true" || second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("заголовки повтора: %v", second.Header())
+	}
+	if second.Header().Get("X-Request-ID") != "" {
+		t.Error("заголовок конкретного запроса не должен сохраняться")
+	}
+
+	if w := post(h, "k1", `{"Title":"b"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("ключ с другим телом: %d, ожидался 422", w.Code)
+	}
+	post(h, "k2", `{"Title":"a"}`)
+	post(h, "", `{"Title":"a"}`)
+	post(h, "", `{"Title":"a"}`)
+	if calls.Load() != 4 {
+		t.Errorf("обработчик вызван %d раз, ожидалось 4", calls.Load())
+	}
+}
+
+func TestServerErrorNotStored(t *testing.T) {
+	var calls atomic.Int32
+	h := New(Config{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "нет БД", http.StatusInternalServerError)
+		}
+	}))
+	post(h, "k", "")
+	if w := post(h, "k", ""); w.Code != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("после ошибки запрос должен выполниться заново: %d, вызовов %d", w.Code, calls.Load())
+	}
+}
+
+func TestInProgress(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	started, release := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	h := New(Config{Store: store, LockTimeout: time.Minute}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		post(h, "k", "")
+		close(done)
+	}()
+	<-started
+	w := post(h, "k", "")
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("повтор во время выполнения: %d, ожидался 409 с Retry-After", w.Code)
+	}
+
+	// Зависший запрос можно повторить после LockTimeout
+	now = now.Add(2 * time.Minute)
+	if w := post(h, "k", ""); w.Code != http.StatusOK || calls.Load() != 2 {
+		t.Errorf("повтор после LockTimeout: %d, вызовов %d", w.Code, calls.Load())
+	}
+	close(release)
+	<-done
+}
+
+func TestClaimTakeover(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if saved, _ := store.Claim(ctx, "k", "a", "f", time.Hour, time.Minute); saved != nil {
+		t.Fatalf("первый захват: %+v", saved)
+	}
+	now = now.Add(2 * time.Minute)
+	if saved, _ := store.Claim(ctx, "k", "b", "f", time.Hour, time.Minute); saved != nil {
+		t.Fatalf("захват после LockTimeout: %+v", saved)
+	}
+
+	// Зависший запрос не освобождает и не перезаписывает чужой захват
+	store.Release(ctx, "k", "a")
+	store.Save(ctx, "k", "a", &Response{Status: http.StatusCreated, Fingerprint: "f"})
+	if saved, _ := store.Claim(ctx, "k", "c", "f", time.Hour, time.Minute); saved == nil || saved.Status != 0 {
+		t.Fatalf("после Release и Save старого захвата: %+v, ожидался ключ в работе", saved)
+	}
+
+	store.Save(ctx, "k", "b", &Response{Status: http.StatusOK, Fingerprint: "f"})
+	if saved, _ := store.Claim(ctx, "k", "c", "f", time.Hour, time.Minute); saved == nil || saved.Status != http.StatusOK {
+		t.Errorf("после Save: %+v, ожидался сохраненный ответ", saved)
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	var calls atomic.Int32
+	h := New(Config{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls.Add(1) }))
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/alltasks", nil)
+		r.Header.Set(Header, "k")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("GET с ключом должен выполняться каждый раз, вызовов %d", calls.Load())
+	}
+	if w := post(h, strings.Repeat("k", maxKeyLength+1), ""); w.Code != http.StatusBadRequest {
+		t.Errorf("длинный ключ: %d, ожидался 400", w.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Как часто MemoryStore удаляет ключи с истекшим сроком хранения.
+const sweepInterval = time.Minute
+
+// MemoryStore - ключи в памяти процесса. Подходит для одного экземпляра сервиса:
+// повтор запроса, пришедший на другой экземпляр, выполнится второй раз.
+type MemoryStore struct {
+	mu   sync.Mutex
+	keys map[string]*memoryKey
+	// Время последнего удаления ключей
+	swept time.Time
+	now   func() time.Time
+}
+
+type memoryKey struct {
+	claim       string
+	response    Response
+	expires     time.Time
+	lockedUntil time.Time
+}
+
+// NewMemoryStore - конструктор
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: map[string]*memoryKey{}, now: time.Now}
+}
+
+func (m *MemoryStore) Claim(ctx context.Context, key, claim, fingerprint string, ttl, lock time.Duration) (*Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.swept) > sweepInterval {
+		m.sweep(now)
+	}
+
+	k, ok := m.keys[key]
+	if ok && now.Before(k.expires) &&
+		(k.response.Status != 0 || now.Before(k.lockedUntil) || k.response.Fingerprint != fingerprint) {
+		r := k.response
+		return &r, nil
+	}
+	m.keys[key] = &memoryKey{
+		claim:       claim,
+		response:    Response{Fingerprint: fingerprint},
+		expires:     now.Add(ttl),
+		lockedUntil: now.Add(lock),
+	}
+	return nil, nil
+}
+
+func (m *MemoryStore) Save(ctx context.Context, key, claim string, r *Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.keys[key]; ok && k.claim == claim && k.response.Status == 0 {
+		k.response = *r
+	}
+	return nil
+}
+
+func (m *MemoryStore) Release(ctx context.Context, key, claim string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.keys[key]; ok && k.claim == claim && k.response.Status == 0 {
+		delete(m.keys, key)
+	}
+	return nil
+}
+
+// sweep - удаляет ключи с истекшим сроком хранения
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, k := range m.keys {
+		if !now.Before(k.expires) {
+			delete(m.keys, key)
+		}
+	}
+	m.swept = now
+}
@@ -0,0 +1,67 @@
+package idempotency
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// PostgresStore - ключи в таблице idempotency_keys, общие для всех экземпляров сервиса.
+// Одновременные повторы запроса на разных экземплярах выполняются один раз.
+type PostgresStore struct {
+	storage *storage.Storage
+	// Время последнего удаления ключей, unix time
+	swept atomic.Int64
+}
+
+// NewPostgresStore - конструктор
+func NewPostgresStore(s *storage.Storage) *PostgresStore {
+	return &PostgresStore{storage: s}
+}
+
+func (p *PostgresStore) Claim(ctx context.Context, key, claim, fingerprint string, ttl, lock time.Duration) (*Response, error) {
+	p.sweep()
+	saved, err := p.storage.ClaimIdempotencyKey(ctx, key, claim, fingerprint, ttl, lock)
+	if err != nil || saved == nil {
+		return nil, err
+	}
+	return &Response{
+		Fingerprint: saved.Fingerprint,
+		Status:      saved.Status,
+		Header:      http.Header(saved.Header),
+		Body:        saved.Body,
+	}, nil
+}
+
+func (p *PostgresStore) Save(ctx context.Context, key, claim string, r *Response) error {
+	return p.storage.SaveIdempotentResponse(ctx, key, claim, &storage.IdempotentResponse{
+		Fingerprint: r.Fingerprint,
+		Status:      r.Status,
+		Header:      r.Header,
+		Body:        r.Body,
+	})
+}
+
+func (p *PostgresStore) Release(ctx context.Context, key, claim string) error {
+	return p.storage.ReleaseIdempotencyKey(ctx, key, claim)
+}
+
+// sweep - не чаще раза в sweepInterval удаляет в фоне ключи с истекшим сроком хранения
+func (p *PostgresStore) sweep() {
+	now := time.Now().Unix()
+	last := p.swept.Load()
+	if now-last < int64(sweepInterval.Seconds()) || !p.swept.CompareAndSwap(last, now) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := p.storage.DeleteExpiredIdempotencyKeys(ctx)
+		if err != nil {
+			logger.Error("Ошибка удаления ключей идемпотентности: %s", err.Error())
+		}
+	}()
+}
@@ -0,0 +1,390 @@
+// Package jsonPatch - частичное изменение JSON документов: JSON Merge Patch (RFC 7396)
+// и JSON Patch (RFC 6902).
+package jsonPatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Типы содержимого запросов PATCH.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// Ошибки изменения документа.
+var (
+	// Тип содержимого не поддерживается
+	ErrUnsupportedType = errors.New("тип изменения не поддерживается, ожидается " + MergePatchType + " или " + JSONPatchType)
+	// Изменение не разбирается или содержит неизвестную операцию
+	ErrMalformed = errors.New("некорректное изменение")
+	// Операция test не выполнена: документ изменился
+	ErrTestFailed = errors.New("проверка test не выполнена")
+	// Путь операции не существует в документе
+	ErrPath = errors.New("некорректный путь")
+)
+
+// mediaType - тип содержимого без параметров (charset)
+func mediaType(contentType string) string {
+	t, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(strings.ToLower(t))
+}
+
+// Supported - изменение типа contentType поддерживается
+func Supported(contentType string) bool {
+	t := mediaType(contentType)
+	return t == MergePatchType || t == JSONPatchType
+}
+
+// Apply - применяет к документу doc изменение patch типа contentType
+func Apply(contentType string, doc, patch []byte) ([]byte, error) {
+	switch mediaType(contentType) {
+	case MergePatchType:
+		return MergePatch(doc, patch)
+	case JSONPatchType:
+		return Patch(doc, patch)
+	}
+	return nil, ErrUnsupportedType
+}
+
+// decode - разбирает JSON с числами json.Number, чтобы не терять точность больших чисел
+func decode(data []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	err := d.Decode(v)
+	if err != nil {
+		return err
+	}
+	if d.More() {
+		return errors.New("лишние данные после JSON")
+	}
+	return nil
+}
+
+//-------------------JSON Merge Patch-------------------------
+
+// MergePatch - применяет JSON Merge Patch (RFC 7396): поля объекта patch заменяют поля
+// документа, null удаляет поле, вложенные объекты изменяются рекурсивно
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var d, p any
+	err := decode(doc, &d)
+	if err != nil {
+		return nil, err
+	}
+	err = decode(patch, &p)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformed, err.Error())
+	}
+	return json.Marshal(mergePatch(d, p))
+}
+
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = mergePatch(t[name], value)
+	}
+	return t
+}
+
+//-------------------JSON Patch-------------------------
+
+// operation - операция JSON Patch.
+type operation struct {
+	Op    string
+	Path  *string
+	From  *string
+	Value *json.RawMessage
+}
+
+// Patch - применяет JSON Patch (RFC 6902): операции add, remove, replace, move, copy
+// и test выполняются по порядку, при ошибке любой из них документ не изменяется
+func Patch(doc, patch []byte) ([]byte, error) {
+	var d any
+	err := decode(doc, &d)
+	if err != nil {
+		return nil, err
+	}
+	var ops []operation
+	err = json.Unmarshal(patch, &ops)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformed, err.Error())
+	}
+
+	for i, op := range ops {
+		d, err = apply(d, op)
+		if err != nil {
+			return nil, fmt.Errorf("операция %d (%s): %w", i, op.Op, err)
+		}
+	}
+	return json.Marshal(d)
+}
+
+// apply - выполняет одну операцию и возвращает измененный документ
+func apply(doc any, op operation) (any, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: не задан path", ErrMalformed)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: не задан value", ErrMalformed)
+		}
+		err = decode(*op.Value, &value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrMalformed, err.Error())
+		}
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: не задан from", ErrMalformed)
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err = get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if isPrefix(from, path) && len(from) < len(path) {
+				return nil, fmt.Errorf("%w: нельзя переместить значение внутрь самого себя", ErrPath)
+			}
+			doc, err = remove(doc, from)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			value = clone(value)
+		}
+	case "remove":
+		return remove(doc, path)
+	default:
+		return nil, fmt.Errorf("%w: неизвестная операция %q", ErrMalformed, op.Op)
+	}
+
+	switch op.Op {
+	case "replace":
+		_, err = get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return set(doc, path, value, true)
+	case "test":
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, fmt.Errorf("%w: значение %s отличается", ErrTestFailed, *op.Path)
+		}
+		return doc, nil
+	}
+	return set(doc, path, value, false)
+}
+
+// parsePointer - разбирает JSON Pointer (RFC 6901)
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("%w: %q должен начинаться с /", ErrMalformed, s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// index - индекс элемента массива длины n, "-" разрешен при добавлении и означает конец массива
+func index(token string, n int, adding bool) (int, error) {
+	if adding && token == "-" {
+		return n, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: некорректный индекс %q", ErrPath, token)
+	}
+	i, err := strconv.Atoi(token)
+	limit := n - 1
+	if adding {
+		limit = n
+	}
+	if err != nil || i > limit {
+		return 0, fmt.Errorf("%w: индекс %s вне массива", ErrPath, token)
+	}
+	return i, nil
+}
+
+// get - значение по пути
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch v := doc.(type) {
+		case map[string]any:
+			value, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: поле %q не найдено", ErrPath, token)
+			}
+			doc = value
+		case []any:
+			i, err := index(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("%w: %q не объект и не массив", ErrPath, token)
+		}
+	}
+	return doc, nil
+}
+
+// set - добавляет значение по пути или, при replace, заменяет элемент массива
+func set(doc any, path []string, value any, replace bool) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = value
+		return doc, nil
+	case []any:
+		i, err := index(last, len(p), !replace)
+		if err != nil {
+			return nil, err
+		}
+		if replace {
+			p[i] = value
+			return doc, nil
+		}
+		p = append(p[:i], append([]any{value}, p[i:]...)...)
+		return set(doc, path[:len(path)-1], p, true)
+	}
+	return nil, fmt.Errorf("%w: родитель %q не объект и не массив", ErrPath, last)
+}
+
+// remove - удаляет значение по пути
+func remove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		if _, ok := p[last]; !ok {
+			return nil, fmt.Errorf("%w: поле %q не найдено", ErrPath, last)
+		}
+		delete(p, last)
+		return doc, nil
+	case []any:
+		i, err := index(last, len(p), false)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p[:i:i], p[i+1:]...)
+		return set(doc, path[:len(path)-1], p, true)
+	}
+	return nil, fmt.Errorf("%w: родитель %q не объект и не массив", ErrPath, last)
+}
+
+// clone - глубокая копия значения, чтобы copy не связывал два места документа
+func clone(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for k, e := range v {
+			c[k] = clone(e)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, e := range v {
+			c[i] = clone(e)
+		}
+		return c
+	}
+	return v
+}
+
+// equal - сравнение значений по правилам test: числа сравниваются по значению
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		bn, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == bn {
+			return true
+		}
+		af, errA := a.Float64()
+		bf, errB := bn.Float64()
+		return errA == nil && errB == nil && af == bf
+	case map[string]any:
+		bm, ok := b.(map[string]any)
+		if !ok || len(a) != len(bm) {
+			return false
+		}
+		for k, v := range a {
+			bv, ok := bm[k]
+			if !ok || !equal(v, bv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		ba, ok := b.([]any)
+		if !ok || len(a) != len(ba) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], ba[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -0,0 +1,105 @@
+package jsonPatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func jsonEqual(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("результат не JSON: %s", got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("ожидаемое значение не JSON: %s", want)
+	}
+	return reflect.DeepEqual(g, w)
+}
+
+// Примеры из приложения A RFC 7396.
+func TestMergePatch(t *testing.T) {
+	tests := []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		got, err := Apply(MergePatchType+"; charset=utf-8", []byte(tt.doc), []byte(tt.patch))
+		if err != nil || !jsonEqual(t, got, tt.want) {
+			t.Errorf("%s + %s = %s, %v, ожидалось %s", tt.doc, tt.patch, got, err, tt.want)
+		}
+	}
+}
+
+// Примеры из приложения A RFC 6902.
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+		err                    error
+	}{
+		{"add в объект", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{"add в массив", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{"add в конец", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, nil},
+		{"remove", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{"remove из массива", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{"move", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil},
+		{"move в массиве", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`, nil},
+		{"copy", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`,
+			`{"a":{"b":1},"c":{"b":2}}`, nil},
+		{"test", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`, nil},
+		{"экранирование", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`, `{"~1":10}`, nil},
+		{"test не выполнен", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``, ErrTestFailed},
+		{"нет поля", `{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, ``, ErrPath},
+		{"нет родителя", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``, ErrPath},
+		{"индекс вне массива", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":1}]`, ``, ErrPath},
+		{"ведущий ноль", `{"foo":["bar","baz"]}`, `[{"op":"remove","path":"/foo/01"}]`, ``, ErrPath},
+		{"неизвестная операция", `{}`, `[{"op":"merge","path":"/a","value":1}]`, ``, ErrMalformed},
+		{"нет value", `{}`, `[{"op":"add","path":"/a"}]`, ``, ErrMalformed},
+		{"не массив", `{}`, `{"op":"add","path":"/a","value":1}`, ``, ErrMalformed},
+		{"move внутрь себя", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`, ``, ErrPath},
+	}
+	for _, tt := range tests {
+		got, err := Apply(JSONPatchType, []byte(tt.doc), []byte(tt.patch))
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: ожидалась ошибка %v, получено %s, %v", tt.name, tt.err, got, err)
+			}
+			continue
+		}
+		if err != nil || !jsonEqual(t, got, tt.want) {
+			t.Errorf("%s: %s, %v, ожидалось %s", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestUnsupportedType(t *testing.T) {
+	_, err := Apply("application/json", []byte(`{}`), []byte(`{}`))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("ожидалась ErrUnsupportedType, получено %v", err)
+	}
+}
+
+func TestLargeNumbers(t *testing.T) {
+	got, err := MergePatch([]byte(`{"Due":1893456000123456789}`), []byte(`{"Title":"a"}`))
+	if err != nil || !jsonEqual(t, got, `{"Due":1893456000123456789,"Title":"a"}`) || string(got) != `{"Due":1893456000123456789,"Title":"a"}` {
+		t.Errorf("большие числа должны сохраняться без потери точности: %s, %v", got, err)
+	}
+}
@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// handler - добавляет в запись функцию-источник и поля контекста и фильтрует записи
+// по уровню пакета, из которого они сделаны.
+type handler struct {
+	inner slog.Handler
+	// Уровень по умолчанию
+	level slog.Level
+	// Уровни пакетов
+	levels map[string]slog.Level
+	// Наименьший из уровней, записи ниже него не создаются
+	min slog.Level
+
+	// Уровни функций по адресу вызова, чтобы не разбирать имя функции на каждой записи
+	cache *sync.Map
+}
+
+func newHandler(inner slog.Handler, level slog.Level, levels map[string]slog.Level) *handler {
+	min := level
+	for _, l := range levels {
+		if l < min {
+			min = l
+		}
+	}
+	return &handler{inner: inner, level: level, levels: levels, min: min, cache: &sync.Map{}}
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.min
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	fn := funcName(r.PC)
+	if r.Level < h.levelOf(r.PC, fn) {
+		return nil
+	}
+	if fn != "" {
+		r.AddAttrs(slog.String("func", fn))
+	}
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithAttrs(attrs)
+	return &c
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithGroup(name)
+	return &c
+}
+
+// levelOf - уровень пакета функции fn
+func (h *handler) levelOf(pc uintptr, fn string) slog.Level {
+	if len(h.levels) == 0 || pc == 0 {
+		return h.level
+	}
+	if l, ok := h.cache.Load(pc); ok {
+		return l.(slog.Level)
+	}
+	level := h.level
+	path := packagePath(fn)
+	if l, ok := h.levels[path]; ok {
+		level = l
+	} else if l, ok := h.levels[path[strings.LastIndex(path, "/")+1:]]; ok {
+		level = l
+	}
+	h.cache.Store(pc, level)
+	return level
+}
+
+// funcName - полное имя функции по адресу вызова
+func funcName(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return frame.Function
+}
+
+// packagePath - путь пакета из полного имени функции:
+// TaskManager/pkg/storage.(*Storage).TaskById - TaskManager/pkg/storage
+func packagePath(fn string) string {
+	slash := strings.LastIndex(fn, "/") + 1
+	if dot := strings.Index(fn[slash:], "."); dot >= 0 {
+		return fn[:slash+dot]
+	}
+	return fn
+}
+
+//-------------------Контекст-------------------------
+
+type attrsKey struct{}
+
+// With - добавляет в контекст поля ключ-значение, которые попадут во все записи с этим контекстом:
+// ctx = logger.With(ctx, "request_id", id)
+func With(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	r := slog.NewRecord(time.Time{}, 0, "", 0)
+	r.Add(args...)
+	attrs := make([]slog.Attr, len(prev), len(prev)+r.NumAttrs())
+	copy(attrs, prev)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
@@ -1,55 +1,196 @@
+// Package logger - журнал сервиса на основе log/slog: уровни, поля ключ-значение,
+// текстовый и JSON формат, вывод в stdout или файл с ротацией, уровни для отдельных пакетов
+// и поля из контекста запроса.
 package logger
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
-var Console = true
+// Форматы журнала.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
 
-func getFuncName() string {
-	pc, _, _, ok := runtime.Caller(2)
-	details := runtime.FuncForPC(pc)
-	if ok && details != nil {
-		return details.Name()
-	}
-	return "undefined"
+// Выводы журнала, кроме них выводом может быть путь к файлу.
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// Config - настройки журнала.
+type Config struct {
+	// Минимальный уровень: debug, info, warn или error
+	Level string
+	// Формат: text или json
+	Format string
+	// stdout, stderr или путь к файлу
+	Output string
+	// Уровни отдельных пакетов, ключ - путь пакета или его последний элемент:
+	// "storage" или "TaskManager/pkg/storage"
+	Levels map[string]string
+	// Ротация файла журнала, не используется при выводе в stdout и stderr
+	Rotation Rotation
 }
 
-func New() error {
+// current - действующий обработчик журнала. До вызова Setup журнал пишется в stdout
+// в текстовом формате с уровнем info
+var current atomic.Pointer[slog.Logger]
+
+// closer - файл журнала предыдущего Setup
+var closer io.Closer
+
+func init() {
+	current.Store(slog.New(newHandler(slog.NewTextHandler(os.Stdout, handlerOptions(slog.LevelDebug)), slog.LevelInfo, nil)))
+}
+
+// Setup - применяет настройки журнала. Вызывается при запуске, до начала обработки запросов
+func Setup(config Config) error {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return err
+	}
+	levels := make(map[string]slog.Level, len(config.Levels))
+	for pkg, name := range config.Levels {
+		levels[pkg], err = ParseLevel(name)
+		if err != nil {
+			return fmt.Errorf("уровень пакета %s: %w", pkg, err)
+		}
+	}
+
+	var out io.Writer
+	var file io.Closer
+	switch config.Output {
+	case OutputStdout, "":
+		out = os.Stdout
+	case OutputStderr:
+		out = os.Stderr
+	default:
+		f, err := OpenRotating(config.Output, config.Rotation)
+		if err != nil {
+			return err
+		}
+		out, file = f, f
+	}
+
+	// Фильтрацию по уровню выполняет newHandler, внутренний обработчик пропускает все записи
+	opts := handlerOptions(slog.LevelDebug)
+	var inner slog.Handler
+	switch config.Format {
+	case FormatText, "":
+		inner = slog.NewTextHandler(out, opts)
+	case FormatJSON:
+		inner = slog.NewJSONHandler(out, opts)
+	default:
+		if file != nil {
+			file.Close()
+		}
+		return fmt.Errorf("неизвестный формат журнала: %s", config.Format)
+	}
+
+	current.Store(slog.New(newHandler(inner, level, levels)))
+	if closer != nil {
+		closer.Close()
+	}
+	closer = file
 	return nil
 }
 
-func Info(format string, a ...any) {
-	date := time.Now()
-	dateFormat := fmt.Sprintf("[%s]", date.Format("02.01.2006 15.01"))
-	funcName := fmt.Sprintf("%s ->", getFuncName())
-	str := fmt.Sprintf("%s %s info: %s", dateFormat, funcName, format)
-	if Console {
-		fmt.Printf(str, a...)
-		fmt.Println()
+// ParseLevel - уровень по названию, пустое название - info
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	err := level.UnmarshalText([]byte(name))
+	if err != nil {
+		return level, fmt.Errorf("неизвестный уровень журнала: %s", name)
 	}
+	return level, nil
 }
 
-func Error(format string, a ...any) {
-	date := time.Now()
-	dateFormat := fmt.Sprintf("[%s]", date.Format("02.01.2006 15.01"))
-	funcName := fmt.Sprintf("%s ->", getFuncName())
-	str := fmt.Sprintf("%s %s error: %s", dateFormat, funcName, format)
-	if Console {
-		fmt.Printf(str, a...)
-		fmt.Println()
+// ParseLevels - разбирает уровни пакетов вида "storage=debug,mailer=warn"
+func ParseLevels(s string) (map[string]string, error) {
+	levels := map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		pkg, level, ok := strings.Cut(item, "=")
+		if !ok || pkg == "" {
+			return nil, fmt.Errorf("ожидалось пакет=уровень: %q", item)
+		}
+		levels[strings.TrimSpace(pkg)] = strings.TrimSpace(level)
 	}
+	return levels, nil
+}
+
+func handlerOptions(level slog.Level) *slog.HandlerOptions {
+	return &slog.HandlerOptions{Level: level}
+}
+
+// Logger - журнал для записей с полями ключ-значение: logger.Logger().Info("sent", "to", addr)
+func Logger() *slog.Logger {
+	return current.Load()
+}
+
+//-------------------Записи-------------------------
+
+func Debug(format string, a ...any) {
+	log(context.Background(), slog.LevelDebug, format, a...)
+}
+
+func Info(format string, a ...any) {
+	log(context.Background(), slog.LevelInfo, format, a...)
 }
 
 func Warn(format string, a ...any) {
-	date := time.Now()
-	dateFormat := fmt.Sprintf("[%s]", date.Format("02.01.2006 15.01"))
-	funcName := fmt.Sprintf("%s ->", getFuncName())
-	str := fmt.Sprintf("%s %s warn: %s", dateFormat, funcName, format)
-	if Console {
-		fmt.Printf(str, a...)
-		fmt.Println()
+	log(context.Background(), slog.LevelWarn, format, a...)
+}
+
+func Error(format string, a ...any) {
+	log(context.Background(), slog.LevelError, format, a...)
+}
+
+// DebugContext - запись с полями из контекста запроса (request_id, trace_id)
+func DebugContext(ctx context.Context, format string, a ...any) {
+	log(ctx, slog.LevelDebug, format, a...)
+}
+
+// InfoContext - запись с полями из контекста запроса (request_id, trace_id)
+func InfoContext(ctx context.Context, format string, a ...any) {
+	log(ctx, slog.LevelInfo, format, a...)
+}
+
+// WarnContext - запись с полями из контекста запроса (request_id, trace_id)
+func WarnContext(ctx context.Context, format string, a ...any) {
+	log(ctx, slog.LevelWarn, format, a...)
+}
+
+// ErrorContext - запись с полями из контекста запроса (request_id, trace_id)
+func ErrorContext(ctx context.Context, format string, a ...any) {
+	log(ctx, slog.LevelError, format, a...)
+}
+
+// log - форматирует сообщение и пишет запись от имени вызвавшей функции
+func log(ctx context.Context, level slog.Level, format string, a ...any) {
+	l := current.Load()
+	if !l.Enabled(ctx, level) {
+		return
 	}
+	// Пропускаются runtime.Callers, log и экспортируемая функция пакета
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, a...), pcs[0])
+	_ = l.Handler().Handle(ctx, r)
 }
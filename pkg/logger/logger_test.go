@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capture - направляет журнал в буфер в формате JSON
+func capture(t *testing.T, level slog.Level, levels map[string]slog.Level) *bytes.Buffer {
+	t.Helper()
+	prev := current.Load()
+	t.Cleanup(func() { current.Store(prev) })
+	var buf bytes.Buffer
+	current.Store(slog.New(newHandler(slog.NewJSONHandler(&buf, handlerOptions(slog.LevelDebug)), level, levels)))
+	return &buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var result []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r map[string]any
+		err := json.Unmarshal([]byte(line), &r)
+		if err != nil {
+			t.Fatalf("%s: %s", line, err)
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+func TestLevels(t *testing.T) {
+	buf := capture(t, slog.LevelWarn, nil)
+	Debug("debug %d", 1)
+	Info("info %d", 2)
+	Warn("warn %d", 3)
+	Error("error %d", 4)
+
+	got := records(t, buf)
+	if len(got) != 2 || got[0]["msg"] != "warn 3" || got[1]["level"] != "ERROR" {
+		t.Fatalf("записи: %v", got)
+	}
+	if fn, _ := got[0]["func"].(string); !strings.HasSuffix(fn, "logger.TestLevels") {
+		t.Errorf("func = %q, ожидалась вызвавшая функция", fn)
+	}
+}
+
+func TestPackageLevels(t *testing.T) {
+	buf := capture(t, slog.LevelError, map[string]slog.Level{"logger": slog.LevelDebug})
+	Debug("включен уровнем пакета")
+	if got := records(t, buf); len(got) != 1 {
+		t.Fatalf("ожидалась запись отладки пакета logger: %v", got)
+	}
+
+	buf = capture(t, slog.LevelDebug, map[string]slog.Level{"TaskManager/pkg/logger": slog.LevelError})
+	Warn("отключен уровнем пакета")
+	if got := records(t, buf); len(got) != 0 {
+		t.Fatalf("ожидалось отсутствие записей: %v", got)
+	}
+}
+
+func TestContext(t *testing.T) {
+	buf := capture(t, slog.LevelInfo, nil)
+	ctx := With(context.Background(), "request_id", "abc")
+	ctx = With(ctx, "user_id", 7)
+	InfoContext(ctx, "запрос")
+	Logger().InfoContext(ctx, "поля", "task_id", 42)
+	Info("без контекста")
+
+	got := records(t, buf)
+	if len(got) != 3 {
+		t.Fatalf("записи: %v", got)
+	}
+	for _, r := range got[:2] {
+		if r["request_id"] != "abc" || r["user_id"] != float64(7) {
+			t.Errorf("нет полей контекста: %v", r)
+		}
+	}
+	if got[1]["task_id"] != float64(42) {
+		t.Errorf("нет поля task_id: %v", got[1])
+	}
+	if _, ok := got[2]["request_id"]; ok {
+		t.Errorf("поле контекста в записи без контекста: %v", got[2])
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels("storage=debug, mailer=warn")
+	if err != nil || levels["storage"] != "debug" || levels["mailer"] != "warn" {
+		t.Errorf("ParseLevels() = %v, %v", levels, err)
+	}
+	if _, err = ParseLevels("storage"); err == nil {
+		t.Error("ожидалась ошибка для записи без уровня")
+	}
+	if _, err = ParseLevel("verbose"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного уровня")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tm.log")
+	now := time.Date(2026, 1, 19, 10, 0, 0, 0, time.UTC)
+
+	f, err := OpenRotating(path, Rotation{MaxSize: 10, Interval: 24 * time.Hour, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.now = func() time.Time { return now }
+	f.period = f.periodOf(now)
+
+	write := func(s string) {
+		t.Helper()
+		_, err := f.Write([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("12345\n")
+	write("12345\n") // превышение размера
+	now = now.Add(time.Minute)
+	write("1\n")
+	now = now.Add(24 * time.Hour) // новые сутки
+	write("2\n")
+	now = now.Add(time.Minute)
+	write("123456789\n") // превышение размера, самый старый файл удаляется
+
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Errorf("старых файлов %d, ожидалось 2", len(backups))
+	}
+	b, err := os.ReadFile(path)
+	if err != nil || string(b) != "123456789\n" {
+		t.Errorf("текущий файл %q, %v", b, err)
+	}
+}
@@ -0,0 +1,194 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rotation - правила ротации файла журнала, нулевые значения отключают правило.
+type Rotation struct {
+	// Размер файла в байтах, после которого начинается новый файл
+	MaxSize int64
+	// Период, после которого начинается новый файл, отсчитывается от полуночи UTC:
+	// 24h - новый файл каждые сутки
+	Interval time.Duration
+	// Сколько старых файлов хранить
+	MaxBackups int
+	// Сколько хранить старые файлы
+	MaxAge time.Duration
+}
+
+// Формат времени в имени старого файла: tm.log - tm-20260119T150405.000.log.
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile - файл журнала, который при превышении размера или по истечении периода
+// переименовывается с отметкой времени, после чего запись продолжается в новый файл.
+type RotatingFile struct {
+	path     string
+	rotation Rotation
+
+	mu sync.Mutex
+	f  *os.File
+	// Размер текущего файла
+	size int64
+	// Начало периода текущего файла
+	period time.Time
+	now    func() time.Time
+}
+
+// OpenRotating - открывает файл журнала для дозаписи, создавая каталог при необходимости
+func OpenRotating(path string, rotation Rotation) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, rotation: rotation, now: time.Now}
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, err
+	}
+	err = r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open - открывает текущий файл, период файла считается по времени его изменения
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	r.period = r.periodOf(info.ModTime())
+	if r.size == 0 {
+		r.period = r.periodOf(r.now())
+	}
+	return nil
+}
+
+// periodOf - начало периода, в который попадает t
+func (r *RotatingFile) periodOf(t time.Time) time.Time {
+	if r.rotation.Interval <= 0 {
+		return time.Time{}
+	}
+	return t.UTC().Truncate(r.rotation.Interval)
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	now := r.now()
+	full := r.rotation.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.rotation.MaxSize
+	expired := r.rotation.Interval > 0 && r.periodOf(now).After(r.period)
+	if full || expired {
+		err := r.rotate(now)
+		if err != nil {
+			// Запись продолжается в прежний файл, чтобы не терять журнал
+			fmt.Fprintf(os.Stderr, "logger: rotate %s: %s\n", r.path, err)
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate - переименовывает текущий файл, открывает новый и удаляет лишние старые файлы
+func (r *RotatingFile) rotate(now time.Time) error {
+	err := r.f.Close()
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(r.path)
+	backup := strings.TrimSuffix(r.path, ext) + "-" + now.UTC().Format(backupTimeFormat) + ext
+	renameErr := os.Rename(r.path, backup)
+
+	err = r.open()
+	if err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+	r.size = 0
+	r.period = r.periodOf(now)
+	return r.cleanup(now)
+}
+
+// cleanup - удаляет старые файлы сверх MaxBackups и старше MaxAge
+func (r *RotatingFile) cleanup(now time.Time) error {
+	if r.rotation.MaxBackups <= 0 && r.rotation.MaxAge <= 0 {
+		return nil
+	}
+	backups, err := r.backups()
+	if err != nil {
+		return err
+	}
+	// Сначала новые
+	sort.Slice(backups, func(i, j int) bool { return backups[i].t.After(backups[j].t) })
+	for i, b := range backups {
+		tooMany := r.rotation.MaxBackups > 0 && i >= r.rotation.MaxBackups
+		tooOld := r.rotation.MaxAge > 0 && now.Sub(b.t) > r.rotation.MaxAge
+		if tooMany || tooOld {
+			err = os.Remove(b.path)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type backupFile struct {
+	path string
+	t    time.Time
+}
+
+// backups - старые файлы журнала с временем ротации из имени
+func (r *RotatingFile) backups() ([]backupFile, error) {
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(filepath.Base(r.path), ext) + "-"
+	dir := filepath.Dir(r.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []backupFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{filepath.Join(dir, name), t})
+	}
+	return backups, nil
+}
+
+// Close - закрывает файл журнала
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,266 @@
+package mailer
+
+import (
+	"TaskManager/pkg/logger"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Способы отправки писем.
+const (
+	SinkSMTP = "smtp"
+	SinkFile = "file"
+	SinkLog  = "log"
+)
+
+// Режимы шифрования SMTP соединения.
+const (
+	TLSNone     = "none"
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+)
+
+// Message - письмо.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer - способ отправки писем.
+type Mailer interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+// Config - настройки почты.
+type Config struct {
+	// Способ отправки: smtp, file или log
+	Sink string
+	// Адрес отправителя
+	From string
+	// Адрес SMTP сервера host:port
+	Addr     string
+	Username string
+	Password string
+	// Шифрование: none, starttls или tls
+	TLS string
+	// Каталог для писем при Sink = file
+	Dir string
+}
+
+// New - создает способ отправки писем по настройкам.
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Sink {
+	case SinkSMTP:
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный адрес SMTP сервера: %w", err)
+		}
+		switch cfg.TLS {
+		case TLSNone, TLSStartTLS, TLSImplicit:
+		default:
+			return nil, fmt.Errorf("неизвестный режим шифрования SMTP: %s", cfg.TLS)
+		}
+		return &SMTPMailer{
+			Addr:     cfg.Addr,
+			Host:     host,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			TLS:      cfg.TLS,
+		}, nil
+	case SinkFile:
+		err := os.MkdirAll(cfg.Dir, 0o755)
+		if err != nil {
+			return nil, err
+		}
+		return &FileMailer{Dir: cfg.Dir}, nil
+	case SinkLog, "":
+		return &LogMailer{}, nil
+	}
+	return nil, fmt.Errorf("неизвестный способ отправки писем: %s", cfg.Sink)
+}
+
+// SMTPMailer - отправка писем через SMTP сервер.
+type SMTPMailer struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	TLS      string
+	// Настройки TLS, по умолчанию проверяется сертификат Host
+	TLSConfig *tls.Config
+}
+
+// Send - отправляет письмо, соединение с сервером открывается на каждое письмо.
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	tlsConfig := m.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: m.Host}
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if m.TLS == TLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if m.TLS == TLSStartTLS {
+		err = c.StartTLS(tlsConfig)
+		if err != nil {
+			return err
+		}
+	}
+	if m.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(msg.From)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(msg.To)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	data, err := Build(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// FileMailer - сохраняет письма в каталог в формате .eml, для разработки.
+type FileMailer struct {
+	Dir string
+	n   atomic.Int64
+}
+
+// Send - сохраняет письмо в файл.
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	data, err := Build(msg)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%d.eml", time.Now().Format("20060102-150405"), m.n.Add(1))
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o644)
+}
+
+// LogMailer - пишет письма в лог, для разработки.
+type LogMailer struct{}
+
+// Send - пишет текстовую версию письма в лог.
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	logger.InfoContext(ctx, "Письмо для %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+// Build - формирует письмо в формате RFC 5322 с текстовой и HTML версиями.
+func Build(msg *Message) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if i := strings.LastIndex(msg.From, "@"); i >= 0 {
+		domain = strings.Trim(msg.From[i+1:], "> ")
+	}
+
+	headers := []string{
+		"From: " + msg.From,
+		"To: " + msg.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: <" + hex.EncodeToString(id) + "@" + domain + ">",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	}
+	for _, p := range parts {
+		if p.body == "" {
+			continue
+		}
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		_, err = qw.Write([]byte(p.body))
+		if err != nil {
+			return nil, err
+		}
+		err = qw.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
@@ -0,0 +1,157 @@
+package mailer
+
+import (
+	"TaskManager/pkg/storage"
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStandIn - минимальный SMTP сервер для тестов: принимает одно письмо без шифрования
+// и возвращает его содержимое в канал.
+func smtpStandIn(t *testing.T) (string, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				received <- data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	return l.Addr().String(), received
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	addr, received := smtpStandIn(t)
+
+	m, err := New(Config{Sink: SinkSMTP, Addr: addr, TLS: TLSNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err = m.Send(ctx, &Message{
+		From:    "taskmanager@example.com",
+		To:      "user@example.com",
+		Subject: "Проверка",
+		Text:    "Текст письма",
+		HTML:    "<p>Текст письма</p>",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	select {
+	case data := <-received:
+		msg, err := mail.ReadMessage(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("письмо не разбирается: %v", err)
+		}
+		subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		if err != nil || subject != "Проверка" {
+			t.Errorf("Subject = %q, want %q", subject, "Проверка")
+		}
+		if msg.Header.Get("To") != "user@example.com" {
+			t.Errorf("To = %q", msg.Header.Get("To"))
+		}
+		if !strings.HasPrefix(msg.Header.Get("Content-Type"), "multipart/alternative") {
+			t.Errorf("Content-Type = %q", msg.Header.Get("Content-Type"))
+		}
+	case <-ctx.Done():
+		t.Fatal("письмо не получено")
+	}
+}
+
+func TestTemplates_Render(t *testing.T) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := &TemplateData{
+		User:    storage.User{Name: "Иван"},
+		Task:    storage.Task{ID: 42, Title: "<Починить>", Due: 1700000000},
+		Tasks:   []storage.Task{{ID: 42, Title: "Починить"}},
+		BaseURL: "http://tm.local/",
+	}
+	tests := []struct {
+		name    string
+		kind    string
+		locale  string
+		subject string
+	}{
+		{name: "Назначение ru", kind: KindAssigned, locale: "ru", subject: "Вам назначена задача #42: <Починить>"},
+		{name: "Назначение en", kind: KindAssigned, locale: "en", subject: "Task #42 assigned to you: <Починить>"},
+		{name: "Неизвестный язык", kind: KindAssigned, locale: "de", subject: "Вам назначена задача #42: <Починить>"},
+		{name: "Напоминание", kind: KindReminder, locale: "en", subject: "Task #42 is due 14.11.2023 22:13"},
+		{name: "Сводка", kind: KindDigest, locale: "ru", subject: "Ваши открытые задачи: 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := templates.Render(tt.kind, tt.locale, data)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("Subject = %q, want %q", msg.Subject, tt.subject)
+			}
+			if !strings.Contains(msg.Text, "http://tm.local/gettask?id=42") {
+				t.Errorf("Text без ссылки на задачу: %q", msg.Text)
+			}
+			if strings.Contains(msg.HTML, "<Починить>") {
+				t.Errorf("HTML не экранирован: %q", msg.HTML)
+			}
+		})
+	}
+}
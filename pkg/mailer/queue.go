@@ -0,0 +1,138 @@
+package mailer
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"context"
+	"time"
+)
+
+// Параметры очереди отправки.
+const (
+	// Максимальное число попыток отправки письма
+	MaxAttempts = 8
+	// Интервал опроса очереди
+	pollInterval = time.Second * 10
+	// Сколько писем берется за один опрос
+	batchSize = 20
+	// На сколько откладывается повторная выдача взятого в работу письма
+	claimLease = time.Minute * 5
+	// Таймаут отправки одного письма
+	sendTimeout = time.Second * 30
+)
+
+// Queue - очередь отправки писем, хранимая в БД. Письма ставятся в очередь сразу,
+// а отправляются фоновым обработчиком с повторами при ошибках.
+type Queue struct {
+	storage   *storage.Storage
+	mailer    Mailer
+	templates *Templates
+	from      string
+	baseURL   string
+}
+
+// NewQueue - конструктор очереди, from - адрес отправителя, baseURL - адрес сервиса для ссылок в письмах.
+func NewQueue(storage *storage.Storage, mailer Mailer, from, baseURL string) (*Queue, error) {
+	templates, err := LoadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{
+		storage:   storage,
+		mailer:    mailer,
+		templates: templates,
+		from:      from,
+		baseURL:   baseURL,
+	}, nil
+}
+
+// Enqueue - формирует письмо вида kind для пользователя и ставит в очередь.
+// Пользователи без адреса, отказавшиеся от писем и деактивированные пропускаются.
+// Письмо с уже поставленным dedupeKey повторно не ставится.
+func (q *Queue) Enqueue(ctx context.Context, user *storage.User, kind, dedupeKey string, data *TemplateData) error {
+	if user.Email == "" || user.EmailOptOut || user.Deactivated != 0 {
+		return nil
+	}
+
+	data.User = *user
+	data.BaseURL = q.baseURL
+	msg, err := q.templates.Render(kind, user.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.storage.EnqueueMail(ctx, &storage.MailMessage{
+		UserID:    user.ID,
+		To:        user.Email,
+		DedupeKey: dedupeKey,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+		HTML:      msg.HTML,
+	})
+	return err
+}
+
+// Run - отправляет письма из очереди до отмены ctx.
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.sendBatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendBatch - отправляет очередную порцию писем
+func (q *Queue) sendBatch(ctx context.Context) {
+	messages, err := q.storage.ClaimMail(ctx, batchSize, claimLease)
+	if err != nil {
+		logger.ErrorContext(ctx, "Ошибка при получении писем из очереди: %s", err.Error())
+		return
+	}
+
+	for _, m := range messages {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err = q.mailer.Send(sendCtx, &Message{
+			From:    q.from,
+			To:      m.To,
+			Subject: m.Subject,
+			Text:    m.Text,
+			HTML:    m.HTML,
+		})
+		cancel()
+
+		if err == nil {
+			err = q.storage.MarkMailSent(ctx, m.ID)
+			if err != nil {
+				logger.ErrorContext(ctx, "Ошибка при отметке письма %d отправленным: %s", m.ID, err.Error())
+			}
+			continue
+		}
+
+		attempts := m.Attempts + 1
+		final := attempts >= MaxAttempts
+		logger.WarnContext(ctx, "Ошибка при отправке письма %d (попытка %d): %s", m.ID, attempts, err.Error())
+		err = q.storage.MarkMailError(ctx, m.ID, err.Error(), time.Now().Add(Backoff(attempts)).Unix(), final)
+		if err != nil {
+			logger.ErrorContext(ctx, "Ошибка при сохранении ошибки письма %d: %s", m.ID, err.Error())
+		}
+	}
+}
+
+// Backoff - пауза перед следующей попыткой: 1, 2, 4... минут, но не больше 6 часов.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	d := time.Minute << (attempts - 1)
+	if attempts > 10 || d > time.Hour*6 {
+		return time.Hour * 6
+	}
+	return d
+}
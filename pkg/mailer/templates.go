@@ -0,0 +1,141 @@
+package mailer
+
+import (
+	"TaskManager/pkg/storage"
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Виды писем.
+const (
+	KindAssigned = "assigned"
+	KindReminder = "reminder"
+	KindDigest   = "digest"
+)
+
+// Язык писем по умолчанию.
+const DefaultLocale = "ru"
+
+//go:embed templates/*.tmpl
+var templatesFS embed.FS
+
+// TemplateData - данные для шаблонов писем.
+type TemplateData struct {
+	User    storage.User
+	Task    storage.Task
+	Tasks   []storage.Task
+	BaseURL string
+	// Часовой пояс для дат в письме, по умолчанию UTC
+	Location *time.Location
+}
+
+// Templates - шаблоны писем, каждый файл templates/<вид>.<язык>.tmpl
+// определяет блоки subject, text и html.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates - загружает встроенные шаблоны писем.
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{
+		text: map[string]*texttemplate.Template{},
+		html: map[string]*htmltemplate.Template{},
+	}
+
+	entries, err := templatesFS.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".tmpl")
+		data, err := templatesFS.ReadFile("templates/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		// Функции подставляются при отрисовке, здесь только заглушки для разбора
+		tt, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(funcs(nil))).Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("шаблон %s: %w", e.Name(), err)
+		}
+		ht, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(funcs(nil))).Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("шаблон %s: %w", e.Name(), err)
+		}
+		t.text[name] = tt
+		t.html[name] = ht
+	}
+
+	return t, nil
+}
+
+// funcs - функции шаблонов
+func funcs(data *TemplateData) map[string]any {
+	loc := time.UTC
+	baseURL := ""
+	if data != nil {
+		if data.Location != nil {
+			loc = data.Location
+		}
+		baseURL = strings.TrimSuffix(data.BaseURL, "/")
+	}
+	return map[string]any{
+		"date": func(unix int64) string {
+			return time.Unix(unix, 0).In(loc).Format("02.01.2006 15:04")
+		},
+		"taskURL": func(id int) string {
+			return fmt.Sprintf("%s/gettask?id=%d", baseURL, id)
+		},
+	}
+}
+
+// Render - формирует письмо вида kind на языке locale. При отсутствии шаблона
+// на нужном языке используется DefaultLocale.
+func (t *Templates) Render(kind, locale string, data *TemplateData) (*Message, error) {
+	name := kind + "." + locale
+	if _, ok := t.text[name]; !ok {
+		name = kind + "." + DefaultLocale
+	}
+	tt, ok := t.text[name]
+	if !ok {
+		return nil, fmt.Errorf("шаблон письма %s не найден", kind)
+	}
+	ht := t.html[name]
+
+	tt, err := tt.Clone()
+	if err != nil {
+		return nil, err
+	}
+	ht, err = ht.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tt.Funcs(texttemplate.FuncMap(funcs(data)))
+	ht.Funcs(htmltemplate.FuncMap(funcs(data)))
+
+	var subject, text, html bytes.Buffer
+	err = tt.ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+	err = tt.ExecuteTemplate(&text, "text", data)
+	if err != nil {
+		return nil, err
+	}
+	err = ht.ExecuteTemplate(&html, "html", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    strings.TrimSpace(html.String()) + "\n",
+	}, nil
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"TaskManager/pkg/logger"
+	"context"
+	"strconv"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//-------------------Пул соединений-------------------------
+
+// poolCollector - статистика пула соединений pgxpool, снимается при каждом опросе.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquired     *prometheus.Desc
+	idle         *prometheus.Desc
+	constructing *prometheus.Desc
+	total        *prometheus.Desc
+	max          *prometheus.Desc
+	acquires     *prometheus.Desc
+	emptyAcquire *prometheus.Desc
+	canceled     *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+// RegisterPool - регистрирует метрики пула соединений с БД
+func RegisterPool(pool *pgxpool.Pool) error {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return Registry.Register(&poolCollector{
+		pool:         pool,
+		acquired:     desc("acquired_connections", "Connections currently acquired from the pool."),
+		idle:         desc("idle_connections", "Idle connections in the pool."),
+		constructing: desc("constructing_connections", "Connections being established."),
+		total:        desc("total_connections", "Total connections in the pool."),
+		max:          desc("max_connections", "Maximum size of the pool."),
+		acquires:     desc("acquires_total", "Successful acquires from the pool."),
+		emptyAcquire: desc("empty_acquires_total", "Acquires that had to wait for a connection."),
+		canceled:     desc("canceled_acquires_total", "Acquires canceled by their context."),
+		waitDuration: desc("acquire_wait_seconds_total", "Total time spent acquiring connections."),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	gauge := func(d *prometheus.Desc, v int32) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(v))
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge(c.acquired, s.AcquiredConns())
+	gauge(c.idle, s.IdleConns())
+	gauge(c.constructing, s.ConstructingConns())
+	gauge(c.total, s.TotalConns())
+	gauge(c.max, s.MaxConns())
+	counter(c.acquires, float64(s.AcquireCount()))
+	counter(c.emptyAcquire, float64(s.EmptyAcquireCount()))
+	counter(c.canceled, float64(s.CanceledAcquireCount()))
+	counter(c.waitDuration, s.AcquireDuration().Seconds())
+}
+
+//-------------------Открытые задачи-------------------------
+
+// TaskStats - источник бизнес-показателей задач, реализуется хранилищем.
+type TaskStats interface {
+	// OpenTaskCounts - число открытых задач по ID исполнителя
+	OpenTaskCounts(ctx context.Context) (map[int]int, error)
+}
+
+// tasksCollector - число открытых задач по исполнителям, запрашивается из БД при каждом опросе.
+type tasksCollector struct {
+	stats TaskStats
+	open  *prometheus.Desc
+}
+
+// RegisterTaskStats - регистрирует метрики открытых задач
+func RegisterTaskStats(stats TaskStats) error {
+	return Registry.Register(&tasksCollector{
+		stats: stats,
+		open: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "open_tasks"),
+			"Open tasks by assignee.", []string{"assignee_id"}, nil),
+	})
+}
+
+func (c *tasksCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.open
+}
+
+func (c *tasksCollector) Collect(ch chan<- prometheus.Metric) {
+	open, err := c.stats.OpenTaskCounts(context.Background())
+	if err != nil {
+		logger.Error("Ошибка при подсчете открытых задач: %s", err.Error())
+		ch <- prometheus.NewInvalidMetric(c.open, err)
+		return
+	}
+	for assignee, n := range open {
+		ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(n), strconv.Itoa(assignee))
+	}
+}
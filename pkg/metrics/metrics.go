@@ -0,0 +1,150 @@
+// Package metrics - метрики сервиса в формате Prometheus: HTTP запросы, пул соединений,
+// операции хранилища и бизнес-показатели задач.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Префикс имен метрик.
+const namespace = "taskmanager"
+
+// Registry - реестр метрик сервиса, отдается эндпоинтом /metrics.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler - эндпоинт /metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+//-------------------Хранилище-------------------------
+
+var (
+	storageDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of storage operations by method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"method"})
+
+	storageErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "operation_errors_total",
+		Help:      "Failed storage operations by method.",
+	}, []string{"method"})
+)
+
+// ObserveStorage - учитывает операцию хранилища: длительность и ошибку, nil - успешная операция.
+func ObserveStorage(method string, d time.Duration, err error) {
+	storageDuration.WithLabelValues(method).Observe(d.Seconds())
+	if err != nil {
+		storageErrors.WithLabelValues(method).Inc()
+	}
+}
+
+var (
+	cacheHits = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "Storage reads served from the cache by kind of cached value.",
+	}, []string{"kind"})
+
+	cacheMisses = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "Storage reads not found in the cache by kind of cached value.",
+	}, []string{"kind"})
+)
+
+// ObserveCache - учитывает чтение из кэша хранилища: kind - вид значения (labels, label, user...)
+func ObserveCache(kind string, hit bool) {
+	if hit {
+		cacheHits.WithLabelValues(kind).Inc()
+	} else {
+		cacheMisses.WithLabelValues(kind).Inc()
+	}
+}
+
+//-------------------Задачи-------------------------
+
+var (
+	tasksCreated = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_created_total",
+		Help:      "Tasks created, counted from the event stream of all instances.",
+	})
+
+	tasksClosed = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_closed_total",
+		Help:      "Tasks closed, counted from the event stream of all instances.",
+	})
+)
+
+// TaskEvent - изменение задачи: тип события и время закрытия задачи до и после него.
+type TaskEvent struct {
+	Created bool
+	// Время закрытия до изменения, 0 - задача была открыта
+	ClosedBefore int64
+	// Время закрытия после изменения, 0 - задача открыта
+	ClosedAfter int64
+}
+
+// ObserveTaskEvent - учитывает событие задачи в счетчиках созданных и закрытых задач.
+// События приходят каждому экземпляру сервиса от всех экземпляров, поэтому счетчики
+// показывают общие значения, и при агрегации по экземплярам нужно брать max, а не sum.
+func ObserveTaskEvent(e TaskEvent) {
+	if e.Created {
+		tasksCreated.Inc()
+	}
+	if e.ClosedAfter != 0 && (e.Created || e.ClosedBefore == 0) {
+		tasksClosed.Inc()
+	}
+}
+
+//-------------------Ограничение запросов-------------------------
+
+var rateLimited = factory.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "http",
+	Name:      "rate_limited_total",
+	Help:      "Requests rejected by rate limiting by request class and bucket scope.",
+}, []string{"class", "scope"})
+
+// ObserveRateLimited - учитывает запрос, отклоненный ограничением частоты запросов.
+func ObserveRateLimited(class, scope string) {
+	rateLimited.WithLabelValues(class, scope).Inc()
+}
+
+//-------------------Ключи идемпотентности-------------------------
+
+var idempotentRequests = factory.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "http",
+	Name:      "idempotent_requests_total",
+	Help:      "Repeated requests with an Idempotency-Key by outcome: replayed, in_progress or mismatch.",
+}, []string{"outcome"})
+
+// ObserveIdempotency - учитывает повтор запроса с ключом идемпотентности.
+func ObserveIdempotency(outcome string) {
+	idempotentRequests.WithLabelValues(outcome).Inc()
+}
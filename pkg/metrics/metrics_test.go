@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/gettask", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Queries("id", "{id}")
+	r.PathPrefix("/docs/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	r.Use(Middleware)
+
+	for _, path := range []string{"/gettask?id=1", "/gettask?id=2", "/docs/index.html", "/docs/swagger.css"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	tests := []struct {
+		route, status string
+		want          float64
+	}{
+		{"/gettask", "204", 2},
+		{"/docs/", "200", 2},
+	}
+	for _, tt := range tests {
+		got := testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, tt.route, tt.status))
+		if got != tt.want {
+			t.Errorf("requests_total{route=%q,status=%q} = %v, want %v", tt.route, tt.status, got, tt.want)
+		}
+	}
+	if n := testutil.CollectAndCount(httpRequests); n != len(tests) {
+		t.Errorf("requests_total has %d series, want %d", n, len(tests))
+	}
+}
+
+func TestObserveStorage(t *testing.T) {
+	ObserveStorage("TaskById", time.Millisecond, nil)
+	ObserveStorage("TaskById", time.Millisecond, errors.New("connection refused"))
+	ObserveStorage("NewTask", time.Millisecond, nil)
+
+	if got := testutil.ToFloat64(storageErrors.WithLabelValues("TaskById")); got != 1 {
+		t.Errorf("TaskById errors = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(storageErrors.WithLabelValues("NewTask")); got != 0 {
+		t.Errorf("NewTask errors = %v, want 0", got)
+	}
+	if n := testutil.CollectAndCount(storageDuration); n != 2 {
+		t.Errorf("operation_duration_seconds has %d series, want 2", n)
+	}
+}
+
+func TestObserveTaskEvent(t *testing.T) {
+	created, closed := testutil.ToFloat64(tasksCreated), testutil.ToFloat64(tasksClosed)
+	events := []TaskEvent{
+		{Created: true},
+		{Created: true, ClosedAfter: 100},
+		// Закрытие открытой задачи
+		{ClosedAfter: 200},
+		// Изменение закрытой задачи
+		{ClosedBefore: 200, ClosedAfter: 200},
+		// Повторное открытие
+		{ClosedBefore: 200},
+	}
+	for _, e := range events {
+		ObserveTaskEvent(e)
+	}
+	if got := testutil.ToFloat64(tasksCreated) - created; got != 2 {
+		t.Errorf("tasks created = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(tasksClosed) - closed; got != 2 {
+		t.Errorf("tasks closed = %v, want 2", got)
+	}
+}
+
+type stats map[int]int
+
+func (s stats) OpenTaskCounts(ctx context.Context) (map[int]int, error) {
+	return s, nil
+}
+
+func TestTaskStats(t *testing.T) {
+	err := RegisterTaskStats(stats{1: 3, 7: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := testutil.GatherAndCount(Registry, "taskmanager_open_tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("open_tasks has %d series, want 2", n)
+	}
+}
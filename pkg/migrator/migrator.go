@@ -3,26 +3,40 @@ package migrator
 import (
 	"TaskManager/pkg/storage"
 	"context"
+	"fmt"
 )
 
+// SchemaVersion - версия схемы БД, которую создает Migration и с которой работает сервис.
+// При изменении схемы ее нужно увеличить.
+const SchemaVersion = 8
+
 func Migration(storage *storage.Storage) error {
 	ctx := context.Background()
 	tx, err := storage.DB.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	//1ая строка запроса для тестов: DROP TABLE IF EXISTS tasks_labels, tasks, labels, users;
+	//1ая строка запроса для тестов: DROP TABLE IF EXISTS schema_version, changes, idempotency_keys, rate_limits, mail_queue, notification_prefs, notifications, events, tasks_labels, tasks, labels, users;
 	_, err = tx.Exec(context.Background(), `
-		DROP TABLE IF EXISTS tasks_labels, tasks, labels, users;
+		DROP TABLE IF EXISTS schema_version, changes, idempotency_keys, rate_limits, mail_queue, notification_prefs, notifications, events, tasks_labels, tasks, labels, users;
 
 		CREATE TABLE IF NOT EXISTS users (
     	id SERIAL PRIMARY KEY,
-    	name TEXT NOT NULL
+    	name TEXT NOT NULL,
+    	email TEXT NOT NULL DEFAULT '',
+    	locale TEXT NOT NULL DEFAULT 'ru',
+    	email_opt_out BOOLEAN NOT NULL DEFAULT FALSE,
+    	calendar_token TEXT NOT NULL DEFAULT '',
+    	deactivated BIGINT NOT NULL DEFAULT 0
 		);
 
 		CREATE TABLE IF NOT EXISTS labels (
     		id SERIAL PRIMARY KEY,
-    		name TEXT NOT NULL
+    		scope TEXT NOT NULL DEFAULT '',
+    		name TEXT NOT NULL,
+    		color TEXT NOT NULL DEFAULT '',
+    		description TEXT NOT NULL DEFAULT '',
+    		UNIQUE (scope, name)
 		);
 
 		CREATE TABLE IF NOT EXISTS tasks (
@@ -30,9 +44,11 @@ func Migration(storage *storage.Storage) error {
     		opened BIGINT NOT NULL DEFAULT extract(epoch from now()),
     		closed BIGINT DEFAULT 0,
     		author_id INTEGER REFERENCES users(id) DEFAULT 1,
-    		assigned_id INTEGER REFERENCES users(id) DEFAULT 1,
+    		assigned_id INTEGER REFERENCES users(id),
     		title TEXT,
-    		content TEXT
+    		content TEXT,
+    		due BIGINT NOT NULL DEFAULT 0,
+    		priority INTEGER NOT NULL DEFAULT 0 CHECK (priority BETWEEN 0 AND 3)
 		);
 
 		CREATE TABLE IF NOT EXISTS tasks_labels (
@@ -40,6 +56,83 @@ func Migration(storage *storage.Storage) error {
     		label_id INTEGER REFERENCES labels(id)
 		);
 
+		CREATE TABLE IF NOT EXISTS events (
+    		id BIGSERIAL PRIMARY KEY,
+    		type TEXT NOT NULL,
+    		task_id INTEGER NOT NULL,
+    		created BIGINT NOT NULL DEFAULT extract(epoch from now()),
+    		payload JSONB NOT NULL,
+    		pruned INTEGER NOT NULL DEFAULT 0
+		);
+
+		CREATE INDEX IF NOT EXISTS events_task_id ON events (task_id);
+
+		CREATE TABLE IF NOT EXISTS notifications (
+    		id SERIAL PRIMARY KEY,
+    		user_id INTEGER NOT NULL REFERENCES users(id),
+    		event_id BIGINT NOT NULL,
+    		type TEXT NOT NULL,
+    		task_id INTEGER NOT NULL,
+    		task_title TEXT NOT NULL DEFAULT '',
+    		created BIGINT NOT NULL DEFAULT extract(epoch from now()),
+    		read BOOLEAN NOT NULL DEFAULT FALSE,
+    		UNIQUE (user_id, event_id, type)
+		);
+
+		CREATE TABLE IF NOT EXISTS notification_prefs (
+    		user_id INTEGER NOT NULL REFERENCES users(id),
+    		type TEXT NOT NULL,
+    		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    		PRIMARY KEY (user_id, type)
+		);
+
+		CREATE TABLE IF NOT EXISTS mail_queue (
+    		id SERIAL PRIMARY KEY,
+    		user_id INTEGER NOT NULL REFERENCES users(id),
+    		to_addr TEXT NOT NULL,
+    		dedupe_key TEXT NOT NULL UNIQUE,
+    		subject TEXT NOT NULL,
+    		text_body TEXT NOT NULL,
+    		html_body TEXT NOT NULL,
+    		attempts INTEGER NOT NULL DEFAULT 0,
+    		next_attempt BIGINT NOT NULL DEFAULT extract(epoch from now()),
+    		last_error TEXT NOT NULL DEFAULT '',
+    		sent BIGINT NOT NULL DEFAULT 0,
+    		failed BOOLEAN NOT NULL DEFAULT FALSE,
+    		created BIGINT NOT NULL DEFAULT extract(epoch from now())
+		);
+
+		CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+    		key TEXT PRIMARY KEY,
+    		tokens DOUBLE PRECISION NOT NULL,
+    		updated DOUBLE PRECISION NOT NULL,
+    		allowed BOOLEAN NOT NULL DEFAULT TRUE
+		);
+
+		CREATE TABLE IF NOT EXISTS changes (
+    		id BIGSERIAL PRIMARY KEY,
+    		entity TEXT NOT NULL,
+    		entity_id INTEGER NOT NULL,
+    		created BIGINT NOT NULL DEFAULT extract(epoch from now()),
+    		before JSONB NOT NULL,
+    		after JSONB NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+    		key TEXT PRIMARY KEY,
+    		claim TEXT NOT NULL DEFAULT '',
+    		fingerprint TEXT NOT NULL,
+    		status INTEGER NOT NULL DEFAULT 0,
+    		header JSONB,
+    		body BYTEA,
+    		expires BIGINT NOT NULL,
+    		locked_until BIGINT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS schema_version (
+    		version INTEGER NOT NULL
+		);
+
 		INSERT INTO users (name) VALUES ('default');
 	`)
 
@@ -49,9 +142,41 @@ func Migration(storage *storage.Storage) error {
 		return err
 	}
 
+	_, err = tx.Exec(ctx, "INSERT INTO schema_version (version) VALUES ($1);", SchemaVersion)
+	if err != nil {
+		return err
+	}
+
 	err = tx.Commit(ctx)
 	if err != nil {
 		return err
 	}
+	storage.InvalidateCache(ctx)
 	return nil
 }
+
+// Version - версия схемы в БД, 0 - миграции не выполнялись
+func Version(ctx context.Context, storage *storage.Storage) (int, error) {
+	var exists bool
+	err := storage.DB.QueryRow(ctx, "SELECT to_regclass('schema_version') IS NOT NULL;").Scan(&exists)
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	err = storage.DB.QueryRow(ctx, "SELECT COALESCE(max(version), 0) FROM schema_version;").Scan(&version)
+	return version, err
+}
+
+// CheckVersion - проверка готовности: версия схемы в БД совпадает с SchemaVersion
+func CheckVersion(storage *storage.Storage) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		version, err := Version(ctx, storage)
+		if err != nil {
+			return err
+		}
+		if version != SchemaVersion {
+			return fmt.Errorf("версия схемы БД %d, ожидается %d", version, SchemaVersion)
+		}
+		return nil
+	}
+}
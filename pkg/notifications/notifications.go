@@ -0,0 +1,199 @@
+package notifications
+
+import (
+	"TaskManager/pkg/events"
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/mailer"
+	"TaskManager/pkg/storage"
+	"context"
+	"fmt"
+	"time"
+)
+
+// Service - формирует уведомления пользователей из событий задач.
+type Service struct {
+	storage *storage.Storage
+	events  *events.Hub
+	mail    *mailer.Queue
+}
+
+// Конструктор, принимает хранилище, источник событий и очередь писем (nil - без писем).
+func New(storage *storage.Storage, events *events.Hub, mail *mailer.Queue) *Service {
+	return &Service{storage: storage, events: events, mail: mail}
+}
+
+// Recipients - возвращает уведомления, которые нужно создать по событию:
+// исполнителю при назначении задачи и автору при ее закрытии.
+func Recipients(e *storage.Event) []storage.Notification {
+	var result []storage.Notification
+	add := func(userID int, notificationType string) {
+		if userID == 0 {
+			return
+		}
+		result = append(result, storage.Notification{
+			UserID:    userID,
+			EventID:   e.ID,
+			Type:      notificationType,
+			TaskID:    e.TaskID,
+			TaskTitle: e.Task.Title,
+		})
+	}
+
+	switch e.Type {
+	case storage.EventTaskCreated:
+		// Автор сам назначил задачу на себя, уведомлять некого
+		if e.Task.AssignedID != e.Task.AuthorID {
+			add(e.Task.AssignedID, storage.NotificationTaskAssigned)
+		}
+	case storage.EventTaskUpdated:
+		if e.Previous == nil {
+			break
+		}
+		if e.Previous.AssignedID != e.Task.AssignedID {
+			add(e.Task.AssignedID, storage.NotificationTaskAssigned)
+		}
+		if e.Previous.Closed == 0 && e.Task.Closed != 0 {
+			add(e.Task.AuthorID, storage.NotificationTaskClosed)
+		}
+	}
+
+	return result
+}
+
+// Run - обрабатывает события до отмены ctx. Каждый экземпляр сервера обрабатывает все события,
+// дубли отсекаются уникальным ключом уведомления в БД.
+func (s *Service) Run(ctx context.Context) {
+	var lastID int64
+	for {
+		sub := s.events.Subscribe(events.Filter{
+			Types: []string{storage.EventTaskCreated, storage.EventTaskUpdated},
+		}, lastID)
+
+		for _, e := range sub.Replay {
+			s.handle(ctx, &e)
+			lastID = e.ID
+		}
+
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				sub.Close()
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					// Отключены из-за переполнения, переподписываемся с последнего события
+					logger.WarnContext(ctx, "Переподписка обработчика уведомлений с события %d", lastID)
+					break loop
+				}
+				s.handle(ctx, &e)
+				lastID = e.ID
+			}
+		}
+	}
+}
+
+func (s *Service) handle(ctx context.Context, e *storage.Event) {
+	for _, n := range Recipients(e) {
+		created, err := s.storage.NewNotification(ctx, &n)
+		if err != nil {
+			logger.ErrorContext(ctx, "Ошибка при создании уведомления по событию %d: %s", e.ID, err.Error())
+			continue
+		}
+		if created && s.mail != nil && n.Type == storage.NotificationTaskAssigned {
+			s.mailUser(ctx, n.UserID, mailer.KindAssigned, fmt.Sprintf("assigned:%d:%d", e.ID, n.UserID),
+				&mailer.TemplateData{Task: e.Task})
+		}
+	}
+}
+
+// mailUser - ставит письмо пользователю в очередь
+func (s *Service) mailUser(ctx context.Context, userID int, kind, dedupeKey string, data *mailer.TemplateData) {
+	user, err := s.storage.UserById(ctx, userID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Ошибка при получении пользователя %d: %s", userID, err.Error())
+		return
+	}
+	err = s.mail.Enqueue(ctx, user, kind, dedupeKey, data)
+	if err != nil {
+		logger.ErrorContext(ctx, "Ошибка при постановке письма %s в очередь: %s", dedupeKey, err.Error())
+	}
+}
+
+// Интервал проверки сроков задач и отправки сводок.
+const scheduleInterval = time.Minute * 5
+
+// За сколько до срока отправляется напоминание.
+const reminderAhead = time.Hour * 24
+
+// RunSchedule - ставит в очередь напоминания о сроках задач и ежедневные сводки до отмены ctx.
+// Сводки отправляются начиная с часа digestHour (время сервера). Повторная постановка
+// одних и тех же писем отсекается ключом письма, поэтому расписание можно запускать на каждом экземпляре.
+func (s *Service) RunSchedule(ctx context.Context, digestHour int) {
+	if s.mail == nil {
+		return
+	}
+
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		s.reminders(ctx, now)
+		if now.Hour() >= digestHour {
+			s.digests(ctx, now)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reminders - ставит напоминания по задачам, срок которых наступит в ближайшие reminderAhead
+func (s *Service) reminders(ctx context.Context, now time.Time) {
+	tasks, err := s.storage.DueTasks(ctx, now.Unix(), now.Add(reminderAhead).Unix())
+	if err != nil {
+		logger.ErrorContext(ctx, "Ошибка при получении задач со сроком: %s", err.Error())
+		return
+	}
+
+	for _, t := range tasks {
+		if t.AssignedID == 0 {
+			continue
+		}
+		s.mailUser(ctx, t.AssignedID, mailer.KindReminder, fmt.Sprintf("reminder:%d:%d", t.ID, t.Due),
+			&mailer.TemplateData{Task: t, Location: now.Location()})
+	}
+}
+
+// digests - ставит ежедневные сводки открытых задач
+func (s *Service) digests(ctx context.Context, now time.Time) {
+	users, err := s.storage.AllUsers(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "Ошибка при получении пользователей: %s", err.Error())
+		return
+	}
+
+	date := now.Format("2006-01-02")
+	for _, u := range users {
+		if u.Email == "" || u.EmailOptOut {
+			continue
+		}
+		tasks, err := s.storage.OpenTasksByAssignee(ctx, u.ID)
+		if err != nil {
+			logger.ErrorContext(ctx, "Ошибка при получении задач пользователя %d: %s", u.ID, err.Error())
+			continue
+		}
+		if len(tasks) == 0 {
+			continue
+		}
+		err = s.mail.Enqueue(ctx, &u, mailer.KindDigest, fmt.Sprintf("digest:%d:%s", u.ID, date),
+			&mailer.TemplateData{Tasks: tasks, Location: now.Location()})
+		if err != nil {
+			logger.ErrorContext(ctx, "Ошибка при постановке сводки пользователю %d: %s", u.ID, err.Error())
+		}
+	}
+}
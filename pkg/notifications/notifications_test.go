@@ -0,0 +1,73 @@
+package notifications
+
+import (
+	"TaskManager/pkg/storage"
+	"reflect"
+	"testing"
+)
+
+func TestRecipients(t *testing.T) {
+	type recipient struct {
+		UserID int
+		Type   string
+	}
+	tests := []struct {
+		name  string
+		event storage.Event
+		want  []recipient
+	}{
+		{
+			name: "Создание задачи на другого пользователя",
+			event: storage.Event{
+				Type: storage.EventTaskCreated,
+				Task: storage.Task{AuthorID: 1, AssignedID: 2},
+			},
+			want: []recipient{{2, storage.NotificationTaskAssigned}},
+		},
+		{
+			name: "Создание задачи на себя",
+			event: storage.Event{
+				Type: storage.EventTaskCreated,
+				Task: storage.Task{AuthorID: 1, AssignedID: 1},
+			},
+			want: nil,
+		},
+		{
+			name: "Переназначение и закрытие",
+			event: storage.Event{
+				Type:     storage.EventTaskUpdated,
+				Task:     storage.Task{AuthorID: 1, AssignedID: 3, Closed: 100},
+				Previous: &storage.Task{AuthorID: 1, AssignedID: 2},
+			},
+			want: []recipient{{3, storage.NotificationTaskAssigned}, {1, storage.NotificationTaskClosed}},
+		},
+		{
+			name: "Изменение без смены исполнителя",
+			event: storage.Event{
+				Type:     storage.EventTaskUpdated,
+				Task:     storage.Task{AuthorID: 1, AssignedID: 2, Title: "new"},
+				Previous: &storage.Task{AuthorID: 1, AssignedID: 2},
+			},
+			want: nil,
+		},
+		{
+			name: "Удаление",
+			event: storage.Event{
+				Type: storage.EventTaskDeleted,
+				Task: storage.Task{AuthorID: 1, AssignedID: 2},
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []recipient
+			for _, n := range Recipients(&tt.event) {
+				got = append(got, recipient{n.UserID, n.Type})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Recipients() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package rateLimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Как часто MemoryStore удаляет полные корзины.
+const sweepInterval = time.Minute
+
+// MemoryStore - корзины в памяти процесса. Подходит для одного экземпляра сервиса:
+// у каждого экземпляра свои корзины, и общий лимит умножается на число экземпляров.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	// Время последнего удаления полных корзин
+	swept time.Time
+	now   func() time.Time
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	// Когда корзина наполнится полностью, после этого ее можно удалить
+	full time.Time
+}
+
+// NewMemoryStore - конструктор
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryBucket{}, now: time.Now}
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.swept) > sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(l.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.updated).Seconds()*l.Rate)
+	b.updated = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	res := result(l, b.tokens, allowed)
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
+
+// sweep - удаляет корзины, которые уже наполнились: они ничем не отличаются от новых
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+	m.swept = now
+}
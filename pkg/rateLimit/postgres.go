@@ -0,0 +1,51 @@
+package rateLimit
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/storage"
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Через сколько после последнего запроса корзина удаляется из БД. Корзины с лимитами
+// до часа к этому времени наполняются полностью и ничем не отличаются от новых.
+const postgresBucketTTL = time.Hour
+
+// PostgresStore - корзины в таблице rate_limits, общие для всех экземпляров сервиса.
+type PostgresStore struct {
+	storage *storage.Storage
+	// Время последнего удаления старых корзин, unix time
+	swept atomic.Int64
+}
+
+// NewPostgresStore - конструктор
+func NewPostgresStore(s *storage.Storage) *PostgresStore {
+	return &PostgresStore{storage: s}
+}
+
+func (p *PostgresStore) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	p.sweep()
+	tokens, allowed, err := p.storage.TakeRateToken(ctx, key, l.Rate, l.Burst)
+	if err != nil {
+		return Result{}, err
+	}
+	return result(l, tokens, allowed), nil
+}
+
+// sweep - не чаще раза в sweepInterval удаляет в фоне корзины, к которым давно не обращались
+func (p *PostgresStore) sweep() {
+	now := time.Now()
+	last := p.swept.Load()
+	if now.Unix()-last < int64(sweepInterval.Seconds()) || !p.swept.CompareAndSwap(last, now.Unix()) {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err := p.storage.DeleteRateBuckets(ctx, now.Add(-postgresBucketTTL))
+		if err != nil {
+			logger.Error("Ошибка удаления корзин ограничения запросов: %s", err.Error())
+		}
+	}()
+}
@@ -0,0 +1,333 @@
+// Package rateLimit - ограничение частоты запросов к HTTP API по алгоритму token bucket:
+// отдельные корзины на API токен, пользователя и адрес клиента и отдельные лимиты
+// для чтения, записи и массовых операций.
+package rateLimit
+
+import (
+	"TaskManager/pkg/accessLog"
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/metrics"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Class - вид запроса, у каждого вида свои лимиты.
+type Class string
+
+const (
+	// Чтение
+	ClassRead Class = "read"
+	// Изменение данных
+	ClassWrite Class = "write"
+	// Массовые операции: создание и импорт многих задач, выгрузка
+	ClassBulk Class = "bulk"
+	// Запрос не ограничивается: предварительные запросы CORS, мониторинг
+	ClassNone Class = ""
+)
+
+// Scope - чему принадлежит корзина.
+type Scope string
+
+const (
+	ScopeToken Scope = "token"
+	ScopeUser  Scope = "user"
+	ScopeIP    Scope = "ip"
+)
+
+// Limit - скорость пополнения корзины и ее емкость. Нулевой Limit - без ограничения.
+type Limit struct {
+	// Маркеров в секунду
+	Rate float64
+	// Емкость корзины: сколько запросов можно сделать подряд
+	Burst int
+}
+
+// Unlimited - лимит не задан
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// window - за сколько секунд пустая корзина наполняется полностью
+func (l Limit) window() float64 {
+	return float64(l.Burst) / l.Rate
+}
+
+// ParseLimit - разбирает лимит вида "100/s", "600/m", "5000/h" с необязательной емкостью
+// корзины через двоеточие: "10/m:20". Без емкости она равна числу запросов за период.
+// Пустая строка и "0" - без ограничения
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+	rate, burst, hasBurst := strings.Cut(s, ":")
+	count, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("неверный лимит %q, ожидается вид 100/s", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("неверное число запросов в лимите %q", s)
+	}
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("неверный период в лимите %q, ожидается s, m или h", s)
+	}
+	l := Limit{Rate: float64(n) / period.Seconds(), Burst: n}
+	if hasBurst {
+		l.Burst, err = strconv.Atoi(burst)
+		if err != nil || l.Burst <= 0 {
+			return Limit{}, fmt.Errorf("неверная емкость в лимите %q", s)
+		}
+	}
+	return l, nil
+}
+
+// Limits - лимиты одного вида запросов для каждой корзины.
+type Limits struct {
+	Token Limit
+	User  Limit
+	IP    Limit
+}
+
+// ParseLimits - разбирает лимиты корзин вида "ip=100/s,token=50/s,user=50/s".
+// Незаданные корзины не ограничиваются
+func ParseLimits(s string) (Limits, error) {
+	var limits Limits
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		scope, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return limits, fmt.Errorf("неверный лимит %q, ожидается вид ip=100/s", item)
+		}
+		l, err := ParseLimit(value)
+		if err != nil {
+			return limits, err
+		}
+		switch Scope(scope) {
+		case ScopeToken:
+			limits.Token = l
+		case ScopeUser:
+			limits.User = l
+		case ScopeIP:
+			limits.IP = l
+		default:
+			return limits, fmt.Errorf("неизвестная корзина %q, ожидается token, user или ip", scope)
+		}
+	}
+	return limits, nil
+}
+
+// Result - состояние корзины после попытки взять маркер.
+type Result struct {
+	Allowed bool
+	// Осталось маркеров
+	Remaining int
+	// Через сколько корзина наполнится полностью
+	Reset time.Duration
+	// Через сколько появится маркер, если запрос отклонен
+	RetryAfter time.Duration
+}
+
+// result - состояние корзины с tokens маркерами после попытки
+func result(l Limit, tokens float64, allowed bool) Result {
+	r := Result{Allowed: allowed, Remaining: int(math.Max(0, math.Floor(tokens)))}
+	r.Reset = time.Duration((float64(l.Burst) - tokens) / l.Rate * float64(time.Second))
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) / l.Rate * float64(time.Second))
+	}
+	return r
+}
+
+// Store - хранилище корзин.
+type Store interface {
+	// Take - пополняет корзину key по лимиту l и берет из нее маркер, если он есть
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// Config - настройки ограничения.
+type Config struct {
+	// Лимиты по видам запросов
+	Read  Limits
+	Write Limits
+	Bulk  Limits
+	// Хранилище корзин, nil - в памяти процесса
+	Store Store
+	// Токены, запросы с которыми не ограничиваются: токен администратора
+	ExemptTokens []string
+	// Вид запроса, nil - по методу: GET и HEAD - чтение, остальные - запись
+	Classify func(r *http.Request) Class
+	// Пользователь, от имени которого выполняется запрос, nil или "" - корзина пользователя не используется
+	User func(r *http.Request) string
+}
+
+// Limiter - промежуточный обработчик, ограничивающий частоту запросов.
+type Limiter struct {
+	config Config
+}
+
+// New - конструктор
+func New(config Config) *Limiter {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.Classify == nil {
+		config.Classify = ClassifyMethod
+	}
+	return &Limiter{config: config}
+}
+
+// ClassifyMethod - вид запроса по методу: предварительные запросы CORS не ограничиваются,
+// GET и HEAD - чтение, остальные - запись
+func ClassifyMethod(r *http.Request) Class {
+	switch r.Method {
+	case http.MethodOptions:
+		return ClassNone
+	case http.MethodGet, http.MethodHead:
+		return ClassRead
+	}
+	return ClassWrite
+}
+
+// limits - лимиты вида запросов
+func (l *Limiter) limits(c Class) Limits {
+	switch c {
+	case ClassRead:
+		return l.config.Read
+	case ClassWrite:
+		return l.config.Write
+	case ClassBulk:
+		return l.config.Bulk
+	}
+	return Limits{}
+}
+
+// bucket - корзина, из которой запрос берет маркер.
+type bucket struct {
+	scope Scope
+	key   string
+	limit Limit
+}
+
+// buckets - корзины запроса. Запрос берет маркер из корзины адреса клиента и, если они есть,
+// из корзин токена и пользователя: подмена токена или пользователя не обходит лимит адреса.
+// Второй результат - запрос с токеном из ExemptTokens
+func (l *Limiter) buckets(r *http.Request, c Class) ([]bucket, bool) {
+	limits := l.limits(c)
+	var buckets []bucket
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		for _, exempt := range l.config.ExemptTokens {
+			if exempt != "" && subtle.ConstantTimeCompare([]byte(token), []byte(exempt)) == 1 {
+				return nil, true
+			}
+		}
+		// Сам токен не хранится в ключе, ключи Postgres хранилища видны в БД
+		sum := sha256.Sum256([]byte(token))
+		buckets = append(buckets, bucket{ScopeToken, hex.EncodeToString(sum[:8]), limits.Token})
+	}
+	if l.config.User != nil {
+		if user := l.config.User(r); user != "" {
+			buckets = append(buckets, bucket{ScopeUser, user, limits.User})
+		}
+	}
+	ip := accessLog.ClientIP(r.Context())
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+	buckets = append(buckets, bucket{ScopeIP, ip, limits.IP})
+	return buckets, false
+}
+
+// Middleware - берет маркер из корзин запроса, добавляет заголовки RateLimit-* самой
+// заполненной корзины и отклоняет запрос с кодом 429 и заголовком Retry-After, если
+// в какой-то из корзин нет маркеров. При ошибке хранилища запрос пропускается
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := l.config.Classify(r)
+		if class == ClassNone {
+			next.ServeHTTP(w, r)
+			return
+		}
+		buckets, exempt := l.buckets(r, class)
+		if exempt {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var tightest *Result
+		var tightestLimit Limit
+		var rejected *bucket
+		for i, b := range buckets {
+			if b.limit.Unlimited() {
+				continue
+			}
+			res, err := l.config.Store.Take(r.Context(), string(b.scope)+":"+string(class)+":"+b.key, b.limit)
+			if err != nil {
+				logger.ErrorContext(r.Context(), "Ошибка ограничения запросов: %s", err.Error())
+				continue
+			}
+			if tightest == nil || tighter(res, *tightest) {
+				tightest, tightestLimit = &res, b.limit
+			}
+			if !res.Allowed && rejected == nil {
+				rejected = &buckets[i]
+			}
+		}
+		if tightest != nil {
+			setHeaders(w.Header(), tightestLimit, *tightest)
+		}
+		if rejected != nil {
+			metrics.ObserveRateLimited(string(class), string(rejected.scope))
+			logger.WarnContext(r.Context(), "Превышен лимит запросов (%s, %s)", class, rejected.scope)
+			http.Error(w, "Превышен лимит запросов", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tighter - a ограничивает сильнее b: отклоненный запрос, затем меньший остаток
+func tighter(a, b Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+// setHeaders - заголовки RateLimit-* (draft-ietf-httpapi-ratelimit-headers) и Retry-After
+func setHeaders(h http.Header, l Limit, r Result) {
+	h.Set("RateLimit-Limit", strconv.Itoa(l.Burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(r.Reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", l.Burst, seconds(time.Duration(l.window()*float64(time.Second)))))
+	if !r.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(1, seconds(r.RetryAfter))))
+	}
+}
+
+// seconds - длительность в целых секундах с округлением вверх
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
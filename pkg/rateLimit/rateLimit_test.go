@@ -0,0 +1,155 @@
+package rateLimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Limit
+	}{
+		{"", Limit{}},
+		{"0", Limit{}},
+		{"100/s", Limit{Rate: 100, Burst: 100}},
+		{"600/m", Limit{Rate: 10, Burst: 600}},
+		{"3600/h:10", Limit{Rate: 1, Burst: 10}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.s)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %v, %v, ожидалось %v", tt.s, got, err, tt.want)
+		}
+	}
+	for _, s := range []string{"100", "x/s", "-1/s", "10/d", "10/s:0"} {
+		_, err := ParseLimit(s)
+		if err == nil {
+			t.Errorf("ParseLimit(%q): ожидалась ошибка", s)
+		}
+	}
+
+	limits, err := ParseLimits("ip=10/s, token=5/s")
+	if err != nil || limits.IP.Rate != 10 || limits.Token.Rate != 5 || !limits.User.Unlimited() {
+		t.Errorf("ParseLimits: получено %+v, %v", limits, err)
+	}
+	_, err = ParseLimits("host=10/s")
+	if err == nil {
+		t.Error("ParseLimits: ожидалась ошибка неизвестной корзины")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	m := NewMemoryStore()
+	m.now = func() time.Time { return now }
+	l := Limit{Rate: 1, Burst: 2}
+
+	for i, want := range []bool{true, true, false} {
+		res, _ := m.Take(ctx, "k", l)
+		if res.Allowed != want {
+			t.Fatalf("запрос %d: ожидалось %v", i+1, want)
+		}
+	}
+	res, _ := m.Take(ctx, "k", l)
+	if res.RetryAfter != time.Second || res.Remaining != 0 {
+		t.Errorf("пустая корзина: ожидался Retry-After 1s, получено %+v", res)
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	res, _ = m.Take(ctx, "k", l)
+	if !res.Allowed || res.Remaining != 0 || res.Reset != 1500*time.Millisecond {
+		t.Errorf("после пополнения: получено %+v", res)
+	}
+	res, _ = m.Take(ctx, "other", l)
+	if !res.Allowed || res.Remaining != 1 {
+		t.Errorf("другая корзина: получено %+v", res)
+	}
+
+	// Полные корзины удаляются
+	now = now.Add(time.Hour)
+	_, _ = m.Take(ctx, "k", l)
+	if len(m.buckets) != 1 {
+		t.Errorf("ожидалась одна корзина после удаления полных, получено %d", len(m.buckets))
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l := New(Config{
+		Read:         Limits{IP: Limit{Rate: 1, Burst: 3}, Token: Limit{Rate: 1, Burst: 2}},
+		Write:        Limits{IP: Limit{Rate: 1, Burst: 1}},
+		ExemptTokens: []string{"admin"},
+	})
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(method, token, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodGet, "t1", "192.0.2.1")
+	if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("первый запрос: ожидались заголовки корзины токена, получено %d %v", rec.Code, rec.Header())
+	}
+	request(http.MethodGet, "t1", "192.0.2.1")
+	rec = request(http.MethodGet, "t1", "192.0.2.1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("лимит токена: ожидался 429 с Retry-After, получено %d %v", rec.Code, rec.Header())
+	}
+
+	// Новый токен не обходит лимит адреса
+	rec = request(http.MethodGet, "t2", "192.0.2.1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("лимит адреса: ожидался 429, получено %d", rec.Code)
+	}
+	rec = request(http.MethodGet, "", "192.0.2.2")
+	if rec.Code != http.StatusOK {
+		t.Errorf("другой адрес: ожидался 200, получено %d", rec.Code)
+	}
+
+	// У записи свои корзины
+	if rec = request(http.MethodPost, "", "192.0.2.1"); rec.Code != http.StatusOK {
+		t.Errorf("запись: ожидался 200, получено %d", rec.Code)
+	}
+	if rec = request(http.MethodPost, "", "192.0.2.1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("лимит записи: ожидался 429, получено %d", rec.Code)
+	}
+
+	for i := 0; i < 5; i++ {
+		rec = request(http.MethodPost, "admin", "192.0.2.1")
+		if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("токен администратора не ограничивается, получено %d", rec.Code)
+		}
+		rec = request(http.MethodOptions, "", "192.0.2.1")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("OPTIONS не ограничивается, получено %d", rec.Code)
+		}
+	}
+}
+
+func TestResult(t *testing.T) {
+	l := Limit{Rate: 0.5, Burst: 10}
+	r := result(l, 0.25, false)
+	if r.RetryAfter != 1500*time.Millisecond || r.Reset != 19500*time.Millisecond {
+		t.Errorf("получено %+v", r)
+	}
+	h := http.Header{}
+	setHeaders(h, l, r)
+	want := map[string]string{"RateLimit-Limit": "10", "RateLimit-Remaining": "0", "RateLimit-Reset": "20",
+		"RateLimit-Policy": "10;w=20", "Retry-After": strconv.Itoa(2)}
+	for k, v := range want {
+		if h.Get(k) != v {
+			t.Errorf("%s: ожидалось %s, получено %s", k, v, h.Get(k))
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+)
+
+// Пакетные выборки для загрузки связанных записей одним запросом вместо запроса на каждую запись.
+
+// UsersByIds - возвращает пользователей с заданными ID, отсутствующие ID пропускаются
+func (s *Storage) UsersByIds(ctx context.Context, ids []int) (_ []User, err error) {
+	ctx, end := startOp(ctx, "UsersByIds")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT id, name, email, locale, email_opt_out, deactivated
+		FROM users
+		WHERE id = ANY($1)
+		ORDER BY id;
+	`,
+		ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		err = scanUser(rows, &u)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// LabelsByTaskIds - возвращает метки задач с заданными ID, сгруппированные по ID задачи
+func (s *Storage) LabelsByTaskIds(ctx context.Context, taskIDs []int) (_ map[int][]Label, err error) {
+	ctx, end := startOp(ctx, "LabelsByTaskIds")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT
+			tl.task_id,
+			l.id,
+			l.scope,
+			l.name,
+			l.color,
+			l.description
+		FROM labels as l
+		INNER JOIN tasks_labels as tl
+		ON (tl.task_id = ANY($1)) AND (l.id = tl.label_id)
+		ORDER BY tl.task_id, l.id;
+	`,
+		taskIDs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := map[int][]Label{}
+	for rows.Next() {
+		var taskID int
+		var l Label
+		err = rows.Scan(&taskID, &l.ID, &l.Scope, &l.Name, &l.Color, &l.Description)
+		if err != nil {
+			return nil, err
+		}
+		labels[taskID] = append(labels[taskID], l)
+	}
+	return labels, rows.Err()
+}
+
+// TasksByLabelIds - возвращает задачи с метками из labelIDs, сгруппированные по ID метки
+func (s *Storage) TasksByLabelIds(ctx context.Context, labelIDs []int) (_ map[int][]Task, err error) {
+	ctx, end := startOp(ctx, "TasksByLabelIds")
+	defer end(&err)
+	return s.groupedTasks(ctx, `
+		SELECT
+			tl.label_id,
+			t.id,
+			t.opened,
+			t.closed,
+			t.author_id,
+			t.assigned_id,
+			t.title,
+			t.content,
+			t.due,
+			t.priority
+		FROM tasks as t
+		INNER JOIN tasks_labels as tl
+		ON (tl.label_id = ANY($1)) AND (t.id = tl.task_id)
+		ORDER BY tl.label_id, t.id;
+	`, labelIDs)
+}
+
+// TasksByAssigneeIds - возвращает задачи исполнителей из userIDs, сгруппированные по ID исполнителя
+func (s *Storage) TasksByAssigneeIds(ctx context.Context, userIDs []int) (_ map[int][]Task, err error) {
+	ctx, end := startOp(ctx, "TasksByAssigneeIds")
+	defer end(&err)
+	return s.groupedTasks(ctx, `
+		SELECT
+			assigned_id,
+			id,
+			opened,
+			closed,
+			author_id,
+			assigned_id,
+			title,
+			content,
+			due,
+			priority
+		FROM tasks
+		WHERE assigned_id = ANY($1)
+		ORDER BY assigned_id, id;
+	`, userIDs)
+}
+
+// TasksByAuthorIds - возвращает задачи авторов из userIDs, сгруппированные по ID автора
+func (s *Storage) TasksByAuthorIds(ctx context.Context, userIDs []int) (_ map[int][]Task, err error) {
+	ctx, end := startOp(ctx, "TasksByAuthorIds")
+	defer end(&err)
+	return s.groupedTasks(ctx, `
+		SELECT
+			author_id,
+			id,
+			opened,
+			closed,
+			author_id,
+			assigned_id,
+			title,
+			content,
+			due,
+			priority
+		FROM tasks
+		WHERE author_id = ANY($1)
+		ORDER BY author_id, id;
+	`, userIDs)
+}
+
+// groupedTasks - выполняет запрос, первая колонка которого - ключ группы, остальные - колонки задачи
+func (s *Storage) groupedTasks(ctx context.Context, sql string, ids []int) (map[int][]Task, error) {
+	rows, err := s.db().Query(ctx, sql, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := map[int][]Task{}
+	for rows.Next() {
+		var key int
+		var t Task
+		err = rows.Scan(
+			&key,
+			&t.ID,
+			&t.Opened,
+			&t.Closed,
+			&t.AuthorID,
+			nullID{&t.AssignedID},
+			&t.Title,
+			&t.Content,
+			&t.Due,
+			&t.Priority,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tasks[key] = append(tasks[key], t)
+	}
+	return tasks, rows.Err()
+}
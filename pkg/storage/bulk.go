@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Максимальное количество задач в одной массовой операции.
+const MaxBulkTasks = 1000
+
+// Режимы массовых операций.
+const (
+	// Все или ничего: при ошибке в любой задаче изменения не сохраняются
+	BulkAtomic = "atomic"
+	// Изменения сохраняются для задач без ошибок
+	BulkBestEffort = "best-effort"
+)
+
+// Ошибки выбора задач массовой операции.
+var (
+	ErrBulkEmpty    = errors.New("не заданы ни ID задач, ни фильтр")
+	ErrBulkTooLarge = fmt.Errorf("операция затрагивает больше %d задач", MaxBulkTasks)
+)
+
+// TaskSelector - задачи массовой операции: по списку ID или, если он пуст, по фильтру.
+// Пустой фильтр не выбирает все задачи, а считается ошибкой.
+type TaskSelector struct {
+	IDs    []int
+	Filter TaskFilter
+}
+
+// TaskChanges - изменения задач массовой операции, nil и пустые списки - без изменений.
+type TaskChanges struct {
+	// 0 - снять исполнителя
+	AssigneeID *int   `json:"AssigneeID,omitempty"`
+	Priority   *int   `json:"Priority,omitempty"`
+	Due        *int64 `json:"Due,omitempty"`
+	// true - закрыть открытые задачи, false - открыть закрытые заново
+	Closed       *bool `json:"Closed,omitempty"`
+	AddLabels    []int `json:"AddLabels,omitempty"`
+	RemoveLabels []int `json:"RemoveLabels,omitempty"`
+}
+
+// Empty - изменений нет
+func (c TaskChanges) Empty() bool {
+	return c.AssigneeID == nil && c.Priority == nil && c.Due == nil && c.Closed == nil &&
+		len(c.AddLabels) == 0 && len(c.RemoveLabels) == 0
+}
+
+// BulkOptions - параметры массовой операции.
+type BulkOptions struct {
+	// BulkAtomic или BulkBestEffort, пустой - BulkAtomic
+	Mode string
+	// Только показать результат, ничего не сохраняя
+	DryRun bool
+}
+
+// BulkTaskResult - результат массовой операции для одной задачи.
+type BulkTaskResult struct {
+	TaskID int
+	// Задача после изменения или удаленная задача
+	Task *Task `json:"Task,omitempty"`
+	// Задача до изменения
+	Previous *Task  `json:"Previous,omitempty"`
+	Error    string `json:"Error,omitempty"`
+}
+
+// BulkReport - отчет о массовой операции.
+type BulkReport struct {
+	Mode      string
+	DryRun    bool
+	Committed bool
+	// Выбрано задач
+	Matched   int
+	Succeeded int
+	Failed    int
+	Tasks     []BulkTaskResult
+}
+
+// UpdateTasks - изменяет задачи в одной транзакции и возвращает отчет по каждой задаче.
+// Для каждой измененной задачи публикуются события task.updated и task.relabeled.
+// Добавляемая метка группы заменяет метку той же группы, две добавляемые метки одной
+// группы - ошибка ErrInvalid.
+func (s *Storage) UpdateTasks(ctx context.Context, sel TaskSelector, changes TaskChanges, opts BulkOptions) (_ *BulkReport, err error) {
+	ctx, end := startOp(ctx, "UpdateTasks")
+	defer end(&err)
+	// Проверки выполняются в транзакции операции: блокировка исполнителя держится до ее конца
+	check := func(ctx context.Context, q querier) error {
+		err := checkExclusive(ctx, q, changes.AddLabels)
+		if err != nil {
+			return err
+		}
+		if changes.AssigneeID != nil {
+			return checkAssignee(ctx, q, *changes.AssigneeID)
+		}
+		return nil
+	}
+	return s.bulk(ctx, sel, opts, check, func(ctx context.Context, q querier, id int) (*Task, *Task, error) {
+		return bulkUpdateTask(ctx, q, id, changes)
+	})
+}
+
+// DeleteTasks - удаляет задачи в одной транзакции вместе с их связями с метками
+// и возвращает отчет по каждой задаче.
+func (s *Storage) DeleteTasks(ctx context.Context, sel TaskSelector, opts BulkOptions) (_ *BulkReport, err error) {
+	ctx, end := startOp(ctx, "DeleteTasks")
+	defer end(&err)
+	return s.bulk(ctx, sel, opts, nil, bulkDeleteTask)
+}
+
+// bulkFunc - операция над одной задачей, возвращает задачу после и до операции
+type bulkFunc func(ctx context.Context, q querier, id int) (*Task, *Task, error)
+
+// bulk - выполняет fn для каждой выбранной задачи. Каждая задача обрабатывается в своей
+// точке сохранения, поэтому ошибка в задаче не мешает проверить остальные. check, если
+// задан, выполняется в той же транзакции до выбора задач, его ошибка прерывает операцию
+func (s *Storage) bulk(ctx context.Context, sel TaskSelector, opts BulkOptions, check func(ctx context.Context, q querier) error, fn bulkFunc) (*BulkReport, error) {
+	if opts.Mode == "" {
+		opts.Mode = BulkAtomic
+	}
+	if opts.Mode != BulkAtomic && opts.Mode != BulkBestEffort {
+		return nil, fmt.Errorf("неизвестный режим массовой операции: %s", opts.Mode)
+	}
+	if len(sel.IDs) == 0 && sel.Filter.Empty() {
+		return nil, ErrBulkEmpty
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if check != nil {
+		err = check(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ids, err := selectTasks(ctx, tx, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BulkReport{Mode: opts.Mode, DryRun: opts.DryRun, Matched: len(ids), Tasks: []BulkTaskResult{}}
+	for _, id := range ids {
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result := BulkTaskResult{TaskID: id}
+		result.Task, result.Previous, err = fn(ctx, tracedQuerier{sp}, id)
+		if err != nil {
+			result.Error = err.Error()
+			report.Failed++
+			err = sp.Rollback(ctx)
+		} else {
+			report.Succeeded++
+			err = sp.Commit(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+		report.Tasks = append(report.Tasks, result)
+	}
+
+	if opts.DryRun || report.Succeeded == 0 || (opts.Mode == BulkAtomic && report.Failed > 0) {
+		return report, nil
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Committed = true
+
+	keys := make([]string, 0, report.Succeeded)
+	for _, r := range report.Tasks {
+		if r.Error == "" {
+			keys = append(keys, taskKey(r.TaskID))
+		}
+	}
+	s.invalidate(ctx, keys...)
+
+	return report, nil
+}
+
+// selectTasks - ID задач по списку или фильтру с блокировкой задач до конца транзакции.
+// ID из списка возвращаются все, в порядке списка без повторов: отсутствующие задачи
+// попадут в отчет с ошибкой
+func selectTasks(ctx context.Context, q querier, sel TaskSelector) ([]int, error) {
+	if len(sel.IDs) > 0 {
+		var ids []int
+		seen := map[int]bool{}
+		for _, id := range sel.IDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > MaxBulkTasks {
+			return nil, ErrBulkTooLarge
+		}
+		_, err := q.Exec(ctx, "SELECT id FROM tasks WHERE id = ANY($1) ORDER BY id FOR UPDATE;", ids)
+		return ids, err
+	}
+
+	rows, err := q.Query(ctx, `
+		SELECT t.id
+		FROM tasks as t
+		WHERE`+taskFilterSQL+`
+		ORDER BY t.id
+		LIMIT `+fmt.Sprint(MaxBulkTasks+1)+`
+		FOR UPDATE;
+	`, sel.Filter.args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > MaxBulkTasks {
+		return nil, ErrBulkTooLarge
+	}
+	return ids, rows.Err()
+}
+
+// bulkTask - задача массовой операции, отсутствие задачи - ошибка с понятным текстом
+func bulkTask(ctx context.Context, q querier, id int) (*Task, error) {
+	t, err := taskById(ctx, q, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("задача %d не найдена", id)
+	}
+	return t, err
+}
+
+// bulkUpdateTask - применяет изменения к задаче и публикует события об изменении
+func bulkUpdateTask(ctx context.Context, q querier, id int, c TaskChanges) (*Task, *Task, error) {
+	previous, err := bulkTask(ctx, q, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = q.Exec(ctx, `
+		UPDATE tasks
+		SET
+			assigned_id = CASE WHEN $2::integer IS NULL THEN assigned_id ELSE NULLIF($2, 0) END,
+			priority = COALESCE($3, priority),
+			due = COALESCE($4, due),
+			closed = CASE
+				WHEN $5::boolean IS NULL THEN closed
+				WHEN $5 THEN CASE WHEN COALESCE(closed, 0) = 0 THEN extract(epoch from now())::bigint ELSE closed END
+				ELSE 0
+			END
+		WHERE
+			(id = $1);`,
+		id,
+		c.AssigneeID,
+		c.Priority,
+		c.Due,
+		c.Closed,
+	)
+	if err != nil {
+		return nil, previous, err
+	}
+
+	relabeled := false
+	for _, labelID := range c.AddLabels {
+		tag, err := q.Exec(ctx, addTaskLabelSQL, id, labelID)
+		if err != nil {
+			return nil, previous, err
+		}
+		relabeled = relabeled || tag.RowsAffected() > 0
+	}
+	for _, labelID := range c.RemoveLabels {
+		tag, err := q.Exec(ctx, `
+			DELETE FROM tasks_labels
+			WHERE task_id = $1 AND label_id = $2;`,
+			id, labelID)
+		if err != nil {
+			return nil, previous, err
+		}
+		relabeled = relabeled || tag.RowsAffected() > 0
+	}
+
+	task, err := taskById(ctx, q, id)
+	if err != nil {
+		return nil, previous, err
+	}
+	if *task != *previous {
+		err = publishEvent(ctx, q, EventTaskUpdated, task, previous)
+		if err != nil {
+			return task, previous, err
+		}
+	}
+	if relabeled {
+		err = publishEvent(ctx, q, EventTaskRelabeled, task, nil)
+		if err != nil {
+			return task, previous, err
+		}
+	}
+	return task, previous, nil
+}
+
+// bulkDeleteTask - удаляет задачу. Событие публикуется до удаления, чтобы в нем остались метки задачи
+func bulkDeleteTask(ctx context.Context, q querier, id int) (*Task, *Task, error) {
+	task, err := bulkTask(ctx, q, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = publishEvent(ctx, q, EventTaskDeleted, task, nil)
+	if err != nil {
+		return task, nil, err
+	}
+	_, err = q.Exec(ctx, "DELETE FROM tasks_labels WHERE task_id = $1;", id)
+	if err != nil {
+		return task, nil, err
+	}
+	_, err = q.Exec(ctx, "DELETE FROM tasks WHERE id = $1;", id)
+	if err != nil {
+		return task, nil, err
+	}
+	return task, nil, nil
+}
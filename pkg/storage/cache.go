@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"TaskManager/pkg/logger"
+	"TaskManager/pkg/metrics"
+	"container/list"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Канал Postgres, в который публикуются ключи кэша, измененные одним из экземпляров сервиса.
+const CacheChannel = "cache_invalidate"
+
+// Ключ, удаляющий из кэша все записи.
+const cacheAll = "*"
+
+// CacheConfig - настройки кэша чтения меток, пользователей и задач по ID.
+type CacheConfig struct {
+	// Время жизни записи, 0 - кэш отключен
+	TTL time.Duration
+	// Максимальное количество записей, при превышении удаляются давно не читавшиеся
+	MaxEntries int
+}
+
+// cache - кэш с ограничением времени жизни и количества записей. Записи удаляются
+// при изменении данных этим экземпляром сервиса и по уведомлениям остальных экземпляров.
+type cache struct {
+	ttl time.Duration
+	max int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// Записи от недавно прочитанных к давно прочитанным
+	lru *list.List
+	// Увеличивается при каждом удалении. Значение, прочитанное из БД до удаления,
+	// не сохраняется: оно могло быть прочитано до изменения
+	gen uint64
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+func newCache(config CacheConfig) *cache {
+	return &cache{
+		ttl:     config.TTL,
+		max:     config.MaxEntries,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// get - значение по ключу и поколение кэша, которое передается в set после чтения из БД
+func (c *cache) get(key string) (any, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, c.gen, false
+	}
+	e := el.Value.(*cacheEntry)
+	if !c.now().Before(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil, c.gen, false
+	}
+	c.lru.MoveToFront(el)
+	return e.value, c.gen, true
+}
+
+// set - сохраняет значение, если с момента get ничего не удалялось
+func (c *cache) set(key string, value any, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if el, ok := c.entries[key]; ok {
+		c.lru.Remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, value: value, expires: c.now().Add(c.ttl)})
+	for c.max > 0 && c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// remove - удаляет записи по ключам, ключ cacheAll удаляет все записи
+func (c *cache) remove(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for _, key := range keys {
+		if key == cacheAll {
+			c.entries = map[string]*list.Element{}
+			c.lru.Init()
+			return
+		}
+		if el, ok := c.entries[key]; ok {
+			c.lru.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Ключи кэша.
+const (
+	labelsKey = "labels"
+	usersKey  = "users"
+)
+
+func labelKey(id int) string { return "label:" + strconv.Itoa(id) }
+func userKey(id int) string  { return "user:" + strconv.Itoa(id) }
+func taskKey(id int) string  { return "task:" + strconv.Itoa(id) }
+
+// cached - значение из кэша или, если его там нет, из load с сохранением в кэш.
+// Ошибки load не кэшируются. Без кэша просто вызывает load
+func cached[T any](s *Storage, key string, load func() (T, error)) (T, error) {
+	if s.cache == nil {
+		return load()
+	}
+	kind, _, _ := strings.Cut(key, ":")
+	v, gen, ok := s.cache.get(key)
+	metrics.ObserveCache(kind, ok)
+	if ok {
+		return v.(T), nil
+	}
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	s.cache.set(key, value, gen)
+	return value, nil
+}
+
+// cachedSlice - как cached, но возвращает копию среза, чтобы вызывающий не изменил кэш
+func cachedSlice[T any](s *Storage, key string, load func() ([]T, error)) ([]T, error) {
+	v, err := cached(s, key, load)
+	return slices.Clone(v), err
+}
+
+// EnableCache - включает кэш чтения меток, пользователей и задач по ID. Вызывается
+// при запуске, до начала обработки запросов. Чтобы изменения других экземпляров
+// сервиса удалялись из кэша, нужно запустить ListenCache
+func (s *Storage) EnableCache(config CacheConfig) {
+	if config.TTL <= 0 {
+		s.cache = nil
+		return
+	}
+	s.cache = newCache(config)
+}
+
+// invalidate - удаляет ключи из кэша этого экземпляра и уведомляет остальные экземпляры.
+// Вызывается после фиксации изменения; ошибка уведомления только записывается в журнал:
+// у остальных экземпляров запись устареет не дольше, чем на время жизни записи
+func (s *Storage) invalidate(ctx context.Context, keys ...string) {
+	if s.cache == nil {
+		return
+	}
+	s.cache.remove(keys...)
+	s.notifyCache(ctx, keys)
+}
+
+// Максимальная длина уведомления, у Postgres предел 8000 байт.
+const maxNotifyPayload = 7000
+
+// notifyCache - уведомляет экземпляры сервиса об изменении ключей, длинный список
+// ключей отправляется несколькими уведомлениями
+func (s *Storage) notifyCache(ctx context.Context, keys []string) {
+	for len(keys) > 0 {
+		n, size := 0, 0
+		for n < len(keys) && (n == 0 || size+len(keys[n])+1 <= maxNotifyPayload) {
+			size += len(keys[n]) + 1
+			n++
+		}
+		_, err := s.db().Exec(ctx, "SELECT pg_notify($1, $2);", CacheChannel, strings.Join(keys[:n], ","))
+		if err != nil {
+			logger.ErrorContext(ctx, "Ошибка уведомления об изменении кэша: %s", err.Error())
+			return
+		}
+		keys = keys[n:]
+	}
+}
+
+// InvalidateCache - очищает кэш всех экземпляров сервиса после изменения данных в обход
+// методов хранилища, например после восстановления из резервной копии. Уведомление
+// отправляется и без своего кэша: восстановление может выполняться отдельной командой
+func (s *Storage) InvalidateCache(ctx context.Context) {
+	if s.cache != nil {
+		s.cache.remove(cacheAll)
+	}
+	s.notifyCache(ctx, []string{cacheAll})
+}
+
+// ListenCache - слушает канал CacheChannel и удаляет из кэша ключи, измененные
+// другими экземплярами, до отмены ctx. При потере соединения кэш очищается, так как
+// уведомления могли быть пропущены, и соединение восстанавливается с паузой.
+func (s *Storage) ListenCache(ctx context.Context) {
+	if s.cache == nil {
+		return
+	}
+	for {
+		err := s.listenCache(ctx)
+		s.cache.remove(cacheAll)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.ErrorContext(ctx, "Ошибка при прослушивании изменений кэша: %s", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second * 5):
+		}
+	}
+}
+
+func (s *Storage) listenCache(ctx context.Context) error {
+	conn, err := s.DB.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "LISTEN "+CacheChannel)
+	if err != nil {
+		return err
+	}
+	// Изменения, сделанные до начала прослушивания
+	s.cache.remove(cacheAll)
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		s.cache.remove(strings.Split(n.Payload, ",")...)
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := newCache(CacheConfig{TTL: time.Minute, MaxEntries: 2})
+	c.now = func() time.Time { return now }
+
+	_, gen, _ := c.get("a")
+	c.set("a", 1, gen)
+	c.set("b", 2, gen)
+	if v, _, ok := c.get("a"); !ok || v != 1 {
+		t.Fatalf("ожидалось значение 1, получено %v, %v", v, ok)
+	}
+
+	// Превышение размера вытесняет давно не читавшуюся запись
+	c.set("c", 3, gen)
+	if _, _, ok := c.get("b"); ok {
+		t.Error("запись b должна быть вытеснена")
+	}
+	if _, _, ok := c.get("a"); !ok {
+		t.Error("недавно прочитанная запись a не должна вытесняться")
+	}
+
+	now = now.Add(time.Minute)
+	if _, _, ok := c.get("a"); ok {
+		t.Error("запись с истекшим временем жизни не должна возвращаться")
+	}
+
+	// Значение, прочитанное до удаления, не сохраняется
+	_, gen, _ = c.get("d")
+	c.remove("x")
+	c.set("d", 4, gen)
+	if _, _, ok := c.get("d"); ok {
+		t.Error("значение, прочитанное до удаления, сохранено в кэш")
+	}
+
+	_, gen, _ = c.get("d")
+	c.set("d", 4, gen)
+	c.set("e", 5, gen)
+	c.remove(cacheAll)
+	if _, _, ok := c.get("e"); ok || c.lru.Len() != 0 {
+		t.Error("ключ * должен удалять все записи")
+	}
+}
+
+func TestCached(t *testing.T) {
+	s := &Storage{}
+	loads := 0
+	load := func() ([]Label, error) {
+		loads++
+		return []Label{{ID: 1, Name: "bug"}}, nil
+	}
+
+	// Без кэша каждый вызов читает из БД
+	_, _ = cachedSlice(s, labelsKey, load)
+	_, _ = cachedSlice(s, labelsKey, load)
+	if loads != 2 {
+		t.Errorf("без кэша ожидалось 2 чтения, получено %d", loads)
+	}
+
+	s.EnableCache(CacheConfig{TTL: time.Minute})
+	loads = 0
+	labels, _ := cachedSlice(s, labelsKey, load)
+	labels[0].Name = "changed"
+	labels, _ = cachedSlice(s, labelsKey, load)
+	if loads != 1 || labels[0].Name != "bug" {
+		t.Errorf("ожидалось одно чтение и неизмененная копия, получено %d, %v", loads, labels)
+	}
+
+	fail := errors.New("fail")
+	_, err := cached(s, userKey(1), func() (User, error) { return User{}, fail })
+	if err != fail {
+		t.Fatal(err)
+	}
+	if _, _, ok := s.cache.get(userKey(1)); ok {
+		t.Error("ошибка чтения не должна кэшироваться")
+	}
+
+	s.EnableCache(CacheConfig{})
+	if s.cache != nil {
+		t.Error("нулевой TTL должен отключать кэш")
+	}
+}
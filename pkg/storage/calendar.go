@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// CalendarTask - задача для календарной ленты.
+type CalendarTask struct {
+	TaskDetails
+	// Время последнего изменения задачи (unix time)
+	Modified int64
+	// Количество изменений задачи после создания, по нему календари понимают, что запись обновилась
+	Sequence int
+}
+
+// CalendarTasks - возвращает задачи исполнителя со сроком выполнения, включая закрытые
+func (s *Storage) CalendarTasks(ctx context.Context, userID int) (_ []CalendarTask, err error) {
+	ctx, end := startOp(ctx, "CalendarTasks")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT
+			t.id,
+			t.opened,
+			t.closed,
+			t.author_id,
+			t.assigned_id,
+			t.title,
+			t.content,
+			t.due,
+			t.priority,
+			COALESCE(a.name, ''),
+			COALESCE(u.name, ''),
+			ARRAY(
+				SELECT `+labelFullNameSQL+`
+				FROM tasks_labels as tl
+				INNER JOIN labels as l ON l.id = tl.label_id
+				WHERE tl.task_id = t.id
+				ORDER BY 1
+			),
+			COALESCE(e.modified, t.opened),
+			COALESCE(e.sequence, 0)
+		FROM tasks as t
+		LEFT JOIN users as a ON a.id = t.author_id
+		LEFT JOIN users as u ON u.id = t.assigned_id
+		LEFT JOIN LATERAL (
+			SELECT
+				max(created) as modified,
+				(count(*) FILTER (WHERE type <> $2) + sum(pruned))::int as sequence
+			FROM events
+			WHERE task_id = t.id
+		) as e ON true
+		WHERE
+			t.assigned_id = $1 AND
+			t.due <> 0
+		ORDER BY t.due, t.id;
+	`,
+		userID,
+		EventTaskCreated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []CalendarTask
+	for rows.Next() {
+		var t CalendarTask
+		err = rows.Scan(
+			&t.ID,
+			&t.Opened,
+			&t.Closed,
+			&t.AuthorID,
+			nullID{&t.AssignedID},
+			&t.Title,
+			&t.Content,
+			&t.Due,
+			&t.Priority,
+			&t.AuthorName,
+			&t.AssigneeName,
+			&t.Labels,
+			&t.Modified,
+			&t.Sequence,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+// ResetCalendarToken - выдает пользователю новый токен календарной ленты,
+// старая ссылка на ленту перестает работать
+func (s *Storage) ResetCalendarToken(ctx context.Context, userID int) (_ string, err error) {
+	ctx, end := startOp(ctx, "ResetCalendarToken")
+	defer end(&err)
+	b := make([]byte, 24)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	tag, err := s.db().Exec(ctx, `
+		UPDATE users
+		SET calendar_token = $1
+		WHERE id = $2;
+		`,
+		token,
+		userID,
+	)
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		return "", pgx.ErrNoRows
+	}
+	return token, nil
+}
+
+// UserByCalendarToken - находит активного пользователя по токену календарной ленты,
+// лента деактивированного пользователя недоступна
+func (s *Storage) UserByCalendarToken(ctx context.Context, token string) (_ *User, err error) {
+	ctx, end := startOp(ctx, "UserByCalendarToken")
+	defer end(&err)
+	user := &User{}
+	if token == "" {
+		return user, errors.New("пустой токен календаря")
+	}
+	row := s.db().QueryRow(ctx, `
+		SELECT id, name, email, locale, email_opt_out, deactivated
+		FROM users
+		WHERE calendar_token = $1 AND deactivated = 0;
+		`,
+		token,
+	)
+	err = scanUser(row, user)
+	if err != nil {
+		return user, err
+	}
+	return user, nil
+}
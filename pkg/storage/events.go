@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Канал Postgres, в который публикуются ID новых событий.
+const EventsChannel = "task_events"
+
+// Типы событий по задачам.
+const (
+	EventTaskCreated   = "task.created"
+	EventTaskUpdated   = "task.updated"
+	EventTaskRelabeled = "task.relabeled"
+	EventTaskDeleted   = "task.deleted"
+)
+
+// Event - событие изменения задачи.
+type Event struct {
+	ID       int64
+	Type     string
+	TaskID   int
+	Created  int64
+	Task     Task
+	Previous *Task `json:"Previous,omitempty"`
+	Labels   []int
+}
+
+// querier - общий интерфейс пула и транзакции, позволяет выполнять запросы в транзакции и без нее.
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// eventPayload - часть события, хранимая в колонке payload.
+type eventPayload struct {
+	Task     Task
+	Previous *Task `json:"Previous,omitempty"`
+	Labels   []int
+}
+
+// publishEvent - записывает событие в таблицу events и уведомляет слушателей через NOTIFY.
+// При вызове в транзакции уведомление будет доставлено только после ее фиксации.
+func publishEvent(ctx context.Context, q querier, eventType string, task *Task, previous *Task) error {
+	labels, err := taskLabelIDs(ctx, q, task.ID)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(eventPayload{
+		Task:     *task,
+		Previous: previous,
+		Labels:   labels,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(ctx, `
+		WITH e AS (
+			INSERT INTO events (type, task_id, payload)
+			VALUES ($1, $2, $3) RETURNING id
+		)
+		SELECT pg_notify($4, id::text) FROM e;
+		`,
+		eventType,
+		task.ID,
+		payload,
+		EventsChannel,
+	)
+	return err
+}
+
+// EventById - находит и возвращает событие по id
+func (s *Storage) EventById(ctx context.Context, id int64) (_ *Event, err error) {
+	ctx, end := startOp(ctx, "EventById")
+	defer end(&err)
+	e := &Event{}
+	err = scanEvent(s.db().QueryRow(ctx, `
+		SELECT id, type, task_id, created, payload
+		FROM events
+		WHERE id = $1;
+		`,
+		id,
+	), e)
+	return e, err
+}
+
+// EventsAfter - возвращает до limit событий с ID больше afterID в порядке ID
+func (s *Storage) EventsAfter(ctx context.Context, afterID int64, limit int) (_ []Event, err error) {
+	ctx, end := startOp(ctx, "EventsAfter")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT id, type, task_id, created, payload
+		FROM events
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2;
+		`,
+		afterID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		err = scanEvent(rows, &e)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// LastEventID - ID последнего события, 0 - событий нет
+func (s *Storage) LastEventID(ctx context.Context) (_ int64, err error) {
+	ctx, end := startOp(ctx, "LastEventID")
+	defer end(&err)
+	var id int64
+	err = s.db().QueryRow(ctx, "SELECT COALESCE(max(id), 0) FROM events;").Scan(&id)
+	return id, err
+}
+
+// DeleteEvents - удаляет события, созданные до before, кроме последнего события каждой
+// задачи: по нему календарная лента знает время изменения задачи. Количество удаленных
+// изменений переносится в pruned последнего события, чтобы SEQUENCE ленты не уменьшался
+func (s *Storage) DeleteEvents(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, end := startOp(ctx, "DeleteEvents")
+	defer end(&err)
+	tag, err := s.db().Exec(ctx, `
+		WITH old AS (
+			SELECT id, task_id, type, pruned
+			FROM events as e
+			WHERE
+				created < $1 AND
+				id < (SELECT max(id) FROM events WHERE task_id = e.task_id)
+		), folded AS (
+			UPDATE events as e
+			SET pruned = e.pruned + f.count
+			FROM (
+				SELECT task_id, (count(*) FILTER (WHERE type <> $2) + sum(pruned))::int as count
+				FROM old
+				GROUP BY task_id
+			) as f
+			WHERE e.id = (SELECT max(id) FROM events WHERE task_id = f.task_id)
+		)
+		DELETE FROM events
+		WHERE id IN (SELECT id FROM old);
+		`,
+		before.Unix(),
+		EventTaskCreated,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// scanEvent - сканирует строку результата в событие. Порядок колонок:
+// id, type, task_id, created, payload
+func scanEvent(row pgx.Row, e *Event) error {
+	var payload []byte
+	err := row.Scan(&e.ID, &e.Type, &e.TaskID, &e.Created, &payload)
+	if err != nil {
+		return err
+	}
+
+	var p eventPayload
+	err = json.Unmarshal(payload, &p)
+	if err != nil {
+		return err
+	}
+	e.Task = p.Task
+	e.Previous = p.Previous
+	e.Labels = p.Labels
+	return nil
+}
+
+// taskLabelIDs - возвращает ID меток задачи
+func taskLabelIDs(ctx context.Context, q querier, taskID int) ([]int, error) {
+	rows, err := q.Query(ctx, `
+		SELECT label_id
+		FROM tasks_labels
+		WHERE task_id = $1
+		ORDER BY label_id;
+	`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []int{}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, id)
+	}
+	return labels, rows.Err()
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+)
+
+// Состояния задачи в TaskFilter.
+const (
+	TaskStateOpen   = "open"
+	TaskStateClosed = "closed"
+)
+
+// TaskFilter - фильтр задач, нулевые значения полей означают "любой".
+type TaskFilter struct {
+	TaskID     int
+	AuthorID   int
+	AssigneeID int
+	LabelID    int
+	// TaskStateOpen или TaskStateClosed
+	State string
+}
+
+// Empty - фильтр не ограничивает выборку
+func (f TaskFilter) Empty() bool {
+	return f == TaskFilter{}
+}
+
+// taskFilterSQL - условие TaskFilter для таблицы tasks с псевдонимом t, параметры $1-$5 - args()
+const taskFilterSQL = `
+			($1 = 0 OR t.id = $1) AND
+			($2 = 0 OR t.author_id = $2) AND
+			($3 = 0 OR t.assigned_id = $3) AND
+			($4 = 0 OR EXISTS (
+				SELECT 1 FROM tasks_labels as tl WHERE tl.task_id = t.id AND tl.label_id = $4
+			)) AND
+			($5 = '' OR (COALESCE(t.closed, 0) = 0) = ($5 = 'open'))`
+
+// args - параметры taskFilterSQL
+func (f TaskFilter) args() []any {
+	return []any{f.TaskID, f.AuthorID, f.AssigneeID, f.LabelID, f.State}
+}
+
+// TaskDetails - задача с именами автора, исполнителя и меток.
+type TaskDetails struct {
+	Task
+	AuthorName   string
+	AssigneeName string
+	Labels       []string
+}
+
+// EachTaskDetails - вызывает fn для каждой задачи, подходящей под фильтр, не загружая весь результат в память.
+// Обход прекращается при первой ошибке fn.
+func (s *Storage) EachTaskDetails(ctx context.Context, filter TaskFilter, fn func(t *TaskDetails) error) (err error) {
+	ctx, end := startOp(ctx, "EachTaskDetails")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT
+			t.id,
+			t.opened,
+			t.closed,
+			t.author_id,
+			t.assigned_id,
+			t.title,
+			t.content,
+			t.due,
+			t.priority,
+			COALESCE(a.name, ''),
+			COALESCE(u.name, ''),
+			ARRAY(
+				SELECT `+labelFullNameSQL+`
+				FROM tasks_labels as tl
+				INNER JOIN labels as l ON l.id = tl.label_id
+				WHERE tl.task_id = t.id
+				ORDER BY 1
+			)
+		FROM tasks as t
+		LEFT JOIN users as a ON a.id = t.author_id
+		LEFT JOIN users as u ON u.id = t.assigned_id
+		WHERE`+taskFilterSQL+`
+		ORDER BY t.id;
+	`, filter.args()...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t TaskDetails
+		err = rows.Scan(
+			&t.ID,
+			&t.Opened,
+			&t.Closed,
+			&t.AuthorID,
+			nullID{&t.AssignedID},
+			&t.Title,
+			&t.Content,
+			&t.Due,
+			&t.Priority,
+			&t.AuthorName,
+			&t.AssigneeName,
+			&t.Labels,
+		)
+		if err != nil {
+			return err
+		}
+		err = fn(&t)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
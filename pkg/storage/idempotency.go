@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// IdempotentResponse - сохраненный ответ на запрос с ключом идемпотентности.
+type IdempotentResponse struct {
+	// Отпечаток запроса: метод, путь и тело
+	Fingerprint string
+	// Код ответа, 0 - запрос еще выполняется
+	Status int
+	Header map[string][]string
+	Body   []byte
+}
+
+// ClaimIdempotencyKey - занимает ключ идемпотентности для выполнения запроса с отпечатком
+// fingerprint. Ключ занимается, если его нет, срок его хранения ttl истек или запрос с тем же
+// отпечатком выполнялся дольше lock (экземпляр сервиса, скорее всего, остановился).
+// Занятый ключ помечается claim - идентификатором выполнения запроса, без которого ключ
+// нельзя сохранить или освободить. Возвращает nil, если ключ занят этим вызовом, иначе
+// сохраненную запись ключа. Вставка выполняется одним запросом, поэтому из одновременных
+// запросов ключ займет только один.
+func (s *Storage) ClaimIdempotencyKey(ctx context.Context, key, claim, fingerprint string, ttl, lock time.Duration) (_ *IdempotentResponse, err error) {
+	ctx, end := startOp(ctx, "ClaimIdempotencyKey")
+	defer end(&err)
+	var claimed bool
+	err = s.db().QueryRow(ctx, `
+		INSERT INTO idempotency_keys AS k (key, claim, fingerprint, expires, locked_until)
+		VALUES ($1, $5, $2, extract(epoch from now())::bigint + $3, extract(epoch from now())::bigint + $4)
+		ON CONFLICT (key) DO UPDATE SET
+			claim = EXCLUDED.claim,
+			fingerprint = EXCLUDED.fingerprint,
+			status = 0,
+			header = NULL,
+			body = NULL,
+			expires = EXCLUDED.expires,
+			locked_until = EXCLUDED.locked_until
+		WHERE k.expires <= extract(epoch from now())
+			OR (k.status = 0 AND k.locked_until <= extract(epoch from now()) AND k.fingerprint = EXCLUDED.fingerprint)
+		RETURNING TRUE;
+		`,
+		key,
+		fingerprint,
+		int64(ttl.Seconds()),
+		int64(lock.Seconds()),
+		claim,
+	).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	r := &IdempotentResponse{}
+	err = s.db().QueryRow(ctx, `
+		SELECT fingerprint, status, COALESCE(header, '{}'::jsonb), COALESCE(body, ''::bytea)
+		FROM idempotency_keys
+		WHERE key = $1;
+		`,
+		key,
+	).Scan(&r.Fingerprint, &r.Status, &r.Header, &r.Body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Ключ удалили между запросами: запрос с ним выполнялся и завершился ошибкой
+		return &IdempotentResponse{Fingerprint: fingerprint}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SaveIdempotentResponse - сохраняет ответ на запрос с ключом идемпотентности, если ключ
+// все еще занят claim: ключ, перехваченный повтором запроса, не перезаписывается
+func (s *Storage) SaveIdempotentResponse(ctx context.Context, key, claim string, r *IdempotentResponse) (err error) {
+	ctx, end := startOp(ctx, "SaveIdempotentResponse")
+	defer end(&err)
+	_, err = s.db().Exec(ctx, `
+		UPDATE idempotency_keys
+		SET status = $2, header = $3, body = $4
+		WHERE key = $1 AND claim = $5 AND status = 0;
+		`,
+		key,
+		r.Status,
+		r.Header,
+		r.Body,
+		claim,
+	)
+	return err
+}
+
+// ReleaseIdempotencyKey - освобождает ключ идемпотентности, запрос с которым не выполнен:
+// повтор запроса выполнит его заново. Ключ, перехваченный повтором, не освобождается
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, key, claim string) (err error) {
+	ctx, end := startOp(ctx, "ReleaseIdempotencyKey")
+	defer end(&err)
+	_, err = s.db().Exec(ctx, `DELETE FROM idempotency_keys WHERE key = $1 AND claim = $2 AND status = 0;`, key, claim)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys - удаляет ключи идемпотентности с истекшим сроком хранения
+func (s *Storage) DeleteExpiredIdempotencyKeys(ctx context.Context) (_ int64, err error) {
+	ctx, end := startOp(ctx, "DeleteExpiredIdempotencyKeys")
+	defer end(&err)
+	tag, err := s.db().Exec(ctx, `DELETE FROM idempotency_keys WHERE expires <= extract(epoch from now());`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ID пользователя по умолчанию, совпадает со значением по умолчанию колонки author_id.
+const DefaultUserID = 1
+
+// ImportTask - строка импорта задач. Автор, исполнитель и метки задаются по ID или по имени.
+type ImportTask struct {
+	Row  int
+	Task Task
+	// Имя автора, используется если Task.AuthorID не задан
+	Author string
+	// Имя исполнителя, используется если Task.AssignedID не задан. Без ID и имени
+	// задача создается без исполнителя
+	Assignee string
+	Labels   []string
+	// Ошибки, найденные при разборе строки
+	Errors []string
+}
+
+// ImportRowResult - результат импорта одной строки.
+type ImportRowResult struct {
+	Row    int
+	TaskID int
+	Errors []string `json:"Errors,omitempty"`
+}
+
+// ImportReport - отчет об импорте задач.
+type ImportReport struct {
+	DryRun        bool
+	Committed     bool
+	Imported      int
+	Failed        int
+	CreatedUsers  []string
+	CreatedLabels []string
+	Rows          []ImportRowResult
+}
+
+// nameIndex - поиск ID по имени; несколько записей с одинаковым именем дают неоднозначность
+type nameIndex map[string][]int
+
+// loadNameIndex - индекс записей таблицы по имени; nameSQL - выражение имени для строки l
+func loadNameIndex(ctx context.Context, q querier, table, nameSQL string) (nameIndex, error) {
+	rows, err := q.Query(ctx, "SELECT l.id, "+nameSQL+" FROM "+table+" as l ORDER BY l.id;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	index := nameIndex{}
+	for rows.Next() {
+		var id int
+		var name string
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+		index[name] = append(index[name], id)
+	}
+	return index, rows.Err()
+}
+
+// ImportTasks - создает задачи в одной транзакции. Пользователи и метки ищутся по имени,
+// при createMissing отсутствующие создаются. Если хотя бы одна строка содержит ошибку или
+// dryRun равен true, транзакция откатывается, а отчет показывает, что было бы сделано.
+func (s *Storage) ImportTasks(ctx context.Context, tasks []ImportTask, createMissing, dryRun bool) (_ *ImportReport, err error) {
+	ctx, end := startOp(ctx, "ImportTasks")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	users, err := loadNameIndex(ctx, tx, "users", "l.name")
+	if err != nil {
+		return nil, err
+	}
+	labels, err := loadNameIndex(ctx, tx, "labels", labelFullNameSQL)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: dryRun}
+
+	// resolve - находит ID по имени или создает запись
+	resolve := func(index nameIndex, table, kind, name string, created *[]string) (int, error) {
+		ids := index[name]
+		switch {
+		case len(ids) == 1:
+			return ids[0], nil
+		case len(ids) > 1:
+			return 0, fmt.Errorf("%s %q неоднозначен: найдено %d записей", kind, name, len(ids))
+		case !createMissing:
+			return 0, fmt.Errorf("%s %q не найден", kind, name)
+		}
+		var id int
+		var err error
+		if table == "labels" {
+			scope, labelName := SplitLabelName(name)
+			err = tx.QueryRow(ctx, "INSERT INTO labels (scope, name) VALUES ($1, $2) RETURNING id;", scope, labelName).Scan(&id)
+		} else {
+			err = tx.QueryRow(ctx, "INSERT INTO "+table+" (name) VALUES ($1) RETURNING id;", name).Scan(&id)
+		}
+		if err != nil {
+			return 0, err
+		}
+		index[name] = []int{id}
+		*created = append(*created, name)
+		return id, nil
+	}
+
+	// exists - проверяет наличие записи с заданным ID
+	exists := func(table string, id int) (bool, error) {
+		var found bool
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1);", id).Scan(&found)
+		return found, err
+	}
+
+	for _, it := range tasks {
+		result := ImportRowResult{Row: it.Row, Errors: append([]string(nil), it.Errors...)}
+		t := it.Task
+
+		if strings.TrimSpace(t.Title) == "" {
+			result.Errors = append(result.Errors, "не задан заголовок")
+		}
+
+		people := []struct {
+			id   *int
+			name string
+			kind string
+			// ID, если не заданы ни ID, ни имя
+			def int
+		}{
+			{&t.AuthorID, it.Author, "автор", DefaultUserID},
+			{&t.AssignedID, it.Assignee, "исполнитель", 0},
+		}
+		resolved := len(result.Errors)
+		for _, p := range people {
+			switch {
+			case *p.id != 0:
+				found, err := exists("users", *p.id)
+				if err != nil {
+					return nil, err
+				}
+				if !found {
+					result.Errors = append(result.Errors, fmt.Sprintf("%s с ID %d не найден", p.kind, *p.id))
+				}
+			case p.name != "":
+				id, err := resolve(users, "users", p.kind, p.name, &report.CreatedUsers)
+				if err != nil {
+					result.Errors = append(result.Errors, err.Error())
+				}
+				*p.id = id
+			default:
+				*p.id = p.def
+			}
+		}
+		// Исполнитель найден, но назначить на него задачу можно, только если он активен
+		if t.AssignedID != 0 && len(result.Errors) == resolved {
+			err = checkAssignee(ctx, tx, t.AssignedID)
+			if errors.Is(err, ErrInvalid) {
+				result.Errors = append(result.Errors, err.Error())
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		result.Errors = append(result.Errors, nameConflicts(it.Labels)...)
+		var labelIDs []int
+		for _, name := range it.Labels {
+			l := Label{Name: name}
+			normalizeLabel(&l)
+			if problems := checkLabel(&l); len(problems) > 0 {
+				result.Errors = append(result.Errors, fmt.Sprintf("метка %q: %s", name, strings.Join(problems, "; ")))
+				continue
+			}
+			id, err := resolve(labels, "labels", "метка", l.FullName(), &report.CreatedLabels)
+			if err != nil {
+				result.Errors = append(result.Errors, err.Error())
+				continue
+			}
+			labelIDs = append(labelIDs, id)
+		}
+
+		if len(result.Errors) > 0 {
+			report.Failed++
+			report.Rows = append(report.Rows, result)
+			continue
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO tasks (title, content, due, priority, closed, author_id, assigned_id)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0)) RETURNING id;
+			`,
+			t.Title,
+			t.Content,
+			t.Due,
+			t.Priority,
+			t.Closed,
+			t.AuthorID,
+			t.AssignedID,
+		).Scan(&result.TaskID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, labelID := range labelIDs {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO tasks_labels (task_id, label_id)
+				SELECT $1, $2
+				WHERE NOT EXISTS (
+					SELECT 1 FROM tasks_labels WHERE task_id = $1 AND label_id = $2
+				);`,
+				result.TaskID,
+				labelID,
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		thisTask, err := taskById(ctx, tx, result.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		err = publishEvent(ctx, tx, EventTaskCreated, thisTask, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Imported++
+		report.Rows = append(report.Rows, result)
+	}
+
+	if report.Failed > 0 || dryRun {
+		return report, nil
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Committed = true
+	if len(report.CreatedUsers) > 0 || len(report.CreatedLabels) > 0 {
+		s.invalidate(ctx, usersKey, labelsKey)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,575 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// LabelScopeSeparator - разделитель группы и названия в полном имени метки: priority::high
+const LabelScopeSeparator = "::"
+
+// ErrLabelExists - в группе уже есть метка с таким названием.
+var ErrLabelExists = errors.New("метка с таким названием уже есть в группе")
+
+// Цвет метки: #rrggbb
+var labelColor = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// addTaskLabelSQL - привязывает метку $2 к задаче $1. Метки той же группы снимаются
+// с задачи в том же запросе, поэтому у задачи остается не более одной метки из группы
+const addTaskLabelSQL = `
+	WITH l AS (
+		SELECT id, scope FROM labels WHERE id = $2
+	), replaced AS (
+		DELETE FROM tasks_labels as tl
+		USING labels as o, l
+		WHERE tl.task_id = $1 AND o.id = tl.label_id
+			AND l.scope <> '' AND o.scope = l.scope AND o.id <> l.id
+	)
+	INSERT INTO tasks_labels (task_id, label_id)
+	SELECT $1, $2
+	WHERE NOT EXISTS (
+		SELECT 1 FROM tasks_labels WHERE task_id = $1 AND label_id = $2
+	);`
+
+// labelFullNameSQL - полное имя метки l в запросах, как Label.FullName
+const labelFullNameSQL = `CASE WHEN l.scope = '' THEN l.name ELSE l.scope || '` + LabelScopeSeparator + `' || l.name END`
+
+// SplitLabelName - разбирает полное имя метки на группу и название по последнему "::",
+// поэтому группа сама может быть составной: area::backend::api
+func SplitLabelName(fullName string) (scope, name string) {
+	i := strings.LastIndex(fullName, LabelScopeSeparator)
+	if i < 0 {
+		return "", strings.TrimSpace(fullName)
+	}
+	return strings.TrimSpace(fullName[:i]), strings.TrimSpace(fullName[i+len(LabelScopeSeparator):])
+}
+
+// FullName - полное имя метки вместе с группой
+func (l Label) FullName() string {
+	if l.Scope == "" {
+		return l.Name
+	}
+	return l.Scope + LabelScopeSeparator + l.Name
+}
+
+// renameLabel - меняет название метки l. Пустая scope сохраняет группу метки, если
+// название не содержит группу
+func renameLabel(l *Label, scope, name string) {
+	if scope == "" {
+		scope, name = SplitLabelName(name)
+		if scope == "" {
+			scope = l.Scope
+		}
+	}
+	l.Scope, l.Name = scope, name
+}
+
+// normalizeLabel - приводит метку к хранимому виду: группа выделяется из названия, если
+// не задана отдельно, пробелы по краям убираются, цвет переводится в нижний регистр
+func normalizeLabel(l *Label) {
+	if l.Scope == "" {
+		l.Scope, l.Name = SplitLabelName(l.Name)
+	}
+	l.Scope = strings.TrimSpace(l.Scope)
+	l.Name = strings.TrimSpace(l.Name)
+	l.Color = strings.ToLower(strings.TrimSpace(l.Color))
+	l.Description = strings.TrimSpace(l.Description)
+}
+
+// checkLabel - нарушения правил метки
+func checkLabel(l *Label) []string {
+	var problems []string
+	if l.Name == "" {
+		problems = append(problems, "не задано название")
+	}
+	if strings.Contains(l.Name, LabelScopeSeparator) {
+		problems = append(problems, "название не может содержать "+LabelScopeSeparator)
+	}
+	if l.Color != "" && !labelColor.MatchString(l.Color) {
+		problems = append(problems, "цвет должен быть в формате #rrggbb")
+	}
+	return problems
+}
+
+// labelConflict - заменяет нарушение уникальности названия в группе на ErrLabelExists
+func labelConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrLabelExists
+	}
+	return err
+}
+
+// labelScopes - группы меток с заданными ID
+func labelScopes(ctx context.Context, q querier, ids []int) (map[int]string, error) {
+	rows, err := q.Query(ctx, "SELECT id, scope FROM labels WHERE id = ANY($1);", ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scopes := make(map[int]string, len(ids))
+	for rows.Next() {
+		var id int
+		var scope string
+		err = rows.Scan(&id, &scope)
+		if err != nil {
+			return nil, err
+		}
+		scopes[id] = scope
+	}
+	return scopes, rows.Err()
+}
+
+// scopeConflicts - группы, из которых в ids больше одной метки
+func scopeConflicts(ids []int, scopes map[int]string) []string {
+	seen := map[string]int{}
+	var conflicts []string
+	for _, id := range ids {
+		scope := scopes[id]
+		if scope == "" {
+			continue
+		}
+		if other, ok := seen[scope]; ok && other != id {
+			conflicts = append(conflicts, fmt.Sprintf("метки %d и %d из одной группы %s", other, id, scope))
+			continue
+		}
+		seen[scope] = id
+	}
+	return conflicts
+}
+
+// checkExclusive - проверяет, что среди меток ids не больше одной из каждой группы
+func checkExclusive(ctx context.Context, q querier, ids []int) error {
+	if len(ids) < 2 {
+		return nil
+	}
+	scopes, err := labelScopes(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+	return invalid(scopeConflicts(ids, scopes))
+}
+
+// scopeTaken - проверяет, что перенос метки id в группу scope не даст задаче две метки группы
+func scopeTaken(ctx context.Context, q querier, id int, scope string) error {
+	if scope == "" {
+		return nil
+	}
+	var tasks int
+	err := q.QueryRow(ctx, `
+		SELECT count(DISTINCT a.task_id)
+		FROM tasks_labels as a
+		INNER JOIN tasks_labels as b ON b.task_id = a.task_id AND b.label_id <> a.label_id
+		INNER JOIN labels as o ON o.id = b.label_id
+		WHERE a.label_id = $1 AND o.scope = $2;
+		`,
+		id,
+		scope,
+	).Scan(&tasks)
+	if err != nil {
+		return err
+	}
+	if tasks > 0 {
+		return fmt.Errorf("%w: у %d задач с меткой уже есть метка группы %s", ErrInvalid, tasks, scope)
+	}
+	return nil
+}
+
+// nameConflicts - группы, из которых в списке полных имен больше одной метки
+func nameConflicts(names []string) []string {
+	seen := map[string]string{}
+	var conflicts []string
+	for _, fullName := range names {
+		scope, name := SplitLabelName(fullName)
+		if scope == "" {
+			continue
+		}
+		if other, ok := seen[scope]; ok && other != name {
+			conflicts = append(conflicts, fmt.Sprintf("метки %s и %s из одной группы %s",
+				scope+LabelScopeSeparator+other, scope+LabelScopeSeparator+name, scope))
+			continue
+		}
+		seen[scope] = name
+	}
+	return conflicts
+}
+
+//-------------------Использование меток-------------------------
+
+// LabelUsage - метка с количеством задач.
+type LabelUsage struct {
+	Label
+	// Всего задач с меткой
+	Tasks int
+	// Открытых задач с меткой
+	OpenTasks int
+}
+
+// LabelScope - группа меток с количеством меток и задач. Метки без группы
+// собраны в группу с пустым названием.
+type LabelScope struct {
+	Scope     string
+	Labels    int
+	Tasks     int
+	OpenTasks int
+}
+
+// LabelsUsage - метки с количеством задач, отсортированные по группе и названию.
+// scope nil - все метки, иначе только метки группы *scope
+func (s *Storage) LabelsUsage(ctx context.Context, scope *string) (_ []LabelUsage, err error) {
+	ctx, end := startOp(ctx, "LabelsUsage")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT
+			l.id,
+			l.scope,
+			l.name,
+			l.color,
+			l.description,
+			count(t.id),
+			count(t.id) FILTER (WHERE COALESCE(t.closed, 0) = 0)
+		FROM labels as l
+		LEFT JOIN tasks_labels as tl ON tl.label_id = l.id
+		LEFT JOIN tasks as t ON t.id = tl.task_id
+		WHERE $1::text IS NULL OR l.scope = $1
+		GROUP BY l.id
+		ORDER BY l.scope, l.name;
+	`, scope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []LabelUsage
+	for rows.Next() {
+		var u LabelUsage
+		err = rows.Scan(
+			&u.ID,
+			&u.Scope,
+			&u.Name,
+			&u.Color,
+			&u.Description,
+			&u.Tasks,
+			&u.OpenTasks,
+		)
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// LabelScopes - группы меток с количеством меток и задач, отсортированные по названию
+func (s *Storage) LabelScopes(ctx context.Context) (_ []LabelScope, err error) {
+	ctx, end := startOp(ctx, "LabelScopes")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT
+			l.scope,
+			count(DISTINCT l.id),
+			count(DISTINCT t.id),
+			count(DISTINCT t.id) FILTER (WHERE COALESCE(t.closed, 0) = 0)
+		FROM labels as l
+		LEFT JOIN tasks_labels as tl ON tl.label_id = l.id
+		LEFT JOIN tasks as t ON t.id = tl.task_id
+		GROUP BY l.scope
+		ORDER BY l.scope;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scopes []LabelScope
+	for rows.Next() {
+		var sc LabelScope
+		err = rows.Scan(&sc.Scope, &sc.Labels, &sc.Tasks, &sc.OpenTasks)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, sc)
+	}
+	return scopes, rows.Err()
+}
+
+//-------------------Слияние и удаление меток-------------------------
+
+// ErrLabelInUse - метка привязана к задачам.
+var ErrLabelInUse = errors.New("метка привязана к задачам")
+
+// ChangeLabelMerge - вид записи журнала изменений о слиянии меток: before - удаленные
+// метки, after - целевая метка с количеством задач Tasks и Moved, как в LabelMergeReport
+const ChangeLabelMerge = "label.merge"
+
+// LabelMergeReport - отчет о слиянии меток.
+type LabelMergeReport struct {
+	DryRun    bool
+	Committed bool
+	Target    Label
+	// Метки, удаленные после переноса связей
+	Sources []Label
+	// Задач, у которых изменились метки
+	Tasks int
+	// Задач, получивших целевую метку; у остальных она уже была
+	Moved int
+}
+
+// DuplicateLabels - метки, полные имена которых совпадают без учета регистра.
+type DuplicateLabels struct {
+	// Полное имя в нижнем регистре
+	Key    string
+	Labels []LabelUsage
+}
+
+// unlinkLabels - отвязывает метки ids от всех задач и публикует событие task.relabeled
+// для каждой задачи, возвращает количество задач
+func unlinkLabels(ctx context.Context, q querier, ids []int) (int, error) {
+	rows, err := q.Query(ctx, `
+		DELETE FROM tasks_labels
+		WHERE label_id = ANY($1)
+		RETURNING task_id;
+		`,
+		ids,
+	)
+	if err != nil {
+		return 0, err
+	}
+	seen := map[int]bool{}
+	var taskIDs []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			taskIDs = append(taskIDs, id)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	sort.Ints(taskIDs)
+
+	for _, id := range taskIDs {
+		task, err := taskById(ctx, q, id)
+		if err != nil {
+			return 0, err
+		}
+		err = publishEvent(ctx, q, EventTaskRelabeled, task, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(taskIDs), nil
+}
+
+// MergeLabels - сливает метки sourceIDs в метку targetID в одной транзакции: задачи с
+// исходными метками получают целевую (без повторов), исходные метки удаляются, слияние
+// записывается в журнал изменений. Для каждой задачи с исходными метками публикуется
+// событие task.relabeled. Если у такой задачи есть другая метка группы целевой метки,
+// слияние отклоняется с ErrInvalid. При dryRun транзакция откатывается, а отчет
+// показывает, что было бы сделано.
+func (s *Storage) MergeLabels(ctx context.Context, targetID int, sourceIDs []int, dryRun bool) (_ *LabelMergeReport, err error) {
+	ctx, end := startOp(ctx, "MergeLabels")
+	defer end(&err)
+	var sources []int
+	seen := map[int]bool{}
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return nil, fmt.Errorf("%w: метка %d не может слиться сама с собой", ErrInvalid, id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			sources = append(sources, id)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("%w: не заданы исходные метки", ErrInvalid)
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка меток не дает параллельно привязать исходные метки к задачам
+	rows, err := tx.Query(ctx, `
+		SELECT id, scope, name, color, description
+		FROM labels
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE;
+		`,
+		append([]int{targetID}, sources...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	found := map[int]Label{}
+	for rows.Next() {
+		var l Label
+		err = scanLabel(rows, &l)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		found[l.ID] = l
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &LabelMergeReport{DryRun: dryRun}
+	for _, id := range append([]int{targetID}, sources...) {
+		l, ok := found[id]
+		if !ok {
+			return nil, fmt.Errorf("метка %d: %w", id, pgx.ErrNoRows)
+		}
+		if id == targetID {
+			report.Target = l
+		} else {
+			report.Sources = append(report.Sources, l)
+		}
+	}
+
+	if report.Target.Scope != "" {
+		var conflicts int
+		err = tx.QueryRow(ctx, `
+			SELECT count(DISTINCT tl.task_id)
+			FROM tasks_labels as tl
+			INNER JOIN tasks_labels as other ON other.task_id = tl.task_id
+			INNER JOIN labels as o ON o.id = other.label_id
+			WHERE tl.label_id = ANY($1) AND o.scope = $2 AND o.id <> $3 AND NOT o.id = ANY($1);
+			`,
+			sources,
+			report.Target.Scope,
+			targetID,
+		).Scan(&conflicts)
+		if err != nil {
+			return nil, err
+		}
+		if conflicts > 0 {
+			return nil, fmt.Errorf("%w: у %d задач уже есть другая метка группы %s", ErrInvalid, conflicts, report.Target.Scope)
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO tasks_labels (task_id, label_id)
+		SELECT DISTINCT tl.task_id, $1::integer
+		FROM tasks_labels as tl
+		WHERE tl.label_id = ANY($2) AND NOT EXISTS (
+			SELECT 1 FROM tasks_labels WHERE task_id = tl.task_id AND label_id = $1
+		);`,
+		targetID,
+		sources,
+	)
+	if err != nil {
+		return nil, err
+	}
+	report.Moved = int(tag.RowsAffected())
+
+	report.Tasks, err = unlinkLabels(ctx, tx, sources)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(ctx, "DELETE FROM labels WHERE id = ANY($1);", sources)
+	if err != nil {
+		return nil, err
+	}
+	err = recordChange(ctx, tx, ChangeLabelMerge, targetID, report.Sources, struct {
+		Target       Label
+		Tasks, Moved int
+	}{report.Target, report.Tasks, report.Moved})
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Committed = true
+
+	keys := []string{labelsKey, labelKey(targetID)}
+	for _, id := range sources {
+		keys = append(keys, labelKey(id))
+	}
+	s.invalidate(ctx, keys...)
+	return report, nil
+}
+
+// DuplicateLabels - группы меток, полные имена которых совпадают без учета регистра,
+// с количеством задач у каждой метки. Метки в группе отсортированы по ID
+func (s *Storage) DuplicateLabels(ctx context.Context) (_ []DuplicateLabels, err error) {
+	ctx, end := startOp(ctx, "DuplicateLabels")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT
+			lower(`+labelFullNameSQL+`),
+			l.id,
+			l.scope,
+			l.name,
+			l.color,
+			l.description,
+			count(t.id),
+			count(t.id) FILTER (WHERE COALESCE(t.closed, 0) = 0)
+		FROM labels as l
+		LEFT JOIN tasks_labels as tl ON tl.label_id = l.id
+		LEFT JOIN tasks as t ON t.id = tl.task_id
+		WHERE (lower(l.scope), lower(l.name)) IN (
+			SELECT lower(scope), lower(name)
+			FROM labels
+			GROUP BY 1, 2
+			HAVING count(*) > 1
+		)
+		GROUP BY l.id
+		ORDER BY 1, l.id;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []DuplicateLabels
+	for rows.Next() {
+		var key string
+		var u LabelUsage
+		err = rows.Scan(
+			&key,
+			&u.ID,
+			&u.Scope,
+			&u.Name,
+			&u.Color,
+			&u.Description,
+			&u.Tasks,
+			&u.OpenTasks,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) == 0 || groups[len(groups)-1].Key != key {
+			groups = append(groups, DuplicateLabels{Key: key})
+		}
+		g := &groups[len(groups)-1]
+		g.Labels = append(g.Labels, u)
+	}
+	return groups, rows.Err()
+}
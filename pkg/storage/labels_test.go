@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNormalizeLabel(t *testing.T) {
+	tests := []struct {
+		label, want Label
+		valid       bool
+	}{
+		{Label{Name: " bug "}, Label{Name: "bug"}, true},
+		{Label{Name: "priority::high"}, Label{Scope: "priority", Name: "high"}, true},
+		{Label{Name: "area::backend::api"}, Label{Scope: "area::backend", Name: "api"}, true},
+		{Label{Scope: "priority", Name: "low", Color: "#A0B1C2"}, Label{Scope: "priority", Name: "low", Color: "#a0b1c2"}, true},
+		{Label{Name: "priority::"}, Label{Scope: "priority"}, false},
+		{Label{Scope: "area", Name: "a::b"}, Label{Scope: "area", Name: "a::b"}, false},
+		{Label{Name: "bug", Color: "red"}, Label{Name: "bug", Color: "red"}, false},
+	}
+	for _, tt := range tests {
+		got := tt.label
+		normalizeLabel(&got)
+		problems := checkLabel(&got)
+		if got != tt.want || (len(problems) == 0) != tt.valid {
+			t.Errorf("%+v: получено %+v, %v, ожидалось %+v", tt.label, got, problems, tt.want)
+		}
+	}
+
+	if got := (Label{Scope: "priority", Name: "high"}).FullName(); got != "priority::high" {
+		t.Errorf("FullName = %q", got)
+	}
+}
+
+func TestRenameLabel(t *testing.T) {
+	current := Label{ID: 3, Scope: "priority", Name: "high", Color: "#ff0000", Description: "срочно"}
+	tests := []struct {
+		scope, name string
+		want        Label
+	}{
+		{"", "urgent", Label{ID: 3, Scope: "priority", Name: "urgent", Color: "#ff0000", Description: "срочно"}},
+		{"", "area::api", Label{ID: 3, Scope: "area", Name: "api", Color: "#ff0000", Description: "срочно"}},
+		{"severity", "high", Label{ID: 3, Scope: "severity", Name: "high", Color: "#ff0000", Description: "срочно"}},
+	}
+	for _, tt := range tests {
+		got := current
+		renameLabel(&got, tt.scope, tt.name)
+		if got != tt.want {
+			t.Errorf("%q, %q: получено %+v, ожидалось %+v", tt.scope, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewTaskLabels(t *testing.T) {
+	created := []Task{{ID: 10}, {ID: 11}, {ID: 12}}
+	linkTasks, linkLabels, taskLabels := newTaskLabels(created, [][]int{{5, 3, 5}, nil})
+	if fmt.Sprint(linkTasks, linkLabels) != "[10 10] [5 3]" {
+		t.Errorf("связи %v %v, ожидались [10 10] [5 3]", linkTasks, linkLabels)
+	}
+	payload, _ := json.Marshal(taskLabels)
+	if string(payload) != "[[3,5],[],[]]" {
+		t.Errorf("метки событий %s, ожидались [[3,5],[],[]]", payload)
+	}
+}
+
+func TestScopeConflicts(t *testing.T) {
+	scopes := map[int]string{1: "priority", 2: "priority", 3: "", 4: "area", 5: ""}
+	if conflicts := scopeConflicts([]int{1, 3, 4, 5, 1}, scopes); len(conflicts) != 0 {
+		t.Errorf("неожиданные конфликты: %v", conflicts)
+	}
+	if conflicts := scopeConflicts([]int{1, 4, 2}, scopes); len(conflicts) != 1 {
+		t.Errorf("ожидался конфликт группы priority, получено %v", conflicts)
+	}
+
+	if conflicts := nameConflicts([]string{"bug", "priority::high", "area::api", "priority::high"}); len(conflicts) != 0 {
+		t.Errorf("неожиданные конфликты: %v", conflicts)
+	}
+	if conflicts := nameConflicts([]string{"priority::high", "priority :: low"}); len(conflicts) != 1 {
+		t.Errorf("ожидался конфликт группы priority, получено %v", conflicts)
+	}
+	if err := invalid(scopeConflicts([]int{1, 2}, scopes)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("ожидалась ErrInvalid, получено %v", err)
+	}
+}
+
+func TestMergeLabelsInvalid(t *testing.T) {
+	s := &Storage{}
+	for _, sources := range [][]int{nil, {2, 1}} {
+		_, err := s.MergeLabels(context.Background(), 1, sources, false)
+		if !errors.Is(err, ErrInvalid) {
+			t.Errorf("источники %v: ожидалась ErrInvalid, получено %v", sources, err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// MailMessage - письмо в очереди отправки.
+type MailMessage struct {
+	ID     int
+	UserID int
+	To     string
+	// Ключ для исключения повторной постановки одного и того же письма
+	DedupeKey   string
+	Subject     string
+	Text        string
+	HTML        string
+	Attempts    int
+	NextAttempt int64
+	LastError   string
+	Sent        int64
+	Failed      bool
+	Created     int64
+}
+
+// EnqueueMail - ставит письмо в очередь. Письмо с уже существующим DedupeKey не ставится,
+// в этом случае возвращается false.
+func (s *Storage) EnqueueMail(ctx context.Context, m *MailMessage) (_ bool, err error) {
+	ctx, end := startOp(ctx, "EnqueueMail")
+	defer end(&err)
+	err = s.db().QueryRow(ctx, `
+		INSERT INTO mail_queue (user_id, to_addr, dedupe_key, subject, text_body, html_body)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (dedupe_key) DO NOTHING
+		RETURNING id, next_attempt, created;
+		`,
+		m.UserID,
+		m.To,
+		m.DedupeKey,
+		m.Subject,
+		m.Text,
+		m.HTML,
+	).Scan(&m.ID, &m.NextAttempt, &m.Created)
+
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ClaimMail - выбирает до limit писем, готовых к отправке, и откладывает их следующую попытку на lease,
+// чтобы другие экземпляры не взяли те же письма, пока идет отправка.
+func (s *Storage) ClaimMail(ctx context.Context, limit int, lease time.Duration) (_ []MailMessage, err error) {
+	ctx, end := startOp(ctx, "ClaimMail")
+	defer end(&err)
+	now := time.Now().Unix()
+	rows, err := s.db().Query(ctx, `
+		UPDATE mail_queue
+		SET next_attempt = $1
+		WHERE id IN (
+			SELECT id
+			FROM mail_queue
+			WHERE sent = 0 AND NOT failed AND next_attempt <= $2
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING
+			id,
+			user_id,
+			to_addr,
+			dedupe_key,
+			subject,
+			text_body,
+			html_body,
+			attempts,
+			next_attempt,
+			last_error,
+			sent,
+			failed,
+			created;
+	`,
+		now+int64(lease.Seconds()),
+		now,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var messages []MailMessage
+	for rows.Next() {
+		var m MailMessage
+		err = rows.Scan(
+			&m.ID,
+			&m.UserID,
+			&m.To,
+			&m.DedupeKey,
+			&m.Subject,
+			&m.Text,
+			&m.HTML,
+			&m.Attempts,
+			&m.NextAttempt,
+			&m.LastError,
+			&m.Sent,
+			&m.Failed,
+			&m.Created,
+		)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// MarkMailSent - отмечает письмо отправленным
+func (s *Storage) MarkMailSent(ctx context.Context, id int) (err error) {
+	ctx, end := startOp(ctx, "MarkMailSent")
+	defer end(&err)
+	_, err = s.db().Exec(ctx, `
+		UPDATE mail_queue
+		SET sent = extract(epoch from now()), attempts = attempts + 1, last_error = ''
+		WHERE id = $1;
+		`,
+		id,
+	)
+	return err
+}
+
+// MarkMailError - сохраняет ошибку отправки письма и время следующей попытки.
+// Если final равен true, письмо больше не отправляется.
+func (s *Storage) MarkMailError(ctx context.Context, id int, sendErr string, nextAttempt int64, final bool) (err error) {
+	ctx, end := startOp(ctx, "MarkMailError")
+	defer end(&err)
+	_, err = s.db().Exec(ctx, `
+		UPDATE mail_queue
+		SET attempts = attempts + 1, last_error = $1, next_attempt = $2, failed = $3
+		WHERE id = $4;
+		`,
+		sendErr,
+		nextAttempt,
+		final,
+		id,
+	)
+	return err
+}
+
+// DueTasks - возвращает открытые задачи со сроком в интервале [from, to)
+func (s *Storage) DueTasks(ctx context.Context, from, to int64) (_ []Task, err error) {
+	ctx, end := startOp(ctx, "DueTasks")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT
+			id,
+			opened,
+			closed,
+			author_id,
+			assigned_id,
+			title,
+			content,
+			due,
+			priority
+		FROM tasks
+		WHERE
+			closed = 0 AND
+			due >= $1 AND due < $2
+		ORDER BY due, id;
+	`,
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	for rows.Next() {
+		var t Task
+		err = scanTask(rows, &t)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+// OpenTasksByAssignee - возвращает открытые задачи исполнителя, сначала со сроком
+func (s *Storage) OpenTasksByAssignee(ctx context.Context, userID int) (_ []Task, err error) {
+	ctx, end := startOp(ctx, "OpenTasksByAssignee")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT
+			id,
+			opened,
+			closed,
+			author_id,
+			assigned_id,
+			title,
+			content,
+			due,
+			priority
+		FROM tasks
+		WHERE
+			closed = 0 AND
+			assigned_id = $1
+		ORDER BY due = 0, due, id;
+	`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []Task
+	for rows.Next() {
+		var t Task
+		err = scanTask(rows, &t)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+)
+
+// Типы уведомлений.
+const (
+	NotificationTaskAssigned = "task.assigned"
+	NotificationTaskClosed   = "task.closed"
+)
+
+// NotificationTypes - все типы уведомлений, на которые можно подписаться.
+var NotificationTypes = []string{
+	NotificationTaskAssigned,
+	NotificationTaskClosed,
+}
+
+// Notification - уведомление пользователя.
+type Notification struct {
+	ID        int
+	UserID    int
+	EventID   int64
+	Type      string
+	TaskID    int
+	TaskTitle string
+	Created   int64
+	Read      bool
+}
+
+// NotificationPref - настройка получения уведомлений одного типа.
+type NotificationPref struct {
+	UserID  int
+	Type    string
+	Enabled bool
+}
+
+// NewNotification - создает уведомление, если пользователь не отключил этот тип уведомлений.
+// Повторное уведомление по тому же событию не создается, поэтому метод можно безопасно
+// вызывать с каждого экземпляра сервера. Возвращает false, если уведомление не создано.
+func (s *Storage) NewNotification(ctx context.Context, n *Notification) (_ bool, err error) {
+	ctx, end := startOp(ctx, "NewNotification")
+	defer end(&err)
+	var id int
+	var created int64
+	err = s.db().QueryRow(ctx, `
+		INSERT INTO notifications (user_id, event_id, type, task_id, task_title)
+		SELECT $1, $2, $3, $4, $5
+		WHERE NOT EXISTS (
+			SELECT 1 FROM notification_prefs
+			WHERE user_id = $1 AND type = $3 AND NOT enabled
+		)
+		ON CONFLICT (user_id, event_id, type) DO NOTHING
+		RETURNING id, created;
+		`,
+		n.UserID,
+		n.EventID,
+		n.Type,
+		n.TaskID,
+		n.TaskTitle,
+	).Scan(&id, &created)
+
+	if err != nil {
+		if err.Error() == "no rows in result set" {
+			return false, nil
+		}
+		return false, err
+	}
+
+	n.ID = id
+	n.Created = created
+
+	return true, nil
+}
+
+// Notifications - возвращает уведомления пользователя, новые первыми
+func (s *Storage) Notifications(ctx context.Context, userID int, unreadOnly bool) (_ []Notification, err error) {
+	ctx, end := startOp(ctx, "Notifications")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT 
+			id,
+			user_id,
+			event_id,
+			type,
+			task_id,
+			task_title,
+			created,
+			read
+		FROM notifications
+		WHERE
+			user_id = $1 AND
+			(NOT $2 OR NOT read)
+		ORDER BY id DESC;
+	`,
+		userID,
+		unreadOnly,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var notifications []Notification
+	for rows.Next() {
+		var n Notification
+		err = rows.Scan(
+			&n.ID,
+			&n.UserID,
+			&n.EventID,
+			&n.Type,
+			&n.TaskID,
+			&n.TaskTitle,
+			&n.Created,
+			&n.Read,
+		)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkNotificationRead - отмечает уведомление прочитанным и возвращает его
+func (s *Storage) MarkNotificationRead(ctx context.Context, id int) (_ *Notification, err error) {
+	ctx, end := startOp(ctx, "MarkNotificationRead")
+	defer end(&err)
+	n := &Notification{}
+	err = s.db().QueryRow(ctx, `
+		UPDATE notifications
+		SET read = TRUE
+		WHERE id = $1
+		RETURNING id, user_id, event_id, type, task_id, task_title, created, read;
+		`,
+		id,
+	).Scan(&n.ID, &n.UserID, &n.EventID, &n.Type, &n.TaskID, &n.TaskTitle, &n.Created, &n.Read)
+
+	if err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
+
+// MarkAllNotificationsRead - отмечает прочитанными все уведомления пользователя, возвращает их количество
+func (s *Storage) MarkAllNotificationsRead(ctx context.Context, userID int) (_ int64, err error) {
+	ctx, end := startOp(ctx, "MarkAllNotificationsRead")
+	defer end(&err)
+	tag, err := s.db().Exec(ctx, `
+		UPDATE notifications
+		SET read = TRUE
+		WHERE user_id = $1 AND NOT read;
+		`,
+		userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// UnreadNotificationsCount - возвращает количество непрочитанных уведомлений пользователя
+func (s *Storage) UnreadNotificationsCount(ctx context.Context, userID int) (_ int, err error) {
+	ctx, end := startOp(ctx, "UnreadNotificationsCount")
+	defer end(&err)
+	var count int
+	err = s.db().QueryRow(ctx, `
+		SELECT count(*)
+		FROM notifications
+		WHERE user_id = $1 AND NOT read;
+		`,
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+// NotificationPrefs - возвращает настройки уведомлений пользователя по всем типам.
+// Типы без сохраненной настройки считаются включенными.
+func (s *Storage) NotificationPrefs(ctx context.Context, userID int) (_ []NotificationPref, err error) {
+	ctx, end := startOp(ctx, "NotificationPrefs")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT type, enabled
+		FROM notification_prefs
+		WHERE user_id = $1;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	stored := map[string]bool{}
+	for rows.Next() {
+		var t string
+		var enabled bool
+		err = rows.Scan(&t, &enabled)
+		if err != nil {
+			return nil, err
+		}
+		stored[t] = enabled
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	prefs := make([]NotificationPref, 0, len(NotificationTypes))
+	for _, t := range NotificationTypes {
+		enabled, ok := stored[t]
+		prefs = append(prefs, NotificationPref{
+			UserID:  userID,
+			Type:    t,
+			Enabled: !ok || enabled,
+		})
+	}
+	return prefs, nil
+}
+
+// SetNotificationPrefs - сохраняет настройки уведомлений пользователя и возвращает их полный список
+func (s *Storage) SetNotificationPrefs(ctx context.Context, userID int, prefs []NotificationPref) (_ []NotificationPref, err error) {
+	ctx, end := startOp(ctx, "SetNotificationPrefs")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, p := range prefs {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO notification_prefs (user_id, type, enabled)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled;
+			`,
+			userID,
+			p.Type,
+			p.Enabled,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.NotificationPrefs(ctx, userID)
+}
@@ -0,0 +1,317 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalid - измененная запись не проходит проверку.
+var ErrInvalid = errors.New("некорректные данные")
+
+// Виды записей журнала изменений.
+const (
+	ChangeTask  = "task"
+	ChangeUser  = "user"
+	ChangeLabel = "label"
+)
+
+// recordChange - записывает изменение в журнал changes. Вызывается в транзакции изменения,
+// поэтому запись в журнале есть тогда и только тогда, когда изменение сохранено
+func recordChange(ctx context.Context, q querier, entity string, id int, before, after any) error {
+	b, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	a, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `
+		INSERT INTO changes (entity, entity_id, before, after)
+		VALUES ($1, $2, $3, $4);
+		`,
+		entity,
+		id,
+		b,
+		a,
+	)
+	return err
+}
+
+// invalid - ошибка проверки с перечислением нарушений
+func invalid(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(problems, "; "))
+}
+
+// validateTask - проверяет задачу после изменения: ID и время создания не меняются
+func validateTask(before, after *Task) error {
+	var problems []string
+	if after.ID != before.ID {
+		problems = append(problems, "ID нельзя изменить")
+	}
+	if after.Opened != before.Opened {
+		problems = append(problems, "Opened нельзя изменить")
+	}
+	if strings.TrimSpace(after.Title) == "" {
+		problems = append(problems, "не задан заголовок")
+	}
+	if after.Priority < 0 || after.Priority > 3 {
+		problems = append(problems, "приоритет должен быть от 0 до 3")
+	}
+	if after.Due < 0 {
+		problems = append(problems, "некорректный срок")
+	}
+	if after.Closed < 0 {
+		problems = append(problems, "некорректное время закрытия")
+	}
+	if after.AuthorID <= 0 {
+		problems = append(problems, "не задан автор")
+	}
+	if after.AssignedID < 0 {
+		problems = append(problems, "некорректный исполнитель")
+	}
+	return invalid(problems)
+}
+
+// validateUser - проверяет пользователя после изменения
+func validateUser(before, after *User) error {
+	var problems []string
+	if after.ID != before.ID {
+		problems = append(problems, "ID нельзя изменить")
+	}
+	if strings.TrimSpace(after.Name) == "" {
+		problems = append(problems, "не задано имя")
+	}
+	if after.Email != "" && !strings.Contains(after.Email, "@") {
+		problems = append(problems, "некорректный адрес почты")
+	}
+	if after.Locale == "" {
+		problems = append(problems, "не задан язык")
+	}
+	if after.Deactivated != before.Deactivated {
+		problems = append(problems, "Deactivated изменяется только деактивацией пользователя")
+	}
+	return invalid(problems)
+}
+
+// validateLabel - проверяет метку после изменения
+func validateLabel(before, after *Label) error {
+	var problems []string
+	if after.ID != before.ID {
+		problems = append(problems, "ID нельзя изменить")
+	}
+	problems = append(problems, checkLabel(after)...)
+	return invalid(problems)
+}
+
+// PatchTask - изменяет задачу функцией apply в одной транзакции: задача блокируется,
+// apply получает ее копию, результат проверяется и сохраняется вместе с записью в журнале
+// изменений и событием task.updated. Ошибка apply возвращается без изменений, ошибка
+// проверки оборачивает ErrInvalid. Если apply ничего не изменил, ничего не записывается.
+func (s *Storage) PatchTask(ctx context.Context, id int, apply func(t *Task) error) (_ *Task, err error) {
+	ctx, end := startOp(ctx, "PatchTask")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "SELECT id FROM tasks WHERE id = $1 FOR UPDATE;", id)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := taskById(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	t := *previous
+	err = apply(&t)
+	if err != nil {
+		return nil, err
+	}
+	err = validateTask(previous, &t)
+	if err != nil {
+		return nil, err
+	}
+	if t == *previous {
+		return previous, nil
+	}
+	if t.AssignedID != previous.AssignedID {
+		err = checkAssignee(ctx, tx, t.AssignedID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE tasks
+		SET (title, content, closed, due, priority, author_id, assigned_id) = ($1, $2, $3, $4, $5, $6, NULLIF($7, 0))
+		WHERE
+			(id = $8);`,
+		t.Title,
+		t.Content,
+		t.Closed,
+		t.Due,
+		t.Priority,
+		t.AuthorID,
+		t.AssignedID,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	task, err := taskById(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = recordChange(ctx, tx, ChangeTask, id, previous, task)
+	if err != nil {
+		return nil, err
+	}
+	err = publishEvent(ctx, tx, EventTaskUpdated, task, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, taskKey(id))
+	return task, nil
+}
+
+// PatchUser - изменяет пользователя функцией apply в одной транзакции, как PatchTask
+func (s *Storage) PatchUser(ctx context.Context, id int, apply func(u *User) error) (_ *User, err error) {
+	ctx, end := startOp(ctx, "PatchUser")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	previous := &User{}
+	err = scanUser(tx.QueryRow(ctx, `
+		SELECT id, name, email, locale, email_opt_out, deactivated
+		FROM users
+		WHERE id = $1
+		FOR UPDATE;
+		`, id), previous)
+	if err != nil {
+		return nil, err
+	}
+	u := *previous
+	err = apply(&u)
+	if err != nil {
+		return nil, err
+	}
+	err = validateUser(previous, &u)
+	if err != nil {
+		return nil, err
+	}
+	if u == *previous {
+		return previous, nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET (name, email, locale, email_opt_out) = ($1, $2, $3, $4)
+		WHERE
+			(id = $5);`,
+		u.Name,
+		u.Email,
+		u.Locale,
+		u.EmailOptOut,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = recordChange(ctx, tx, ChangeUser, id, previous, &u)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, usersKey, userKey(id))
+	return &u, nil
+}
+
+// PatchLabel - изменяет метку функцией apply в одной транзакции, как PatchTask. Перенос
+// метки в группу, метка которой уже есть у ее задач, - ошибка проверки
+func (s *Storage) PatchLabel(ctx context.Context, id int, apply func(l *Label) error) (_ *Label, err error) {
+	ctx, end := startOp(ctx, "PatchLabel")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	previous := &Label{}
+	err = scanLabel(tx.QueryRow(ctx, `
+		SELECT id, scope, name, color, description
+		FROM labels
+		WHERE id = $1
+		FOR UPDATE;
+		`, id), previous)
+	if err != nil {
+		return nil, err
+	}
+	l := *previous
+	err = apply(&l)
+	if err != nil {
+		return nil, err
+	}
+	normalizeLabel(&l)
+	err = validateLabel(previous, &l)
+	if err != nil {
+		return nil, err
+	}
+	if l == *previous {
+		return previous, nil
+	}
+	if l.Scope != previous.Scope {
+		err = scopeTaken(ctx, tx, id, l.Scope)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE labels
+		SET (scope, name, color, description) = ($1, $2, $3, $4)
+		WHERE
+			(id = $5);`,
+		l.Scope,
+		l.Name,
+		l.Color,
+		l.Description,
+		id,
+	)
+	if err != nil {
+		return nil, labelConflict(err)
+	}
+	err = recordChange(ctx, tx, ChangeLabel, id, previous, &l)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, labelsKey, labelKey(id))
+	return &l, nil
+}
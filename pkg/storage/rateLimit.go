@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// TakeRateToken - пополняет корзину ограничения запросов key со скоростью rate маркеров
+// в секунду до burst и берет из нее маркер, если он есть. Новая корзина создается полной.
+// Возвращает остаток маркеров и взят ли маркер. Пополнение и списание выполняются
+// одним запросом, поэтому экземпляры сервиса не списывают один маркер дважды.
+func (s *Storage) TakeRateToken(ctx context.Context, key string, rate float64, burst int) (_ float64, _ bool, err error) {
+	ctx, end := startOp(ctx, "TakeRateToken")
+	defer end(&err)
+	var tokens float64
+	var allowed bool
+	err = s.db().QueryRow(ctx, `
+		INSERT INTO rate_limits AS r (key, tokens, updated, allowed)
+		VALUES ($1, $3::float8 - 1, extract(epoch from clock_timestamp()), TRUE)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = LEAST($3, r.tokens + (EXCLUDED.updated - r.updated) * $2)
+				- CASE WHEN LEAST($3, r.tokens + (EXCLUDED.updated - r.updated) * $2) >= 1 THEN 1 ELSE 0 END,
+			allowed = LEAST($3, r.tokens + (EXCLUDED.updated - r.updated) * $2) >= 1,
+			updated = EXCLUDED.updated
+		RETURNING tokens, allowed;
+		`,
+		key,
+		rate,
+		burst,
+	).Scan(&tokens, &allowed)
+	if err != nil {
+		return 0, false, err
+	}
+	return tokens, allowed, nil
+}
+
+// DeleteRateBuckets - удаляет корзины ограничения запросов, к которым не обращались после before
+func (s *Storage) DeleteRateBuckets(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, end := startOp(ctx, "DeleteRateBuckets")
+	defer end(&err)
+	tag, err := s.db().Exec(ctx, `DELETE FROM rate_limits WHERE updated < $1;`, float64(before.UnixMilli())/1000)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
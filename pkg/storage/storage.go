@@ -3,12 +3,20 @@ package storage
 import (
 	"TaskManager/pkg/logger"
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 // Хранилище данных.
 type Storage struct {
 	DB *pgxpool.Pool
+	// Кэш чтения, nil - отключен
+	cache *cache
 }
 
 // Конструктор, принимает строку подключения к БД.
@@ -25,44 +33,143 @@ func New(constr string) (*Storage, error) {
 
 // Задача.
 type Task struct {
-	ID         int
-	Opened     int64
-	Closed     int64
-	AuthorID   int
+	ID       int
+	Opened   int64
+	Closed   int64
+	AuthorID int
+	// Исполнитель, 0 - задача без исполнителя (NULL в БД)
 	AssignedID int
 	Title      string
 	Content    string
+	// Срок выполнения (unix time), 0 - без срока
+	Due int64
+	// Приоритет: 0 - не задан, 1 - высокий, 2 - средний, 3 - низкий
+	Priority int
+}
+
+// scanTask - сканирует строку результата в задачу. Порядок колонок:
+// id, opened, closed, author_id, assigned_id, title, content, due, priority
+func scanTask(row pgx.Row, t *Task) error {
+	return row.Scan(
+		&t.ID,
+		&t.Opened,
+		&t.Closed,
+		&t.AuthorID,
+		nullID{&t.AssignedID},
+		&t.Title,
+		&t.Content,
+		&t.Due,
+		&t.Priority,
+	)
+}
+
+// nullID - приемник колонки-ссылки, допускающей NULL: NULL сканируется как 0
+type nullID struct {
+	id *int
+}
+
+func (n nullID) DecodeBinary(ci *pgtype.ConnInfo, src []byte) error {
+	var v pgtype.Int4
+	err := v.DecodeBinary(ci, src)
+	*n.id = int(v.Int)
+	return err
+}
+
+func (n nullID) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
+	var v pgtype.Int4
+	err := v.DecodeText(ci, src)
+	*n.id = int(v.Int)
+	return err
 }
 
 // Пользователь
 type User struct {
 	ID   int
 	Name string
+	// Адрес для почтовых уведомлений, пустой - уведомления не отправляются
+	Email string
+	// Язык писем: ru или en
+	Locale string
+	// Пользователь отказался от почтовых уведомлений
+	EmailOptOut bool
+	// Время деактивации (unix time), 0 - пользователь активен. Деактивированному
+	// пользователю нельзя назначать задачи, уведомления ему не отправляются
+	Deactivated int64
+}
+
+// scanUser - сканирует строку результата в пользователя. Порядок колонок:
+// id, name, email, locale, email_opt_out, deactivated
+func scanUser(row pgx.Row, u *User) error {
+	return row.Scan(
+		&u.ID,
+		&u.Name,
+		&u.Email,
+		&u.Locale,
+		&u.EmailOptOut,
+		&u.Deactivated,
+	)
 }
 
 // Метки
 type Label struct {
-	ID   int
+	ID int
+	// Группа метки, например priority для priority::high. У задачи может быть не более одной
+	// метки из каждой группы, пустая группа - метка без группы
+	Scope string
+	// Название, уникальное в группе
 	Name string
+	// Цвет в формате #rrggbb, пустой - цвет по умолчанию
+	Color       string
+	Description string
+}
+
+// scanLabel - сканирует строку результата в метку. Порядок колонок:
+// id, scope, name, color, description
+func scanLabel(row pgx.Row, l *Label) error {
+	return row.Scan(
+		&l.ID,
+		&l.Scope,
+		&l.Name,
+		&l.Color,
+		&l.Description,
+	)
+}
+
+// Page - страница списка: записи с ID больше After по возрастанию ID, не более Limit (0 - все).
+type Page struct {
+	After int
+	Limit int
 }
 
 // -------------------Метки-------------------------
 
-// NewLabel - создание новой метки, возвращает все поля новой метки
-func (s *Storage) NewLabel(label *Label) error {
+// NewLabel - создание новой метки, возвращает все поля новой метки. Группа выделяется
+// из названия вида priority::high, если не задана отдельно
+func (s *Storage) NewLabel(ctx context.Context, label *Label) (err error) {
+	ctx, end := startOp(ctx, "NewLabel")
+	defer end(&err)
+	normalizeLabel(label)
+	err = invalid(checkLabel(label))
+	if err != nil {
+		return err
+	}
 	var id int
-	err := s.DB.QueryRow(context.Background(), `
-		INSERT INTO labels (name)
-		VALUES ($1) RETURNING id;
+	err = s.db().QueryRow(ctx, `
+		INSERT INTO labels (scope, name, color, description)
+		VALUES ($1, $2, $3, $4) RETURNING id;
 		`,
+		label.Scope,
 		label.Name,
+		label.Color,
+		label.Description,
 	).Scan(&id)
 
 	if err != nil {
-		return err
+		return labelConflict(err)
 	}
+	s.invalidate(ctx, labelsKey)
 
-	thisLabel, err := s.LabelById(id)
+	thisLabel, err := s.LabelById(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -73,41 +180,60 @@ func (s *Storage) NewLabel(label *Label) error {
 }
 
 // LabelById - находит и возвращает метку по id
-func (s *Storage) LabelById(id int) (*Label, error) {
-	label := &Label{}
-	err := s.DB.QueryRow(context.Background(), `
-		SELECT id, name 
-		FROM labels
-		WHERE id = $1;
-		`,
-		id,
-	).Scan(&label.ID, &label.Name)
+func (s *Storage) LabelById(ctx context.Context, id int) (_ *Label, err error) {
+	ctx, end := startOp(ctx, "LabelById")
+	defer end(&err)
+	label, err := cached(s, labelKey(id), func() (Label, error) {
+		var label Label
+		err := scanLabel(s.db().QueryRow(ctx, `
+			SELECT id, scope, name, color, description
+			FROM labels
+			WHERE id = $1;
+			`,
+			id,
+		), &label)
+		return label, err
+	})
 
 	if err != nil {
-		return label, err
+		return &label, err
 	}
 
-	return label, nil
+	return &label, nil
 }
 
 // AllLabels - Возвращает все метки
-func (s *Storage) AllLabels() ([]Label, error) {
-	rows, err := s.DB.Query(context.Background(), `
+func (s *Storage) AllLabels(ctx context.Context) (_ []Label, err error) {
+	ctx, end := startOp(ctx, "AllLabels")
+	defer end(&err)
+	return cachedSlice(s, labelsKey, func() ([]Label, error) {
+		return s.LabelsPage(ctx, Page{})
+	})
+}
+
+// LabelsPage - возвращает страницу меток
+func (s *Storage) LabelsPage(ctx context.Context, p Page) (_ []Label, err error) {
+	ctx, end := startOp(ctx, "LabelsPage")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
 		SELECT 
 			id,
-			name
+			scope,
+			name,
+			color,
+			description
 		FROM labels
-		ORDER BY id;
-	`)
+		WHERE id > $1
+		ORDER BY id
+		LIMIT NULLIF($2, 0);
+	`, p.After, p.Limit)
 	if err != nil {
 		return nil, err
 	}
 	var labels []Label
 	for rows.Next() {
 		var l Label
-		err = rows.Scan(
-			&l.ID,
-			&l.Name)
+		err = scanLabel(rows, &l)
 		if err != nil {
 			return nil, err
 		}
@@ -119,49 +245,80 @@ func (s *Storage) AllLabels() ([]Label, error) {
 	return labels, rows.Err()
 }
 
-// UpdateLabel - обновляет метку и возвращает уже обновленную модель
-func (s *Storage) UpdateLabel(l *Label) error {
-	_, err := s.DB.Exec(context.Background(), `
-		UPDATE labels
-		SET name = $1
-		WHERE
-			(id = $2);`,
-		l.Name,
-		l.ID,
-	)
-
-	if err != nil {
-		logger.Error("Ошибка при обновлении метки: %s", err.Error())
-		return err
-	}
-
-	thisLabel, err := s.LabelById(l.ID)
+// UpdateLabel - переименовывает метку и возвращает уже обновленную модель. Пустая группа
+// означает, что группа не передана: название вида группа::название переносит метку в группу,
+// иначе группа сохраняется. Цвет и описание не меняются, их меняет PatchLabel. Проверка
+// и журнал изменений те же, что у PatchLabel
+func (s *Storage) UpdateLabel(ctx context.Context, l *Label) (err error) {
+	ctx, end := startOp(ctx, "UpdateLabel")
+	defer end(&err)
+	thisLabel, err := s.PatchLabel(ctx, l.ID, func(current *Label) error {
+		renameLabel(current, l.Scope, l.Name)
+		return nil
+	})
 	if err != nil {
+		logger.ErrorContext(ctx, "Ошибка при обновлении метки: %s", err.Error())
 		return err
 	}
 
 	*l = *thisLabel
 
-	return err
+	return nil
 }
 
-// DeleteLabel - удаляет метку по ее ID и возвращает удаленную запись
-func (s *Storage) DeleteLabel(id int) (*Label, error) {
-	thisLabel, err := s.LabelById(id)
+// DeleteLabel - удаляет метку по ее ID и возвращает удаленную запись. Метку, привязанную
+// к задачам, при force сначала отвязывает от них с событием task.relabeled для каждой
+// задачи, без force возвращает ErrLabelInUse
+func (s *Storage) DeleteLabel(ctx context.Context, id int, force bool) (_ *Label, err error) {
+	ctx, end := startOp(ctx, "DeleteLabel")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return &Label{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка метки не дает параллельно привязать ее к задаче
+	thisLabel := &Label{}
+	err = scanLabel(tx.QueryRow(ctx, `
+		SELECT id, scope, name, color, description
+		FROM labels
+		WHERE id = $1
+		FOR UPDATE;
+		`, id), thisLabel)
 	if err != nil {
 		return thisLabel, err
 	}
-	_, err = s.DB.Exec(context.Background(), `
+
+	if force {
+		_, err = unlinkLabels(ctx, tx, []int{id})
+	} else {
+		var tasks int
+		err = tx.QueryRow(ctx, "SELECT count(*) FROM tasks_labels WHERE label_id = $1;", id).Scan(&tasks)
+		if err == nil && tasks > 0 {
+			err = fmt.Errorf("%w: %d задач, удалите с force или слейте с другой меткой", ErrLabelInUse, tasks)
+		}
+	}
+	if err != nil {
+		return thisLabel, err
+	}
+
+	_, err = tx.Exec(ctx, `
 		DELETE FROM labels
 		WHERE
 			(id = $1);`,
 		id,
 	)
+	if err != nil {
+		logger.ErrorContext(ctx, "Ошибка при удалении метки: %s", err.Error())
+		return thisLabel, err
+	}
 
+	err = tx.Commit(ctx)
 	if err != nil {
-		logger.Error("Ошибка при обновлении метки: %s", err.Error())
 		return thisLabel, err
 	}
+	s.invalidate(ctx, labelsKey, labelKey(id))
 
 	return thisLabel, nil
 }
@@ -169,20 +326,25 @@ func (s *Storage) DeleteLabel(id int) (*Label, error) {
 //-------------------Пользователи-------------------------
 
 // NewUser - создание нового пользователя, возвращает все поля нового пользователя
-func (s *Storage) NewUser(user *User) error {
+func (s *Storage) NewUser(ctx context.Context, user *User) (err error) {
+	ctx, end := startOp(ctx, "NewUser")
+	defer end(&err)
 	var id int
-	err := s.DB.QueryRow(context.Background(), `
-		INSERT INTO users (name)
-		VALUES ($1) RETURNING id;
+	err = s.db().QueryRow(ctx, `
+		INSERT INTO users (name, email, locale)
+		VALUES ($1, $2, COALESCE(NULLIF($3, ''), 'ru')) RETURNING id;
 		`,
 		user.Name,
+		user.Email,
+		user.Locale,
 	).Scan(&id)
 
 	if err != nil {
 		return err
 	}
+	s.invalidate(ctx, usersKey)
 
-	thisUser, err := s.UserById(id)
+	thisUser, err := s.UserById(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -193,41 +355,61 @@ func (s *Storage) NewUser(user *User) error {
 }
 
 // UserById - находит и возвращает пользователя по id
-func (s *Storage) UserById(id int) (*User, error) {
-	user := &User{}
-	err := s.DB.QueryRow(context.Background(), `
-		SELECT id, name 
-		FROM users
-		WHERE id = $1;
-		`,
-		id,
-	).Scan(&user.ID, &user.Name)
+func (s *Storage) UserById(ctx context.Context, id int) (_ *User, err error) {
+	ctx, end := startOp(ctx, "UserById")
+	defer end(&err)
+	user, err := cached(s, userKey(id), func() (User, error) {
+		var user User
+		row := s.db().QueryRow(ctx, `
+			SELECT id, name, email, locale, email_opt_out, deactivated
+			FROM users
+			WHERE id = $1;
+			`,
+			id,
+		)
+		return user, scanUser(row, &user)
+	})
 
 	if err != nil {
-		return user, err
+		return &user, err
 	}
 
-	return user, nil
+	return &user, nil
 }
 
 // AllUsers - Возвращает всех пользователей
-func (s *Storage) AllUsers() ([]User, error) {
-	rows, err := s.DB.Query(context.Background(), `
+func (s *Storage) AllUsers(ctx context.Context) (_ []User, err error) {
+	ctx, end := startOp(ctx, "AllUsers")
+	defer end(&err)
+	return cachedSlice(s, usersKey, func() ([]User, error) {
+		return s.UsersPage(ctx, Page{})
+	})
+}
+
+// UsersPage - возвращает страницу пользователей
+func (s *Storage) UsersPage(ctx context.Context, p Page) (_ []User, err error) {
+	ctx, end := startOp(ctx, "UsersPage")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
 		SELECT 
 			id,
-			name
+			name,
+			email,
+			locale,
+			email_opt_out,
+			deactivated
 		FROM users
-		ORDER BY id;
-	`)
+		WHERE id > $1
+		ORDER BY id
+		LIMIT NULLIF($2, 0);
+	`, p.After, p.Limit)
 	if err != nil {
 		return nil, err
 	}
 	var users []User
 	for rows.Next() {
 		var u User
-		err = rows.Scan(
-			&u.ID,
-			&u.Name)
+		err = scanUser(rows, &u)
 		if err != nil {
 			return nil, err
 		}
@@ -240,8 +422,10 @@ func (s *Storage) AllUsers() ([]User, error) {
 }
 
 // UpdateUser - обновляет пользователя и возвращает уже обновленную модель
-func (s *Storage) UpdateUser(u *User) error {
-	_, err := s.DB.Exec(context.Background(), `
+func (s *Storage) UpdateUser(ctx context.Context, u *User) (err error) {
+	ctx, end := startOp(ctx, "UpdateUser")
+	defer end(&err)
+	_, err = s.db().Exec(ctx, `
 		UPDATE users
 		SET name = $1
 		WHERE
@@ -251,11 +435,12 @@ func (s *Storage) UpdateUser(u *User) error {
 	)
 
 	if err != nil {
-		logger.Error("Ошибка при обновлении пользователя: %s", err.Error())
+		logger.ErrorContext(ctx, "Ошибка при обновлении пользователя: %s", err.Error())
 		return err
 	}
+	s.invalidate(ctx, usersKey, userKey(u.ID))
 
-	thisUser, err := s.UserById(u.ID)
+	thisUser, err := s.UserById(ctx, u.ID)
 	if err != nil {
 		return err
 	}
@@ -265,23 +450,88 @@ func (s *Storage) UpdateUser(u *User) error {
 	return err
 }
 
-// DeleteUser - удаляет пользователя по его ID и возвращает удаленную запись
-func (s *Storage) DeleteUser(id int) (*User, error) {
-	thisUser, err := s.UserById(id)
+// UpdateUserMail - обновляет почтовые настройки пользователя и возвращает уже обновленную модель
+func (s *Storage) UpdateUserMail(ctx context.Context, u *User) (err error) {
+	ctx, end := startOp(ctx, "UpdateUserMail")
+	defer end(&err)
+	_, err = s.db().Exec(ctx, `
+		UPDATE users
+		SET (email, locale, email_opt_out) = ($1, COALESCE(NULLIF($2, ''), locale), $3)
+		WHERE
+			(id = $4);`,
+		u.Email,
+		u.Locale,
+		u.EmailOptOut,
+		u.ID,
+	)
+
+	if err != nil {
+		logger.ErrorContext(ctx, "Ошибка при обновлении пользователя: %s", err.Error())
+		return err
+	}
+	s.invalidate(ctx, usersKey, userKey(u.ID))
+
+	thisUser, err := s.UserById(ctx, u.ID)
+	if err != nil {
+		return err
+	}
+
+	*u = *thisUser
+
+	return nil
+}
+
+// DeleteUser - удаляет пользователя по его ID вместе с его уведомлениями, настройками
+// уведомлений и письмами и возвращает удаленную запись. Пользователя, который автор или
+// исполнитель задач, удалить нельзя (ErrUserInUse), его нужно деактивировать через OffboardUser
+func (s *Storage) DeleteUser(ctx context.Context, id int) (_ *User, err error) {
+	ctx, end := startOp(ctx, "DeleteUser")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return &User{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка пользователя не дает параллельно назначить на него задачу
+	thisUser := &User{}
+	err = scanUser(tx.QueryRow(ctx, `
+		SELECT id, name, email, locale, email_opt_out, deactivated
+		FROM users
+		WHERE id = $1
+		FOR UPDATE;
+		`, id), thisUser)
 	if err != nil {
 		return thisUser, err
 	}
-	_, err = s.DB.Exec(context.Background(), `
-		DELETE FROM users
-		WHERE
-			(id = $1);`,
-		id,
-	)
 
+	var n int
+	err = tx.QueryRow(ctx, "SELECT count(*) FROM tasks WHERE author_id = $1 OR assigned_id = $1;", id).Scan(&n)
 	if err != nil {
-		logger.Error("Ошибка при обновлении пользователя: %s", err.Error())
 		return thisUser, err
 	}
+	if n > 0 {
+		return thisUser, fmt.Errorf("%w: %d задач, деактивируйте пользователя вместо удаления", ErrUserInUse, n)
+	}
+
+	for _, sql := range []string{
+		"DELETE FROM notification_prefs WHERE user_id = $1;",
+		"DELETE FROM notifications WHERE user_id = $1;",
+		"DELETE FROM mail_queue WHERE user_id = $1;",
+		"DELETE FROM users WHERE id = $1;",
+	} {
+		_, err = tx.Exec(ctx, sql, id)
+		if err != nil {
+			logger.ErrorContext(ctx, "Ошибка при удалении пользователя: %s", err.Error())
+			return thisUser, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return thisUser, err
+	}
+	s.invalidate(ctx, usersKey, userKey(id))
 
 	return thisUser, nil
 }
@@ -289,9 +539,20 @@ func (s *Storage) DeleteUser(id int) (*User, error) {
 //-------------------Задачи-------------------------
 
 // TaskById - возвращает задачу по ее id
-func (s *Storage) TaskById(taskID int) (*Task, error) {
+func (s *Storage) TaskById(ctx context.Context, taskID int) (_ *Task, err error) {
+	ctx, end := startOp(ctx, "TaskById")
+	defer end(&err)
+	t, err := cached(s, taskKey(taskID), func() (Task, error) {
+		t, err := taskById(ctx, s.db(), taskID)
+		return *t, err
+	})
+	return &t, err
+}
+
+// taskById - возвращает задачу по ее id, запрос выполняется в q (пул или транзакция)
+func taskById(ctx context.Context, q querier, taskID int) (*Task, error) {
 	t := Task{}
-	row := s.DB.QueryRow(context.Background(), `
+	row := q.QueryRow(ctx, `
 		SELECT 
 			id,
 			opened,
@@ -299,22 +560,16 @@ func (s *Storage) TaskById(taskID int) (*Task, error) {
 			author_id,
 			assigned_id,
 			title,
-			content
+			content,
+			due,
+			priority
 		FROM tasks
 		WHERE
 			id = $1
 		ORDER BY id;
 	`, taskID)
 
-	err := row.Scan(
-		&t.ID,
-		&t.Opened,
-		&t.Closed,
-		&t.AuthorID,
-		&t.AssignedID,
-		&t.Title,
-		&t.Content,
-	)
+	err := scanTask(row, &t)
 	if err != nil {
 		return &t, err
 	}
@@ -323,8 +578,17 @@ func (s *Storage) TaskById(taskID int) (*Task, error) {
 }
 
 // AllTasks - Возвращает все задачи
-func (s *Storage) AllTasks() ([]Task, error) {
-	rows, err := s.DB.Query(context.Background(), `
+func (s *Storage) AllTasks(ctx context.Context) (_ []Task, err error) {
+	ctx, end := startOp(ctx, "AllTasks")
+	defer end(&err)
+	return s.TasksPage(ctx, Page{})
+}
+
+// TasksPage - возвращает страницу задач
+func (s *Storage) TasksPage(ctx context.Context, p Page) (_ []Task, err error) {
+	ctx, end := startOp(ctx, "TasksPage")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
 		SELECT 
 			id,
 			opened,
@@ -332,10 +596,14 @@ func (s *Storage) AllTasks() ([]Task, error) {
 			author_id,
 			assigned_id,
 			title,
-			content
+			content,
+			due,
+			priority
 		FROM tasks
-		ORDER BY id;
-	`)
+		WHERE id > $1
+		ORDER BY id
+		LIMIT NULLIF($2, 0);
+	`, p.After, p.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -344,15 +612,7 @@ func (s *Storage) AllTasks() ([]Task, error) {
 	// и сканирование каждой строки в переменную
 	for rows.Next() {
 		var t Task
-		err = rows.Scan(
-			&t.ID,
-			&t.Opened,
-			&t.Closed,
-			&t.AuthorID,
-			&t.AssignedID,
-			&t.Title,
-			&t.Content,
-		)
+		err = scanTask(rows, &t)
 		if err != nil {
 			return nil, err
 		}
@@ -365,8 +625,10 @@ func (s *Storage) AllTasks() ([]Task, error) {
 }
 
 // Tasks возвращает список задач из БД.
-func (s *Storage) Tasks(taskID, authorID int) ([]Task, error) {
-	rows, err := s.DB.Query(context.Background(), `
+func (s *Storage) Tasks(ctx context.Context, taskID, authorID int) (_ []Task, err error) {
+	ctx, end := startOp(ctx, "Tasks")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
 		SELECT 
 			id,
 			opened,
@@ -374,7 +636,9 @@ func (s *Storage) Tasks(taskID, authorID int) ([]Task, error) {
 			author_id,
 			assigned_id,
 			title,
-			content
+			content,
+			due,
+			priority
 		FROM tasks
 		WHERE
 			($1 = 0 OR id = $1) AND
@@ -392,15 +656,7 @@ func (s *Storage) Tasks(taskID, authorID int) ([]Task, error) {
 	// и сканирование каждой строки в переменную
 	for rows.Next() {
 		var t Task
-		err = rows.Scan(
-			&t.ID,
-			&t.Opened,
-			&t.Closed,
-			&t.AuthorID,
-			&t.AssignedID,
-			&t.Title,
-			&t.Content,
-		)
+		err = scanTask(rows, &t)
 		if err != nil {
 			return nil, err
 		}
@@ -413,8 +669,10 @@ func (s *Storage) Tasks(taskID, authorID int) ([]Task, error) {
 }
 
 // TasksByLabel - возвращает список задач по ID метки
-func (s *Storage) TasksByLabel(labelID int) ([]Task, error) {
-	rows, err := s.DB.Query(context.Background(), `
+func (s *Storage) TasksByLabel(ctx context.Context, labelID int) (_ []Task, err error) {
+	ctx, end := startOp(ctx, "TasksByLabel")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
 		SELECT 
 			t.id,
 			t.opened,
@@ -422,7 +680,9 @@ func (s *Storage) TasksByLabel(labelID int) ([]Task, error) {
 			t.author_id,
 			t.assigned_id,
 			t.title,
-			t.content
+			t.content,
+			t.due,
+			t.priority
 		FROM tasks as t
 		INNER JOIN tasks_labels as tl
     	ON (tl.label_id = $1) AND (t.id = tl.task_id);`,
@@ -436,15 +696,7 @@ func (s *Storage) TasksByLabel(labelID int) ([]Task, error) {
 	// и сканирование каждой строки в переменную
 	for rows.Next() {
 		var t Task
-		err = rows.Scan(
-			&t.ID,
-			&t.Opened,
-			&t.Closed,
-			&t.AuthorID,
-			&t.AssignedID,
-			&t.Title,
-			&t.Content,
-		)
+		err = scanTask(rows, &t)
 		if err != nil {
 			return nil, err
 		}
@@ -457,8 +709,10 @@ func (s *Storage) TasksByLabel(labelID int) ([]Task, error) {
 }
 
 // TasksByAuthor - возвращает список задач по ID автора
-func (s *Storage) TasksByAuthor(authorID int) ([]Task, error) {
-	rows, err := s.DB.Query(context.Background(), `
+func (s *Storage) TasksByAuthor(ctx context.Context, authorID int) (_ []Task, err error) {
+	ctx, end := startOp(ctx, "TasksByAuthor")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
 		SELECT 
 			id,
 			opened,
@@ -466,7 +720,9 @@ func (s *Storage) TasksByAuthor(authorID int) ([]Task, error) {
 			author_id,
 			assigned_id,
 			title,
-			content
+			content,
+			due,
+			priority
 		FROM tasks
 		WHERE
 		author_id = $1
@@ -482,15 +738,7 @@ func (s *Storage) TasksByAuthor(authorID int) ([]Task, error) {
 	// и сканирование каждой строки в переменную
 	for rows.Next() {
 		var t Task
-		err = rows.Scan(
-			&t.ID,
-			&t.Opened,
-			&t.Closed,
-			&t.AuthorID,
-			&t.AssignedID,
-			&t.Title,
-			&t.Content,
-		)
+		err = scanTask(rows, &t)
 		if err != nil {
 			return nil, err
 		}
@@ -502,92 +750,423 @@ func (s *Storage) TasksByAuthor(authorID int) ([]Task, error) {
 	return tasks, rows.Err()
 }
 
-// NewTask - создаёт новую задачу и возвращает все поля в t *Task.
-func (s *Storage) NewTask(t *Task) error {
+// OpenTaskCounts - возвращает число открытых задач по ID исполнителя, 0 - задачи без исполнителя
+func (s *Storage) OpenTaskCounts(ctx context.Context) (_ map[int]int, err error) {
+	ctx, end := startOp(ctx, "OpenTaskCounts")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT COALESCE(assigned_id, 0), count(*)
+		FROM tasks
+		WHERE closed = 0
+		GROUP BY 1;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[int]int{}
+	for rows.Next() {
+		var assignee, n int
+		err = rows.Scan(&assignee, &n)
+		if err != nil {
+			return nil, err
+		}
+		counts[assignee] = n
+	}
+	return counts, rows.Err()
+}
+
+// NewTask - создаёт новую задачу и возвращает все поля в t *Task. Автор и исполнитель
+// проверяются так же, как в NewTasks.
+func (s *Storage) NewTask(ctx context.Context, t *Task) (err error) {
+	ctx, end := startOp(ctx, "NewTask")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	author := t.AuthorID
+	if author == 0 {
+		author = DefaultUserID
+	}
+	err = checkPeople(ctx, tx, author, t.AssignedID, map[[2]int]bool{})
+	if err != nil {
+		return err
+	}
+
 	var id int
-	err := s.DB.QueryRow(context.Background(), `
-		INSERT INTO tasks (title, content)
-		VALUES ($1, $2) RETURNING id;
+	err = tx.QueryRow(ctx, `
+		INSERT INTO tasks (title, content, due, priority, author_id, assigned_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0)) RETURNING id;
 		`,
 		t.Title,
 		t.Content,
+		t.Due,
+		t.Priority,
+		author,
+		t.AssignedID,
 	).Scan(&id)
+	if err != nil {
+		return err
+	}
 
-	thisTask, err := s.TaskById(id)
+	thisTask, err := taskById(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = publishEvent(ctx, tx, EventTaskCreated, thisTask, nil)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
 		return err
 	}
 
 	*t = *thisTask
 
-	return err
+	return nil
 }
 
-// NewTasks - создаёт массив задач и возвращает все поля в t []*Task.
-func (s *Storage) NewTasks(tasks []*Task) error {
-	ctx := context.Background()
-	tx, err := s.DB.Begin(ctx)
-	defer tx.Rollback(ctx)
+// NewTasks - создаёт массив задач в одной транзакции и возвращает все поля в tasks.
+// labels[i] - ID меток задачи tasks[i], labels может быть короче tasks или nil.
+// Автор 0 заменяется на DefaultUserID, исполнитель 0 - задача без исполнителя. Задачи,
+// метки и события создаются тремя запросами независимо от количества задач. Несколько меток
+// одной группы у задачи, несуществующая метка, несуществующий автор или исполнитель
+// и деактивированный исполнитель - ошибка ErrInvalid.
+func (s *Storage) NewTasks(ctx context.Context, tasks []*Task, labels [][]int) (err error) {
+	ctx, end := startOp(ctx, "NewTasks")
+	defer end(&err)
+	if len(tasks) == 0 {
+		return nil
+	}
+	tx, err := s.begin(ctx)
 	if err != nil {
 		return err
 	}
-	_, err = tx.Prepare(ctx, "my-insert", `
-		INSERT INTO tasks (title, content)
-		VALUES ($1, $2) RETURNING id;
-	`)
+	defer tx.Rollback(ctx)
+
+	authors := make([]int, len(tasks))
+	checked := map[[2]int]bool{}
+	for i, t := range tasks {
+		authors[i] = t.AuthorID
+		if authors[i] == 0 {
+			authors[i] = DefaultUserID
+		}
+		err = checkPeople(ctx, tx, authors[i], t.AssignedID, checked)
+		if err != nil {
+			return fmt.Errorf("задача %d: %w", i, err)
+		}
+	}
 
+	var labelIDs []int
+	for _, ids := range labels {
+		labelIDs = append(labelIDs, ids...)
+	}
+	if len(labelIDs) > 0 {
+		scopes, err := labelScopes(ctx, tx, labelIDs)
+		if err != nil {
+			return err
+		}
+		for i, ids := range labels {
+			var problems []string
+			for _, id := range ids {
+				if _, ok := scopes[id]; !ok {
+					problems = append(problems, fmt.Sprintf("метка %d не найдена", id))
+				}
+			}
+			problems = append(problems, scopeConflicts(ids, scopes)...)
+			if len(problems) > 0 {
+				return fmt.Errorf("задача %d: %w", i, invalid(problems))
+			}
+		}
+	}
+
+	var (
+		titles     = make([]string, len(tasks))
+		contents   = make([]string, len(tasks))
+		dues       = make([]int64, len(tasks))
+		priorities = make([]int, len(tasks))
+		assignees  = make([]int, len(tasks))
+	)
+	for i, t := range tasks {
+		titles[i], contents[i], dues[i], priorities[i] = t.Title, t.Content, t.Due, t.Priority
+		assignees[i] = t.AssignedID
+	}
+
+	// Порядок, в котором INSERT берет ID из последовательности, не гарантирован, поэтому
+	// ID выделяются заранее вместе с номером задачи n, и созданные задачи сопоставляются
+	// с переданными по n
+	rows, err := tx.Query(ctx, `
+		WITH input AS (
+			SELECT nextval(pg_get_serial_sequence('tasks', 'id'))::integer as id, t.*
+			FROM unnest($1::text[], $2::text[], $3::bigint[], $4::integer[], $5::integer[], $6::integer[])
+				WITH ORDINALITY AS t(title, content, due, priority, author_id, assigned_id, n)
+		), inserted AS (
+			INSERT INTO tasks (id, title, content, due, priority, author_id, assigned_id)
+			SELECT id, title, content, due, priority, author_id, NULLIF(assigned_id, 0)
+			FROM input
+			RETURNING id, opened, closed, author_id, assigned_id, title, content, due, priority
+		)
+		SELECT t.id, t.opened, t.closed, t.author_id, t.assigned_id, t.title, t.content, t.due, t.priority, i.n
+		FROM inserted as t
+		INNER JOIN input as i ON i.id = t.id;
+		`,
+		titles,
+		contents,
+		dues,
+		priorities,
+		authors,
+		assignees,
+	)
 	if err != nil {
-		logger.Error("Ошибка при подготовке плана: %s", err.Error())
 		return err
 	}
+	created := make([]Task, len(tasks))
+	found := 0
+	for rows.Next() {
+		var t Task
+		var n int
+		err = rows.Scan(
+			&t.ID,
+			&t.Opened,
+			&t.Closed,
+			&t.AuthorID,
+			nullID{&t.AssignedID},
+			&t.Title,
+			&t.Content,
+			&t.Due,
+			&t.Priority,
+			&n,
+		)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if n < 1 || n > len(tasks) || created[n-1].ID != 0 {
+			rows.Close()
+			return fmt.Errorf("некорректный номер созданной задачи %d", n)
+		}
+		created[n-1] = t
+		found++
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if found != len(tasks) {
+		return fmt.Errorf("создано %d задач из %d", found, len(tasks))
+	}
 
-	for _, task := range tasks {
-		row := tx.QueryRow(ctx, "my-insert", task.Title, task.Content)
-		err := row.Scan(&task.ID)
+	linkTasks, linkLabels, taskLabels := newTaskLabels(created, labels)
+	if len(linkTasks) > 0 {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO tasks_labels (task_id, label_id)
+			SELECT * FROM unnest($1::integer[], $2::integer[]);
+			`,
+			linkTasks,
+			linkLabels,
+		)
 		if err != nil {
 			return err
 		}
 	}
 
-	tx.Commit(ctx)
+	ids := make([]int, len(created))
+	payloads := make([]string, len(created))
+	for i := range created {
+		payload, err := json.Marshal(eventPayload{Task: created[i], Labels: taskLabels[i]})
+		if err != nil {
+			return err
+		}
+		ids[i], payloads[i] = created[i].ID, string(payload)
+	}
+	_, err = tx.Exec(ctx, `
+		WITH e AS (
+			INSERT INTO events (type, task_id, payload)
+			SELECT $1, task_id, payload::jsonb
+			FROM unnest($2::integer[], $3::text[]) WITH ORDINALITY AS p(task_id, payload, n)
+			ORDER BY n
+			RETURNING id
+		)
+		SELECT pg_notify($4, id::text) FROM e;
+		`,
+		EventTaskCreated,
+		ids,
+		payloads,
+		EventsChannel,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range created {
+		*tasks[i] = created[i]
+	}
 	return nil
 }
 
+// newTaskLabels - связи созданных задач с метками без повторов: пары linkTasks[i], linkLabels[i]
+// для вставки в tasks_labels и отсортированные метки каждой задачи для события task.created,
+// у задачи без меток - пустой список, как у taskLabelIDs
+func newTaskLabels(created []Task, labels [][]int) (linkTasks, linkLabels []int, taskLabels [][]int) {
+	taskLabels = make([][]int, len(created))
+	for i := range created {
+		taskLabels[i] = []int{}
+		if i >= len(labels) {
+			continue
+		}
+		seen := map[int]bool{}
+		for _, labelID := range labels[i] {
+			if !seen[labelID] {
+				seen[labelID] = true
+				linkTasks = append(linkTasks, created[i].ID)
+				linkLabels = append(linkLabels, labelID)
+				taskLabels[i] = append(taskLabels[i], labelID)
+			}
+		}
+		sort.Ints(taskLabels[i])
+	}
+	return linkTasks, linkLabels, taskLabels
+}
+
 // UpdateTask - обновляет задачу и возвращает уже обновленную модель
-func (s *Storage) UpdateTask(t *Task) error {
-	_, err := s.DB.Exec(context.Background(), `
+func (s *Storage) UpdateTask(ctx context.Context, t *Task) (err error) {
+	ctx, end := startOp(ctx, "UpdateTask")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	previous, err := taskById(ctx, tx, t.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
 		UPDATE tasks
-		SET (title, content, closed) = ($1, $2, $3)
+		SET (title, content, closed, due, priority) = ($1, $2, $3, $4, $5)
 		WHERE
-			(id = $4);`,
+			(id = $6);`,
 		t.Title,
 		t.Content,
 		t.Closed,
+		t.Due,
+		t.Priority,
 		t.ID,
 	)
 
 	if err != nil {
-		logger.Error("Ошибка при обновлении задачи: %s", err.Error())
+		logger.ErrorContext(ctx, "Ошибка при обновлении задачи: %s", err.Error())
 		return err
 	}
 
-	thisTask, err := s.TaskById(t.ID)
+	thisTask, err := taskById(ctx, tx, t.ID)
 	if err != nil {
 		return err
 	}
 
+	err = publishEvent(ctx, tx, EventTaskUpdated, thisTask, previous)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	s.invalidate(ctx, taskKey(t.ID))
+
 	*t = *thisTask
 
-	return err
+	return nil
+}
+
+// AssignTask - назначает исполнителя задачи и возвращает обновленную задачу. userID 0 снимает
+// исполнителя, назначить задачу на деактивированного пользователя нельзя (ErrUserInactive)
+func (s *Storage) AssignTask(ctx context.Context, taskID, userID int) (_ *Task, err error) {
+	ctx, end := startOp(ctx, "AssignTask")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return &Task{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	previous, err := taskById(ctx, tx, taskID)
+	if err != nil {
+		return previous, err
+	}
+	err = checkAssignee(ctx, tx, userID)
+	if err != nil {
+		return previous, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE tasks
+		SET assigned_id = NULLIF($1, 0)
+		WHERE
+			(id = $2);`,
+		userID,
+		taskID,
+	)
+	if err != nil {
+		logger.ErrorContext(ctx, "Ошибка при назначении задачи: %s", err.Error())
+		return previous, err
+	}
+
+	thisTask, err := taskById(ctx, tx, taskID)
+	if err != nil {
+		return thisTask, err
+	}
+
+	err = publishEvent(ctx, tx, EventTaskUpdated, thisTask, previous)
+	if err != nil {
+		return thisTask, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return thisTask, err
+	}
+	s.invalidate(ctx, taskKey(taskID))
+
+	return thisTask, nil
 }
 
 // DeleteTask - удаляет задачу по ее ID и возвращает удаленную запись
-func (s *Storage) DeleteTask(id int) (*Task, error) {
-	thisTask, err := s.TaskById(id)
+func (s *Storage) DeleteTask(ctx context.Context, id int) (_ *Task, err error) {
+	ctx, end := startOp(ctx, "DeleteTask")
+	defer end(&err)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return &Task{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	thisTask, err := taskById(ctx, tx, id)
 	if err != nil {
 		return thisTask, err
 	}
-	_, err = s.DB.Exec(context.Background(), `
+
+	// Событие публикуется до удаления, чтобы в нем остались метки задачи
+	err = publishEvent(ctx, tx, EventTaskDeleted, thisTask, nil)
+	if err != nil {
+		return thisTask, err
+	}
+
+	_, err = tx.Exec(ctx, `
 		DELETE FROM tasks
 		WHERE
 			(id = $1);`,
@@ -595,7 +1174,103 @@ func (s *Storage) DeleteTask(id int) (*Task, error) {
 	)
 
 	if err != nil {
-		logger.Error("Ошибка при обновлении задачи: %s", err.Error())
+		logger.ErrorContext(ctx, "Ошибка при обновлении задачи: %s", err.Error())
+		return thisTask, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return thisTask, err
+	}
+	s.invalidate(ctx, taskKey(id))
+
+	return thisTask, nil
+}
+
+//-------------------Метки задач-------------------------
+
+// TaskLabels - возвращает метки задачи
+func (s *Storage) TaskLabels(ctx context.Context, taskID int) (_ []Label, err error) {
+	ctx, end := startOp(ctx, "TaskLabels")
+	defer end(&err)
+	rows, err := s.db().Query(ctx, `
+		SELECT 
+			l.id,
+			l.scope,
+			l.name,
+			l.color,
+			l.description
+		FROM labels as l
+		INNER JOIN tasks_labels as tl
+		ON (tl.task_id = $1) AND (l.id = tl.label_id)
+		ORDER BY l.id;`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var labels []Label
+	for rows.Next() {
+		var l Label
+		err = scanLabel(rows, &l)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	return labels, rows.Err()
+}
+
+// AddTaskLabel - привязывает метку к задаче и возвращает задачу. Метка из группы заменяет
+// на задаче другую метку той же группы
+func (s *Storage) AddTaskLabel(ctx context.Context, taskID, labelID int) (_ *Task, err error) {
+	ctx, end := startOp(ctx, "AddTaskLabel")
+	defer end(&err)
+	return s.relabelTask(ctx, taskID, addTaskLabelSQL, taskID, labelID)
+}
+
+// RemoveTaskLabel - отвязывает метку от задачи и возвращает задачу
+func (s *Storage) RemoveTaskLabel(ctx context.Context, taskID, labelID int) (_ *Task, err error) {
+	ctx, end := startOp(ctx, "RemoveTaskLabel")
+	defer end(&err)
+	return s.relabelTask(ctx, taskID, `
+		DELETE FROM tasks_labels
+		WHERE task_id = $1 AND label_id = $2;`,
+		taskID, labelID)
+}
+
+// relabelTask - выполняет изменение связей задачи с метками и публикует событие task.relabeled
+func (s *Storage) relabelTask(ctx context.Context, taskID int, sql string, args ...any) (*Task, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return &Task{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка задачи не дает параллельно привязать две метки одной группы
+	_, err = tx.Exec(ctx, "SELECT id FROM tasks WHERE id = $1 FOR UPDATE;", taskID)
+	if err != nil {
+		return &Task{}, err
+	}
+	thisTask, err := taskById(ctx, tx, taskID)
+	if err != nil {
+		return thisTask, err
+	}
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return thisTask, err
+	}
+
+	if tag.RowsAffected() > 0 {
+		err = publishEvent(ctx, tx, EventTaskRelabeled, thisTask, nil)
+		if err != nil {
+			return thisTask, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
 		return thisTask, err
 	}
 
@@ -3,6 +3,7 @@ package storage
 import (
 	"TaskManager/pkg/logger"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"testing"
@@ -63,7 +64,7 @@ func TestStorage_Tasks(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.Tasks(tt.args.taskID, tt.args.authorID)
+			got, err := s.Tasks(context.Background(), tt.args.taskID, tt.args.authorID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Tasks() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -94,7 +95,7 @@ func TestStorage_AllLabels(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.AllLabels()
+			got, err := s.AllLabels(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("AllLabels() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -126,7 +127,7 @@ func TestStorage_AllTasks(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.AllTasks()
+			got, err := s.AllTasks(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("AllTasks() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -158,7 +159,7 @@ func TestStorage_AllUsers(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.AllUsers()
+			got, err := s.AllUsers(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("AllUsers() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -207,7 +208,7 @@ func TestStorage_DeleteLabel(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.DeleteLabel(tt.args.id)
+			got, err := s.DeleteLabel(context.Background(), tt.args.id, true)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DeleteLabel() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -256,7 +257,7 @@ func TestStorage_DeleteTask(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.DeleteTask(tt.args.id)
+			got, err := s.DeleteTask(context.Background(), tt.args.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DeleteTask() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -305,7 +306,7 @@ func TestStorage_DeleteUser(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.DeleteUser(tt.args.id)
+			got, err := s.DeleteUser(context.Background(), tt.args.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DeleteUser() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -354,7 +355,7 @@ func TestStorage_LabelById(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.LabelById(tt.args.id)
+			got, err := s.LabelById(context.Background(), tt.args.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("LabelById() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -407,7 +408,7 @@ func TestStorage_NewLabel(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			if err := s.NewLabel(tt.args.label); (err != nil) != tt.wantErr {
+			if err := s.NewLabel(context.Background(), tt.args.label); (err != nil) != tt.wantErr {
 				t.Errorf("NewLabel() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			t.Log(fmt.Sprintf("NewLabel() got = %+v", tt.args.label))
@@ -460,7 +461,7 @@ func TestStorage_NewTask(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			if err := s.NewTask(tt.args.t); (err != nil) != tt.wantErr {
+			if err := s.NewTask(context.Background(), tt.args.t); (err != nil) != tt.wantErr {
 				t.Errorf("NewTask() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			t.Log(fmt.Sprintf("NewTask() got = %+v", tt.args.t))
@@ -473,7 +474,8 @@ func TestStorage_NewTasks(t *testing.T) {
 		DB *pgxpool.Pool
 	}
 	type args struct {
-		tasks []*Task
+		tasks  []*Task
+		labels [][]int
 	}
 	tests := []struct {
 		name    string
@@ -509,15 +511,72 @@ func TestStorage_NewTasks(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Создание задач с метками",
+			fields: fields{
+				DB: newConnet(),
+			},
+			args: args{
+				tasks: []*Task{
+					{Title: "Тест задачи7", Content: "Контент тестовой задачи7"},
+					{Title: "Тест задачи8", Content: "Контент тестовой задачи8"},
+					{Title: "Тест задачи9", Content: "Контент тестовой задачи9"},
+				},
+				labels: [][]int{{1}, nil, {1, 1}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Создание задачи с несуществующей меткой",
+			fields: fields{
+				DB: newConnet(),
+			},
+			args: args{
+				tasks:  []*Task{{Title: "Тест задачи10", Content: "Контент тестовой задачи10"}},
+				labels: [][]int{{-1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Создание задачи с несуществующим автором",
+			fields: fields{
+				DB: newConnet(),
+			},
+			args: args{
+				tasks: []*Task{{Title: "Тест задачи11", Content: "Контент тестовой задачи11", AuthorID: -1}},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			if err := s.NewTasks(tt.args.tasks); (err != nil) != tt.wantErr {
+			err := s.NewTasks(context.Background(), tt.args.tasks, tt.args.labels)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("NewTasks() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalid) {
+					t.Errorf("NewTasks() error = %v, want ErrInvalid", err)
+				}
+				return
+			}
+			// Задачи возвращаются в порядке запроса, метки связаны с нужными задачами
+			for i, task := range tt.args.tasks {
+				if i > 0 && task.ID <= tt.args.tasks[i-1].ID {
+					t.Errorf("NewTasks() задача %d с ID %d после ID %d", i, task.ID, tt.args.tasks[i-1].ID)
+				}
+				var want []int
+				if i < len(tt.args.labels) && len(tt.args.labels[i]) > 0 {
+					want = []int{tt.args.labels[i][0]}
+				}
+				labels, err := taskLabelIDs(context.Background(), s.DB, task.ID)
+				if err != nil || fmt.Sprint(labels) != fmt.Sprint(want) {
+					t.Errorf("NewTasks() метки задачи %d = %v, %v, want %v", task.ID, labels, err, want)
+				}
+			}
 			t.Log(fmt.Sprintf("NewTasks() got = %+v", tt.args.tasks))
 		})
 	}
@@ -566,7 +625,7 @@ func TestStorage_NewUser(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			if err := s.NewUser(tt.args.user); (err != nil) != tt.wantErr {
+			if err := s.NewUser(context.Background(), tt.args.user); (err != nil) != tt.wantErr {
 				t.Errorf("NewUser() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			t.Log(fmt.Sprintf("NewUser() got = %+v", tt.args.user))
@@ -623,7 +682,7 @@ func TestStorage_TaskById(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.TaskById(tt.args.taskID)
+			got, err := s.TaskById(context.Background(), tt.args.taskID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("TaskById() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -672,7 +731,7 @@ func TestStorage_TasksByAuthor(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.TasksByAuthor(tt.args.authorID)
+			got, err := s.TasksByAuthor(context.Background(), tt.args.authorID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("TasksByAuthor() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -721,7 +780,7 @@ func TestStorage_TasksByLabel(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.TasksByLabel(tt.args.labelID)
+			got, err := s.TasksByLabel(context.Background(), tt.args.labelID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("TasksByLabel() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -776,7 +835,7 @@ func TestStorage_UpdateLabel(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			if err := s.UpdateLabel(tt.args.l); (err != nil) != tt.wantErr {
+			if err := s.UpdateLabel(context.Background(), tt.args.l); (err != nil) != tt.wantErr {
 				t.Errorf("UpdateLabel() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			t.Log(fmt.Sprintf("UpdateLabel() got = %+v", tt.args.l))
@@ -831,7 +890,7 @@ func TestStorage_UpdateTask(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			if err := s.UpdateTask(tt.args.t); (err != nil) != tt.wantErr {
+			if err := s.UpdateTask(context.Background(), tt.args.t); (err != nil) != tt.wantErr {
 				t.Errorf("UpdateTask() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			t.Log(fmt.Sprintf("UpdateTask() got = %+v", tt.args.t))
@@ -884,7 +943,7 @@ func TestStorage_UpdateUser(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			if err := s.UpdateUser(tt.args.u); (err != nil) != tt.wantErr {
+			if err := s.UpdateUser(context.Background(), tt.args.u); (err != nil) != tt.wantErr {
 				t.Errorf("UpdateUser() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			t.Log(fmt.Sprintf("UpdateUser() got = %+v", tt.args.u))
@@ -931,7 +990,7 @@ func TestStorage_UserById(t *testing.T) {
 			s := &Storage{
 				DB: tt.fields.DB,
 			}
-			got, err := s.UserById(tt.args.id)
+			got, err := s.UserById(context.Background(), tt.args.id)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("UserById() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"TaskManager/pkg/metrics"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("TaskManager/pkg/storage")
+
+// startOp - начинает операцию хранилища: span метода и учет в метриках. Возвращаемая
+// функция вызывается через defer с указателем на ошибку метода; отсутствие строк ошибкой не считается
+func startOp(ctx context.Context, method string) (context.Context, func(err *error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "storage."+method,
+		trace.WithAttributes(attribute.String("db.system", "postgresql")))
+	return ctx, func(err *error) {
+		e := *err
+		if errors.Is(e, pgx.ErrNoRows) {
+			e = nil
+		}
+		endSpan(span, e)
+		metrics.ObserveStorage(method, time.Since(start), e)
+	}
+}
+
+// endSpan - завершает span, отмечая ошибку
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+//-------------------Запросы-------------------------
+
+// db - пул соединений, запросы которого записываются в трассировку
+func (s *Storage) db() querier {
+	return tracedQuerier{s.DB}
+}
+
+// begin - начинает транзакцию, запросы которой записываются в трассировку
+func (s *Storage) begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tracedTx{Tx: tx, q: tracedQuerier{tx}}, nil
+}
+
+// tracedTx - транзакция с трассировкой запросов, остальные методы pgx.Tx не меняются.
+type tracedTx struct {
+	pgx.Tx
+	q tracedQuerier
+}
+
+func (t tracedTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return t.q.Exec(ctx, sql, args...)
+}
+
+func (t tracedTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return t.q.Query(ctx, sql, args...)
+}
+
+func (t tracedTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return t.q.QueryRow(ctx, sql, args...)
+}
+
+// tracedQuerier - выполняет запросы в q, оборачивая каждый в span с названием запроса и числом строк.
+type tracedQuerier struct {
+	q querier
+}
+
+func (t tracedQuerier) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	ctx, span := startQuery(ctx, sql)
+	tag, err := t.q.Exec(ctx, sql, args...)
+	if err == nil {
+		span.SetAttributes(attribute.Int64("db.rows_affected", tag.RowsAffected()))
+	}
+	endSpan(span, err)
+	return tag, err
+}
+
+func (t tracedQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ctx, span := startQuery(ctx, sql)
+	rows, err := t.q.Query(ctx, sql, args...)
+	if err != nil {
+		endSpan(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (t tracedQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	ctx, span := startQuery(ctx, sql)
+	return &tracedRow{Row: t.q.QueryRow(ctx, sql, args...), span: span}
+}
+
+// tracedRows - считает прочитанные строки и завершает span, когда строки закончились или закрыты.
+type tracedRows struct {
+	pgx.Rows
+	span  trace.Span
+	count int64
+	done  bool
+}
+
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		r.count++
+		return true
+	}
+	r.end()
+	return false
+}
+
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+	r.end()
+}
+
+func (r *tracedRows) end() {
+	if r.done {
+		return
+	}
+	r.done = true
+	r.span.SetAttributes(attribute.Int64("db.rows_returned", r.count))
+	endSpan(r.span, r.Rows.Err())
+}
+
+// tracedRow - завершает span при чтении строки результата.
+type tracedRow struct {
+	pgx.Row
+	span trace.Span
+}
+
+func (r *tracedRow) Scan(dest ...any) error {
+	err := r.Row.Scan(dest...)
+	var n int64
+	if err == nil {
+		n = 1
+	}
+	r.span.SetAttributes(attribute.Int64("db.rows_returned", n))
+	if errors.Is(err, pgx.ErrNoRows) {
+		endSpan(r.span, nil)
+	} else {
+		endSpan(r.span, err)
+	}
+	return err
+}
+
+// startQuery - начинает span запроса с названием вида "SELECT tasks"
+func startQuery(ctx context.Context, sql string) (context.Context, trace.Span) {
+	sql = strings.Join(strings.Fields(sql), " ")
+	operation, table := statementName(sql)
+	name := operation
+	if table != "" {
+		name += " " + table
+	}
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", operation),
+		attribute.String("db.sql.table", table),
+		attribute.String("db.statement", sql),
+	))
+}
+
+// Таблица запроса: первое имя после FROM, INTO, UPDATE или JOIN, за которым не следует скобка
+// (extract(epoch from now()) - не таблица).
+var tableRe = regexp.MustCompile(`(?i)\b(?:from|into|update|join)\s+([a-z_][a-z0-9_]*)(\(?)`)
+
+// statementName - операция и основная таблица запроса. Для подготовленных запросов,
+// которые вызываются по имени, операцией считается имя запроса
+func statementName(sql string) (operation, table string) {
+	operation, _, found := strings.Cut(sql, " ")
+	if !found {
+		return operation, ""
+	}
+	operation = strings.ToUpper(operation)
+	for _, m := range tableRe.FindAllStringSubmatch(sql, -1) {
+		if m[2] == "" {
+			return operation, strings.ToLower(m[1])
+		}
+	}
+	return operation, ""
+}
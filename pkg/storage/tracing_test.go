@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatementName(t *testing.T) {
+	tests := []struct {
+		sql       string
+		operation string
+		table     string
+	}{
+		{"SELECT id, name FROM labels WHERE id = $1;", "SELECT", "labels"},
+		{"select t.id from tasks t join labels_tasks lt on lt.task_id = t.id", "SELECT", "tasks"},
+		{"INSERT INTO events (type, task_id, payload) VALUES ($1, $2, $3)", "INSERT", "events"},
+		{"UPDATE mail_queue SET sent = extract(epoch from now()) WHERE id = $1;", "UPDATE", "mail_queue"},
+		{"WITH e AS ( INSERT INTO events (type) VALUES ($1) RETURNING id ) SELECT pg_notify($2, id::text) FROM e;", "WITH", "events"},
+		{"SELECT extract(epoch from now())", "SELECT", ""},
+		{"my-insert", "my-insert", ""},
+	}
+	for _, tt := range tests {
+		operation, table := statementName(strings.Join(strings.Fields(tt.sql), " "))
+		if operation != tt.operation || table != tt.table {
+			t.Errorf("statementName(%q) = %q, %q, want %q, %q", tt.sql, operation, table, tt.operation, tt.table)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Ошибки удаления и деактивации пользователей.
+var (
+	// ErrUserInUse - пользователь автор или исполнитель задач.
+	ErrUserInUse = errors.New("пользователь связан с задачами")
+	// ErrUserInactive - пользователь деактивирован, назначить на него задачу нельзя.
+	ErrUserInactive = fmt.Errorf("%w: пользователь деактивирован", ErrInvalid)
+)
+
+// ChangeUserOffboard - вид записи журнала изменений о деактивации пользователя: before -
+// пользователь до деактивации, after - после, с новым исполнителем и ID переназначенных задач
+const ChangeUserOffboard = "user.offboard"
+
+// UserOffboardReport - отчет о деактивации пользователя.
+type UserOffboardReport struct {
+	DryRun    bool
+	Committed bool
+	// Пользователь после деактивации
+	User User
+	// Новый исполнитель открытых задач, nil - задачи остаются без исполнителя
+	ReassignTo *User `json:"ReassignTo,omitempty"`
+	// Открытые задачи пользователя после переназначения
+	Reassigned []Task
+	// Закрытых задач, у которых пользователь остается исполнителем
+	ClosedAssigned int
+	// Задач, у которых пользователь остается автором
+	Authored int
+}
+
+// checkAssignee - проверяет, что на пользователя можно назначить задачу: 0 - без исполнителя,
+// иначе пользователь должен существовать и быть активен. Пользователь блокируется до конца
+// транзакции q, чтобы его не деактивировали параллельно
+func checkAssignee(ctx context.Context, q querier, userID int) error {
+	if userID == 0 {
+		return nil
+	}
+	var deactivated int64
+	err := q.QueryRow(ctx, "SELECT deactivated FROM users WHERE id = $1 FOR SHARE;", userID).Scan(&deactivated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: исполнитель %d не найден", ErrInvalid, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if deactivated != 0 {
+		return fmt.Errorf("%w: %d", ErrUserInactive, userID)
+	}
+	return nil
+}
+
+// checkAuthor - проверяет, что автор задачи существует. Пользователь блокируется до конца
+// транзакции q, чтобы его не удалили параллельно
+func checkAuthor(ctx context.Context, q querier, userID int) error {
+	var id int
+	err := q.QueryRow(ctx, "SELECT id FROM users WHERE id = $1 FOR SHARE;", userID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: автор %d не найден", ErrInvalid, userID)
+	}
+	return err
+}
+
+// checkPeople - проверяет автора и исполнителя новой задачи. Пользователи из checked уже
+// проверены в этой транзакции и повторно не проверяются
+func checkPeople(ctx context.Context, q querier, authorID, assigneeID int, checked map[[2]int]bool) error {
+	if key := [2]int{0, authorID}; !checked[key] {
+		checked[key] = true
+		err := checkAuthor(ctx, q, authorID)
+		if err != nil {
+			return err
+		}
+	}
+	if key := [2]int{1, assigneeID}; !checked[key] {
+		checked[key] = true
+		return checkAssignee(ctx, q, assigneeID)
+	}
+	return nil
+}
+
+// OffboardUser - деактивирует пользователя в одной транзакции: его открытые задачи
+// переназначаются на reassignTo (0 - остаются без исполнителя) с событием task.updated
+// для каждой, закрытые задачи и авторство не меняются, токен календарной ленты сбрасывается.
+// Деактивация записывается в журнал изменений, повторная деактивация не меняет ее время.
+// При dryRun транзакция откатывается, а отчет показывает, что было бы сделано.
+func (s *Storage) OffboardUser(ctx context.Context, id, reassignTo int, dryRun bool) (_ *UserOffboardReport, err error) {
+	ctx, end := startOp(ctx, "OffboardUser")
+	defer end(&err)
+	if reassignTo == id {
+		return nil, fmt.Errorf("%w: задачи нельзя переназначить на деактивируемого пользователя", ErrInvalid)
+	}
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка пользователя не дает параллельно назначить на него задачу
+	previous := &User{}
+	err = scanUser(tx.QueryRow(ctx, `
+		SELECT id, name, email, locale, email_opt_out, deactivated
+		FROM users
+		WHERE id = $1
+		FOR UPDATE;
+		`, id), previous)
+	if err != nil {
+		return nil, fmt.Errorf("пользователь %d: %w", id, err)
+	}
+
+	report := &UserOffboardReport{DryRun: dryRun, Reassigned: []Task{}}
+	if reassignTo != 0 {
+		err = checkAssignee(ctx, tx, reassignTo)
+		if err != nil {
+			return nil, err
+		}
+		report.ReassignTo = &User{}
+		err = scanUser(tx.QueryRow(ctx, `
+			SELECT id, name, email, locale, email_opt_out, deactivated
+			FROM users
+			WHERE id = $1;
+			`, reassignTo), report.ReassignTo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id
+		FROM tasks
+		WHERE assigned_id = $1 AND COALESCE(closed, 0) = 0
+		ORDER BY id
+		FOR UPDATE;
+		`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var taskIDs []int
+	for rows.Next() {
+		var taskID int
+		err = rows.Scan(&taskID)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		taskIDs = append(taskIDs, taskID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, taskID := range taskIDs {
+		previousTask, err := taskById(ctx, tx, taskID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, "UPDATE tasks SET assigned_id = NULLIF($1, 0) WHERE id = $2;", reassignTo, taskID)
+		if err != nil {
+			return nil, err
+		}
+		task, err := taskById(ctx, tx, taskID)
+		if err != nil {
+			return nil, err
+		}
+		err = publishEvent(ctx, tx, EventTaskUpdated, task, previousTask)
+		if err != nil {
+			return nil, err
+		}
+		report.Reassigned = append(report.Reassigned, *task)
+	}
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+			count(*) FILTER (WHERE assigned_id = $1),
+			count(*) FILTER (WHERE author_id = $1)
+		FROM tasks
+		WHERE assigned_id = $1 OR author_id = $1;
+		`,
+		id,
+	).Scan(&report.ClosedAssigned, &report.Authored)
+	if err != nil {
+		return nil, err
+	}
+
+	report.User = *previous
+	err = tx.QueryRow(ctx, `
+		UPDATE users
+		SET
+			deactivated = CASE WHEN deactivated = 0 THEN extract(epoch from now())::bigint ELSE deactivated END,
+			calendar_token = ''
+		WHERE id = $1
+		RETURNING deactivated;
+		`,
+		id,
+	).Scan(&report.User.Deactivated)
+	if err != nil {
+		return nil, err
+	}
+	err = recordChange(ctx, tx, ChangeUserOffboard, id, previous, struct {
+		User       User
+		ReassignTo int
+		Tasks      []int
+	}{report.User, reassignTo, taskIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Committed = true
+
+	keys := []string{usersKey, userKey(id)}
+	for _, taskID := range taskIDs {
+		keys = append(keys, taskKey(taskID))
+	}
+	s.invalidate(ctx, keys...)
+	return report, nil
+}
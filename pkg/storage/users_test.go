@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestNullID(t *testing.T) {
+	id := 7
+	if err := (nullID{&id}).DecodeBinary(nil, nil); err != nil || id != 0 {
+		t.Errorf("NULL: %d, %v, ожидался 0", id, err)
+	}
+	if err := (nullID{&id}).DecodeBinary(nil, []byte{0, 0, 1, 2}); err != nil || id != 258 {
+		t.Errorf("binary: %d, %v, ожидалось 258", id, err)
+	}
+	if err := (nullID{&id}).DecodeText(nil, []byte("42")); err != nil || id != 42 {
+		t.Errorf("text: %d, %v, ожидалось 42", id, err)
+	}
+}
+
+func TestOffboardUserInvalid(t *testing.T) {
+	_, err := (&Storage{}).OffboardUser(context.Background(), 3, 3, true)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("ожидалась ErrInvalid, получено %v", err)
+	}
+	if !errors.Is(ErrUserInactive, ErrInvalid) {
+		t.Error("ErrUserInactive должна оборачивать ErrInvalid")
+	}
+}
+
+// fakeUsers - querier с таблицей пользователей в памяти: ID -> deactivated
+type fakeUsers struct {
+	querier
+	users   map[int]int64
+	queries int
+}
+
+type fakeUserRow struct {
+	deactivated int64
+	found       bool
+}
+
+func (r fakeUserRow) Scan(dest ...any) error {
+	if !r.found {
+		return pgx.ErrNoRows
+	}
+	switch d := dest[0].(type) {
+	case *int64:
+		*d = r.deactivated
+	case *int:
+		*d = 1
+	}
+	return nil
+}
+
+func (q *fakeUsers) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	q.queries++
+	deactivated, found := q.users[args[0].(int)]
+	return fakeUserRow{deactivated, found && strings.Contains(sql, "FROM users")}
+}
+
+func (q *fakeUsers) Exec(context.Context, string, ...any) (pgconn.CommandTag, error) {
+	return nil, errors.New("неожиданный запрос")
+}
+
+func TestCheckPeople(t *testing.T) {
+	q := &fakeUsers{users: map[int]int64{1: 0, 2: 0, 3: 1700000000}}
+	checked := map[[2]int]bool{}
+	for _, people := range [][2]int{{1, 2}, {1, 2}, {2, 0}} {
+		if err := checkPeople(context.Background(), q, people[0], people[1], checked); err != nil {
+			t.Fatalf("%v: %v", people, err)
+		}
+	}
+	// Автор 1, исполнитель 2, автор 2; исполнитель 0 не проверяется в БД
+	if q.queries != 3 {
+		t.Errorf("запросов %d, ожидалось 3", q.queries)
+	}
+
+	for people, want := range map[[2]int]error{
+		{9, 0}: ErrInvalid,
+		{1, 9}: ErrInvalid,
+		{3, 0}: nil,
+		{1, 3}: ErrUserInactive,
+	} {
+		err := checkPeople(context.Background(), q, people[0], people[1], map[[2]int]bool{})
+		if !errors.Is(err, want) || (want == nil) != (err == nil) {
+			t.Errorf("%v: %v, ожидалось %v", people, err, want)
+		}
+	}
+}
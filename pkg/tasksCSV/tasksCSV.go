@@ -0,0 +1,265 @@
+package tasksCSV
+
+import (
+	"TaskManager/pkg/storage"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Разделитель названий меток в колонке labels.
+const LabelsSeparator = ";"
+
+// Первые символы ячейки, с которых Excel и Google Sheets начинают формулу. Такие ячейки
+// экспортируются с префиксом ', чтобы таблица показала их как текст.
+const formulaChars = "=+-@\t\r"
+
+// Форматы дат, принимаемые при импорте. Также допускается unix time числом.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// column - колонка CSV: получение значения при экспорте и установка при импорте
+type column struct {
+	get func(t *storage.TaskDetails) string
+	// nil - колонка только для экспорта и при импорте игнорируется
+	set func(t *storage.ImportTask, v string) error
+}
+
+var columns = map[string]column{
+	"id": {
+		get: func(t *storage.TaskDetails) string { return strconv.Itoa(t.ID) },
+	},
+	"title": {
+		get: func(t *storage.TaskDetails) string { return t.Title },
+		set: func(t *storage.ImportTask, v string) error { t.Task.Title = v; return nil },
+	},
+	"content": {
+		get: func(t *storage.TaskDetails) string { return t.Content },
+		set: func(t *storage.ImportTask, v string) error { t.Task.Content = v; return nil },
+	},
+	"opened": {
+		get: func(t *storage.TaskDetails) string { return formatTime(t.Opened) },
+	},
+	"closed": {
+		get: func(t *storage.TaskDetails) string { return formatTime(t.Closed) },
+		set: func(t *storage.ImportTask, v string) (err error) { t.Task.Closed, err = parseTime(v); return },
+	},
+	"due": {
+		get: func(t *storage.TaskDetails) string { return formatTime(t.Due) },
+		set: func(t *storage.ImportTask, v string) (err error) { t.Task.Due, err = parseTime(v); return },
+	},
+	"priority": {
+		get: func(t *storage.TaskDetails) string { return strconv.Itoa(t.Priority) },
+		set: func(t *storage.ImportTask, v string) (err error) { t.Task.Priority, err = parsePriority(v); return },
+	},
+	"author_id": {
+		get: func(t *storage.TaskDetails) string { return strconv.Itoa(t.AuthorID) },
+		set: func(t *storage.ImportTask, v string) (err error) { t.Task.AuthorID, err = parseID(v); return },
+	},
+	"author": {
+		get: func(t *storage.TaskDetails) string { return t.AuthorName },
+		set: func(t *storage.ImportTask, v string) error { t.Author = v; return nil },
+	},
+	"assignee_id": {
+		get: func(t *storage.TaskDetails) string { return strconv.Itoa(t.AssignedID) },
+		set: func(t *storage.ImportTask, v string) (err error) { t.Task.AssignedID, err = parseID(v); return },
+	},
+	"assignee": {
+		get: func(t *storage.TaskDetails) string { return t.AssigneeName },
+		set: func(t *storage.ImportTask, v string) error { t.Assignee = v; return nil },
+	},
+	"labels": {
+		get: func(t *storage.TaskDetails) string { return strings.Join(t.Labels, LabelsSeparator+" ") },
+		set: func(t *storage.ImportTask, v string) error {
+			for _, name := range strings.Split(v, LabelsSeparator) {
+				if name = strings.TrimSpace(name); name != "" {
+					t.Labels = append(t.Labels, name)
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// DefaultColumns - колонки экспорта по умолчанию.
+var DefaultColumns = []string{
+	"id", "title", "content", "opened", "closed", "due", "priority",
+	"author_id", "author", "assignee_id", "assignee", "labels",
+}
+
+// ParseColumns - разбирает список колонок через запятую, пустая строка дает DefaultColumns.
+func ParseColumns(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultColumns, nil
+	}
+	var result []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("неизвестная колонка: %s", name)
+		}
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+// Export - пишет задачи, подходящие под фильтр, в CSV построчно.
+func Export(ctx context.Context, w io.Writer, s *storage.Storage, filter storage.TaskFilter, names []string) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(names)
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(names))
+	n := 0
+	err = s.EachTaskDetails(ctx, filter, func(t *storage.TaskDetails) error {
+		for i, name := range names {
+			record[i] = escapeCell(columns[name].get(t))
+		}
+		err := cw.Write(record)
+		if err != nil {
+			return err
+		}
+		// Периодически сбрасываем буфер, чтобы клиент получал данные по мере выгрузки
+		n++
+		if n%100 == 0 {
+			cw.Flush()
+			if f, ok := w.(interface{ Flush() }); ok {
+				f.Flush()
+			}
+			return cw.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Parse - читает задачи из CSV. Первая строка - заголовок с названиями колонок,
+// неизвестные колонки и колонки только для экспорта игнорируются. Префикс ', добавленный
+// Export перед формулой, снимается.
+// Ошибки значений сохраняются в ImportTask.Errors, чтобы попасть в отчет импорта.
+func Parse(r io.Reader) ([]storage.ImportTask, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("пустой файл")
+		}
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.ToLower(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+
+	var tasks []storage.ImportTask
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		t := storage.ImportTask{Row: row}
+		for i, v := range record {
+			if i >= len(header) {
+				t.Errors = append(t.Errors, fmt.Sprintf("лишнее значение в колонке %d", i+1))
+				continue
+			}
+			c, ok := columns[header[i]]
+			if !ok || c.set == nil {
+				continue
+			}
+			err = c.set(&t, unescapeCell(strings.TrimSpace(v)))
+			if err != nil {
+				t.Errors = append(t.Errors, fmt.Sprintf("%s: %s", header[i], err.Error()))
+			}
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, nil
+}
+
+// escapeCell - добавляет ' перед значением, которое таблица выполнит как формулу.
+// Значение, уже начинающееся с ', тоже экранируется, чтобы unescapeCell его не изменил
+func escapeCell(v string) string {
+	if v != "" && strings.ContainsRune(formulaChars+"'", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// unescapeCell - снимает префикс, добавленный escapeCell
+func unescapeCell(v string) string {
+	if len(v) > 1 && v[0] == '\'' && strings.ContainsRune(formulaChars+"'", rune(v[1])) {
+		return v[1:]
+	}
+	return v
+}
+
+// formatTime - unix time в RFC3339 (UTC), 0 - пустая строка
+func formatTime(unix int64) string {
+	if unix == 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+// parseTime - дата в одном из timeLayouts (UTC) или unix time, пустая строка - 0
+func parseTime(v string) (int64, error) {
+	if v == "" {
+		return 0, nil
+	}
+	if unix, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return unix, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("некорректная дата %q", v)
+}
+
+// parseID - ID записи, пустая строка - 0
+func parseID(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("некорректный ID %q", v)
+	}
+	return id, nil
+}
+
+// parsePriority - приоритет задачи от 0 до 3, пустая строка - 0
+func parsePriority(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	p, err := strconv.Atoi(v)
+	if err != nil || p < 0 || p > 3 {
+		return 0, fmt.Errorf("некорректный приоритет %q", v)
+	}
+	return p, nil
+}
@@ -0,0 +1,78 @@
+package tasksCSV
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data := "\ufeffID,Title,Due,Author,Assignee_ID,Labels,Unknown\n" +
+		"7,Первая,2024-01-02,Иван,2,\"bug; backend\",x\n" +
+		"8,Вторая,завтра,,abc,,\n"
+
+	tasks, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("len = %d, want 2", len(tasks))
+	}
+
+	first := tasks[0]
+	if first.Row != 2 || first.Task.ID != 0 || first.Task.Title != "Первая" || first.Task.Due != 1704153600 ||
+		first.Author != "Иван" || first.Task.AssignedID != 2 || len(first.Errors) != 0 {
+		t.Errorf("первая строка разобрана неверно: %+v", first)
+	}
+	if !reflect.DeepEqual(first.Labels, []string{"bug", "backend"}) {
+		t.Errorf("Labels = %v", first.Labels)
+	}
+
+	if len(tasks[1].Errors) != 2 {
+		t.Errorf("вторая строка: ожидались ошибки даты и ID, получено %v", tasks[1].Errors)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	got, err := ParseColumns("id, Title ,labels")
+	if err != nil || !reflect.DeepEqual(got, []string{"id", "title", "labels"}) {
+		t.Errorf("ParseColumns() = %v, %v", got, err)
+	}
+	if _, err = ParseColumns("id,password"); err == nil {
+		t.Errorf("ParseColumns() не вернул ошибку для неизвестной колонки")
+	}
+}
+
+func TestEscapeCell(t *testing.T) {
+	tests := map[string]string{
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+1":                       "'+1",
+		"-1":                       "'-1",
+		"@SUM(A1)":                 "'@SUM(A1)",
+		"\tзадача":                 "'\tзадача",
+		"\r=1":                     "'\r=1",
+		"'=1":                      "''=1",
+		"'цитата":                  "''цитата",
+		"обычная":                  "обычная",
+	}
+	for value, want := range tests {
+		if got := escapeCell(value); got != want {
+			t.Errorf("escapeCell(%q) = %q, want %q", value, got, want)
+		}
+
+		// Экспортированное значение импортируется без изменений
+		var b strings.Builder
+		w := csv.NewWriter(&b)
+		w.WriteAll([][]string{{"title"}, {escapeCell(value)}})
+		tasks, err := Parse(strings.NewReader(b.String()))
+		if err != nil || len(tasks) != 1 || tasks[0].Task.Title != value {
+			t.Errorf("%q: после импорта %+v, %v", value, tasks, err)
+		}
+	}
+
+	// Апостроф, введенный вручную, сохраняется
+	if got := unescapeCell("'abc"); got != "'abc" {
+		t.Errorf("unescapeCell('abc) = %q", got)
+	}
+}
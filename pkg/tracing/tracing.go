@@ -0,0 +1,114 @@
+// Package tracing - трассировка OpenTelemetry: настройка экспорта, распространение
+// контекста W3C Trace Context во входящих и исходящих HTTP запросах.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Экспортеры трассировки.
+const (
+	// Трассировка отключена
+	ExporterNone = "none"
+	// OTLP по HTTP, адрес и заголовки задаются переменными OTEL_EXPORTER_OTLP_*
+	ExporterOTLP = "otlp"
+	// Вывод span-ов в stdout для локальной отладки
+	ExporterStdout = "stdout"
+)
+
+// Имя сервиса по умолчанию в ресурсе трассировки.
+const DefaultServiceName = "taskmanager"
+
+// Config - настройки трассировки.
+type Config struct {
+	// Экспортер: none, otlp или stdout
+	Exporter string
+	// Имя сервиса, переменная OTEL_SERVICE_NAME имеет приоритет
+	ServiceName string
+	// Доля трассируемых запросов без входящего контекста, от 0 до 1
+	SampleRatio float64
+}
+
+// Setup - настраивает глобальный провайдер трассировки и распространение контекста W3C.
+// Возвращаемая функция отправляет оставшиеся span-ы и останавливает экспорт.
+// Контекст распространяется и при отключенной трассировке, чтобы не разрывать цепочку вызовов.
+func Setup(ctx context.Context, config Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	switch config.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("неизвестный экспортер трассировки: %s", config.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	name := config.ServiceName
+	if name == "" {
+		name = DefaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(name),
+	))
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME и OTEL_RESOURCE_ATTRIBUTES важнее настроек
+	env, err := resource.New(ctx, resource.WithFromEnv())
+	if err == nil {
+		res, _ = resource.Merge(res, env)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Middleware - начинает span входящего запроса с именем по шаблону маршрута mux
+// и продолжает трассировку из заголовка traceparent
+func Middleware(service string) mux.MiddlewareFunc {
+	return otelmux.Middleware(service)
+}
+
+// Transport - http.RoundTripper, создающий span исходящего запроса
+// и передающий контекст трассировки в заголовке traceparent
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base)
+}
+
+// Client - HTTP клиент для исходящих запросов (вебхуков) с передачей контекста трассировки.
+// Запросы должны создаваться с контекстом входящего запроса или события: http.NewRequestWithContext
+func Client() *http.Client {
+	return &http.Client{Transport: Transport(nil)}
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Входящий traceparent продолжается в span-е маршрута и передается в исходящий запрос.
+func TestPropagation(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var outgoing string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outgoing = r.Header.Get("traceparent")
+	}))
+	defer hook.Close()
+
+	r := mux.NewRouter()
+	r.HandleFunc("/gettask", func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, hook.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}).Queries("id", "{id}")
+	r.Use(Middleware(DefaultServiceName))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/gettask?id=1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("ожидалось 2 span-а, получено %d", len(ended))
+	}
+	var server trace.SpanContext
+	for _, s := range ended {
+		if s.SpanContext().TraceID().String() != traceID {
+			t.Errorf("span %q вне входящей трассировки: %s", s.Name(), s.SpanContext().TraceID())
+		}
+		if s.SpanKind() == trace.SpanKindServer {
+			server = s.SpanContext()
+			if s.Name() != "/gettask" {
+				t.Errorf("имя span-а маршрута %q, ожидалось /gettask", s.Name())
+			}
+		}
+	}
+	if !server.IsValid() {
+		t.Fatal("нет span-а входящего запроса")
+	}
+	if outgoing == "" || outgoing[3:35] != traceID {
+		t.Errorf("traceparent исходящего запроса: %q", outgoing)
+	}
+}
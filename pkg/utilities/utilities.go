@@ -18,7 +18,7 @@ func jsonPrettyPrint(in string) string {
 func ToJSON(object any) string {
 	jsonByte, err := json.Marshal(object)
 	if err != nil {
-		logger.Error("Ошибка при получении JSON: ", err.Error())
+		logger.Error("Ошибка при получении JSON: %s", err.Error())
 	}
 	n := len(jsonByte)
 	result := string(jsonByte[:n])